@@ -0,0 +1,134 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/broadcaster"
+)
+
+var (
+	adminAddress        string
+	replayBroker        string
+	replayKinds         []string
+	replayNamespaces    []string
+	replayLabelSelector string
+)
+
+// pauseCmd pauses publishing on a running broadcaster
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Pause publishing events",
+	Long:  `Pause publishing events on a running broadcaster. Events are buffered or dropped depending on its --pause-policy until publishing is resumed.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return callAdmin(broadcaster.ADMIN_PAUSE_PATH, nil)
+	},
+}
+
+// resumeCmd resumes publishing on a running broadcaster
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume publishing events",
+	Long:  `Resume publishing events on a running broadcaster. Buffered events are published before new events.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return callAdmin(broadcaster.ADMIN_RESUME_PATH, nil)
+	},
+}
+
+// replayCmd replays the resources on the caches of a running broadcaster
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Publish the current state of the resources",
+	Long: `Publish a snapshot event for every resource on the caches of a running broadcaster, followed by a
+snapshot.events.completed event for each kind, so consumers can rebuild the state of the resources.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		params := url.Values{}
+		if replayBroker != "" {
+			params.Set("broker", replayBroker)
+		}
+		if len(replayKinds) > 0 {
+			params.Set("kind", strings.Join(replayKinds, ","))
+		}
+		if len(replayNamespaces) > 0 {
+			params.Set("namespace", strings.Join(replayNamespaces, ","))
+		}
+		if replayLabelSelector != "" {
+			params.Set("labelSelector", replayLabelSelector)
+		}
+
+		return callAdmin(broadcaster.ADMIN_REPLAY_PATH, params)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{pauseCmd, resumeCmd, replayCmd} {
+		cmd.Flags().StringVar(&adminAddress, "address", "http://localhost:8089", "Address of the admin endpoints of the broadcaster, served on its --port when --admin is set")
+		rootCmd.AddCommand(cmd)
+	}
+
+	replayCmd.Flags().StringVar(&replayBroker, "broker", "", "Name of the broker the events are published to, e.g. pubsub or kafka. Events are published to every broker if empty")
+	replayCmd.Flags().StringSliceVar(&replayKinds, "kind", nil, "Kinds of the resources replayed, e.g. GameServer,Fleet. Every watched kind is replayed if empty")
+	replayCmd.Flags().StringSliceVar(&replayNamespaces, "namespace", nil, "Namespaces of the resources replayed. Resources of every namespace are replayed if empty")
+	replayCmd.Flags().StringVar(&replayLabelSelector, "label-selector", "", "Label selector of the resources replayed, e.g. mode=ranked")
+}
+
+// callAdmin sends a POST request to the admin endpoint of the broadcaster and prints the response
+func callAdmin(path string, params url.Values) error {
+	endpoint := strings.TrimSuffix(adminAddress, "/") + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	// Replays publish every resource before responding
+	client := &http.Client{Timeout: 10 * time.Minute}
+	resp, err := client.Post(endpoint, "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("error calling %s: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s: %s", endpoint, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		indented.Reset()
+		indented.Write(body)
+	}
+	_, err = fmt.Fprintln(os.Stdout, strings.TrimSpace(indented.String()))
+
+	return err
+}
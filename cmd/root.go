@@ -16,35 +16,89 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/api/option"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/tools/clientcmd"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/Octops/agones-event-broadcaster/internal/version"
 	"github.com/Octops/agones-event-broadcaster/pkg/broadcaster"
 	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
 	"github.com/Octops/agones-event-broadcaster/pkg/brokers/kafka"
 	"github.com/Octops/agones-event-broadcaster/pkg/brokers/pubsub"
 	"github.com/Octops/agones-event-broadcaster/pkg/brokers/stdout"
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers/webhook"
+	"github.com/Octops/agones-event-broadcaster/pkg/checkpoint"
+	"github.com/Octops/agones-event-broadcaster/pkg/controller"
+	"github.com/Octops/agones-event-broadcaster/pkg/enrichment"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/cloudevents"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/projection"
+	"github.com/Octops/agones-event-broadcaster/pkg/rpc"
+	"github.com/Octops/agones-event-broadcaster/pkg/sharding"
+	"github.com/Octops/agones-event-broadcaster/pkg/stream"
+	"github.com/Octops/agones-event-broadcaster/pkg/tracing"
+	"github.com/Octops/agones-event-broadcaster/pkg/transform"
 )
 
 var (
 	cfgFile                 string
 	kubeconfig              string
+	kubeconfigContexts      []string
+	kubeconfigDir           string
 	verbose                 bool
-	brokerFlag              string
+	brokerFlag              []string
+	filterFlag              string
+	brokerFilters           []string
+	namespaces              []string
+	labelSelectors          []string
+	fieldSelectors          []string
+	watch                   []string
+	watchKinds              []string
+	diagnosticsEnabled      bool
+	enrichmentEnabled       bool
+	deletingEvents          bool
+	startupMode             string
+	checkpointConfig        = &checkpoint.Config{}
+	streamEnabled           bool
+	streamConfig            = &stream.Config{}
+	rpcConfig               = &rpc.Config{}
+	adminEnabled            bool
+	profilingEnabled        bool
+	pausePolicy             string
+	pauseConfig             = &broadcaster.PauseConfig{}
+	tracingExporter         string
+	tracingConfig           = &tracing.Config{}
+	maxRetries              int
+	retryTimeout            time.Duration
+	maxPending              int
+	leaderElect             bool
+	leaderElection          = &manager.LeaderElection{}
+	shardingEnabled         bool
+	shardingStrategy        string
+	shardingConfig          = &sharding.Config{}
+	enrichmentConfig        = enrichment.DefaultConfig()
 	syncPeriod              string
 	port                    int
 	metricsBindAddress      string
 	maxConcurrencyReconcile int
+	clusterName             string
+	cloudEventsMode         string
+	projectionConfig        = projection.DefaultConfig()
 )
 
 var rootCmd = &cobra.Command{
@@ -56,40 +110,318 @@ var rootCmd = &cobra.Command{
 		if verbose {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
-		clientConf, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			logrus.WithError(err).Fatalf("error reading kubeconfig: %s", kubeconfig)
+		encoder := BuildCloudEventsEncoder(cloudEventsMode)
+		filters := ParseKeyValues("broker-filter", brokerFilters)
+		if len(brokerFlag) == 0 {
+			brokerFlag = []string{"stdout"}
 		}
-
-		broker := BuildBroker(brokerFlag)
+		brokerName, brokerType, brokerKey := ParseBroker(brokerFlag[0])
 
 		duration, err := time.ParseDuration(syncPeriod)
 		if err != nil {
 			logrus.WithError(err).Fatalf("error parsing sync-period flag: %s", syncPeriod)
 		}
 
+		var templates []transform.Template
+		if err := viper.UnmarshalKey("templates", &templates); err != nil {
+			logrus.WithError(err).Fatal("error reading templates from config file")
+		}
+
+		pauseConfig.Policy = broadcaster.PausePolicy(pausePolicy)
 		opts := &broadcaster.Config{
 			SyncPeriod:             duration,
 			ServerPort:             port,
 			MetricsBindAddress:     metricsBindAddress,
 			MaxConcurrentReconcile: 4,
+			ClusterName:            clusterName,
+			Projection:             projectionConfig,
+			Templates:              templates,
+			Filter:                 filterFlag,
+			BrokerName:             brokerName,
+			BrokerFilter:           filters[brokerKey],
+			Namespaces:             namespaces,
+			DeletingEvents:         deletingEvents,
+			MaxRetries:             maxRetries,
+			RetryTimeout:           retryTimeout,
+			MaxPending:             maxPending,
+			StartupMode:            broadcaster.StartupMode(startupMode),
+			Pause:                  pauseConfig,
+			Admin:                  adminEnabled,
+			Profiling:              profilingEnabled,
+		}
+		if opts.StartupMode == broadcaster.STARTUP_MODE_RESUME {
+			opts.Checkpoint = checkpointConfig
+		}
+		if streamEnabled {
+			opts.Stream = streamConfig
+		}
+		if rpcConfig.Address != "" {
+			opts.RPC = rpcConfig
+		}
+		if enrichmentEnabled {
+			opts.Enrichment = enrichmentConfig
+		}
+		if leaderElect {
+			opts.LeaderElection = leaderElection
+		}
+		if shardingEnabled {
+			shardingConfig.Strategy = sharding.Strategy(shardingStrategy)
+			opts.Sharding = shardingConfig
+		}
+		var bc *broadcaster.Broadcaster
+		if len(kubeconfigContexts) > 0 || kubeconfigDir != "" {
+			clusters, err := LoadClusters(kubeconfig, kubeconfigContexts, kubeconfigDir)
+			if err != nil {
+				logrus.WithError(err).Fatal("error reading clusters")
+			}
+			bc = broadcaster.NewMultiCluster(clusters, BuildBroker(brokerType, encoder), opts)
+		} else {
+			clientConf, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+			if err != nil {
+				logrus.WithError(err).Fatalf("error reading kubeconfig: %s", kubeconfig)
+			}
+			bc = broadcaster.New(clientConf, BuildBroker(brokerType, encoder), opts)
+		}
+		for _, value := range brokerFlag[1:] {
+			name, ofType, key := ParseBroker(value)
+			if name == "" {
+				bc.WithBroker(BuildBroker(ofType, encoder), filters[key])
+				continue
+			}
+			bc.WithNamedBroker(name, BuildBroker(ofType, encoder), filters[key])
 		}
-		bc := broadcaster.New(clientConf, broker, opts)
 
-		if err := bc.WithWatcherFor(&v1.Fleet{}).WithWatcherFor(&v1.GameServer{}).Build(); err != nil {
+		labelSels := ParseKeyValues("label-selector", labelSelectors)
+		fieldSels := ParseKeyValues("field-selector", fieldSelectors)
+		for _, resource := range watch {
+			obj, err := Watchable(resource)
+			if err != nil {
+				logrus.WithError(err).Fatal("error parsing watch flag")
+			}
+			bc.WithWatcherFor(obj, WatcherOptions(labelSels[resource], fieldSels[resource])...)
+		}
+
+		var resources []Resource
+		if err := viper.UnmarshalKey("resources", &resources); err != nil {
+			logrus.WithError(err).Fatal("error reading resources from config file")
+		}
+		for _, kind := range watchKinds {
+			resource, err := ParseResource(kind)
+			if err != nil {
+				logrus.WithError(err).Fatal("error parsing watch-kind flag")
+			}
+			resources = append(resources, resource)
+		}
+		for _, resource := range resources {
+			name := strings.ToLower(resource.Kind)
+			options := WatcherOptions(labelSels[name], fieldSels[name])
+			if resource.LabelSelector != "" || resource.FieldSelector != "" {
+				options = WatcherOptions(resource.LabelSelector, resource.FieldSelector)
+			}
+			if len(resource.Namespaces) > 0 {
+				options = append(options, broadcaster.InNamespaces(resource.Namespaces...))
+			}
+			bc.WithWatcherForKind(resource.GroupVersionKind(), options...)
+		}
+
+		if diagnosticsEnabled {
+			bc.WithDiagnostics()
+		}
+
+		if err := bc.Build(); err != nil {
 			logrus.WithError(err).Fatal("error creating broadcaster")
 		}
 
 		ctx := ctrl.SetupSignalHandler()
+		if tracingExporter != "" {
+			provider := StartTracing(ctx, tracing.Exporter(tracingExporter))
+			defer func() {
+				// Spans still batched are exported before exiting
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := provider.Shutdown(shutdownCtx); err != nil {
+					logrus.WithError(err).Error("error flushing spans")
+				}
+			}()
+		}
+
 		if err := bc.Start(ctx); err != nil {
 			logrus.WithError(err).Fatal("error starting broadcaster")
 		}
 	},
 }
 
+// BuildCloudEventsEncoder creates the encoder used by brokers to publish CloudEvents.
+// It returns nil when mode is empty and brokers should publish the broadcaster envelope instead.
+func BuildCloudEventsEncoder(mode string) *cloudevents.Encoder {
+	if mode == "" {
+		return nil
+	}
+
+	encoder, err := cloudevents.NewEncoder(clusterName, cloudevents.ContentMode(mode), manager.Scheme)
+	if err != nil {
+		logrus.WithError(err).Fatal("error creating cloudevents encoder")
+	}
+
+	return encoder
+}
+
+// StartTracing creates the tracer provider exporting the spans of the publishing pipeline to the exporter
+func StartTracing(ctx context.Context, exporter tracing.Exporter) *tracing.Provider {
+	tracingConfig.Exporter = exporter
+	tracingConfig.ServiceVersion = version.Version
+	tracingConfig.Instance, _ = os.Hostname()
+
+	provider, err := tracing.New(ctx, tracingConfig)
+	if err != nil {
+		logrus.WithError(err).Fatal("error creating tracer provider")
+	}
+
+	return provider
+}
+
+// watchable maps the resource names used by flags to the resource types
+var watchable = map[string]client.Object{
+	"fleets":           &v1.Fleet{},
+	"gameservers":      &v1.GameServer{},
+	"gameserversets":   &v1.GameServerSet{},
+	"fleetautoscalers": &autoscalingv1.FleetAutoscaler{},
+}
+
+// Watchable returns the resource type for the resource name used by flags.
+// GameServerAllocations are rejected since Kubernetes does not store them, so they can't be listed or watched.
+func Watchable(name string) (client.Object, error) {
+	if name == "gameserverallocations" {
+		return nil, fmt.Errorf("gameserverallocations can't be watched, allocations are published as updates of gameservers to the Allocated state")
+	}
+
+	obj, ok := watchable[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown resource %q", name)
+	}
+
+	return obj.DeepCopyObject().(client.Object), nil
+}
+
+// Resource is a resource watched as an unstructured object. Selectors and namespaces are optional.
+type Resource struct {
+	Group         string   `mapstructure:"group"`
+	Version       string   `mapstructure:"version"`
+	Kind          string   `mapstructure:"kind"`
+	Namespaces    []string `mapstructure:"namespaces"`
+	LabelSelector string   `mapstructure:"labelSelector"`
+	FieldSelector string   `mapstructure:"fieldSelector"`
+}
+
+// GroupVersionKind returns the GroupVersionKind of the resource
+func (r Resource) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind}
+}
+
+// ParseResource parses a resource in the format group/version/Kind. Resources of the core group use the format version/Kind.
+func ParseResource(value string) (Resource, error) {
+	i := strings.LastIndex(value, "/")
+	if i < 0 {
+		return Resource{}, fmt.Errorf("invalid kind %q, expected format group/version/Kind", value)
+	}
+
+	gv, err := schema.ParseGroupVersion(value[:i])
+	if err != nil {
+		return Resource{}, fmt.Errorf("invalid kind %q: %v", value, err)
+	}
+
+	return Resource{Group: gv.Group, Version: gv.Version, Kind: value[i+1:]}, nil
+}
+
+// LoadClusters returns a cluster for each context of the kubeconfig, named after the context,
+// and for each kubeconfig file of dir, named after the file without extension
+func LoadClusters(kubeconfig string, contexts []string, dir string) ([]broadcaster.Cluster, error) {
+	var clusters []broadcaster.Cluster
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	for _, context := range contexts {
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+		restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("error reading context %s: %v", context, err)
+		}
+		clusters = append(clusters, broadcaster.Cluster{Name: context, RestConfig: restConfig})
+	}
+
+	if dir == "" {
+		return clusters, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading kubeconfig directory %s: %v", dir, err)
+	}
+
+	for _, entry := range entries {
+		// Hidden files are skipped, e.g. the ..data links of mounted secrets
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		restConfig, err := clientcmd.BuildConfigFromFlags("", path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading kubeconfig %s: %v", path, err)
+		}
+
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		clusters = append(clusters, broadcaster.Cluster{Name: name, RestConfig: restConfig})
+	}
+
+	return clusters, nil
+}
+
+// ParseKeyValues parses flag values in the format key=value. Only the first = separates the key from the value.
+func ParseKeyValues(flag string, values []string) map[string]string {
+	parsed := map[string]string{}
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			logrus.Fatalf("invalid %s %q, expected format key=value", flag, value)
+		}
+		parsed[parts[0]] = parts[1]
+	}
+
+	return parsed
+}
+
+// ParseBroker parses a value of the broker flag, formatted as [name=]type. key is the key of the filter of the broker
+// on the broker-filter flag: its name, or its type when the broker isn't named.
+func ParseBroker(value string) (name, ofType, key string) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) == 1 {
+		return "", value, value
+	}
+
+	if parts[0] == "" || parts[1] == "" {
+		logrus.Fatalf("invalid broker %q, expected format [name=]type", value)
+	}
+
+	return parts[0], parts[1], parts[0]
+}
+
+// WatcherOptions returns the options restricting a watcher to the selectors. Empty selectors are ignored.
+func WatcherOptions(labelSelector, fieldSelector string) []broadcaster.WatcherOption {
+	var options []broadcaster.WatcherOption
+	if labelSelector != "" {
+		options = append(options, broadcaster.WithLabelSelector(labelSelector))
+	}
+	if fieldSelector != "" {
+		options = append(options, broadcaster.WithFieldSelector(fieldSelector))
+	}
+
+	return options
+}
+
 // BuildBroker creates a broker based on the broker flag.
 // This will refactored in the future and will be placed on a package
-func BuildBroker(ofType string) brokers.Broker {
+func BuildBroker(ofType string, encoder *cloudevents.Encoder) brokers.Broker {
 	if ofType == "pubsub" {
 		var opts []option.ClientOption
 		// If the broadcaster is running within GCP, credentials don't need to be explicitly passed
@@ -103,6 +435,7 @@ func BuildBroker(ofType string) brokers.Broker {
 			OnAddTopicID:    "agones.events.added",
 			OnUpdateTopicID: "agones.events.updated",
 			OnDeleteTopicID: "agones.events.deleted",
+			CloudEvents:     encoder,
 		}, opts...)
 		if err != nil {
 			logrus.WithError(err).Fatal("error creating broker")
@@ -114,11 +447,21 @@ func BuildBroker(ofType string) brokers.Broker {
 			APIKey:           os.Getenv("KAFKA_APIKEY"),
 			APISecret:        os.Getenv("KAFKA_APISECRET"),
 			BootstrapServers: os.Getenv("KAFKA_SERVERS"),
+			CloudEvents:      encoder,
 		})
 		if err != nil {
 			logrus.WithError(err).Fatal("error creating kafka broker")
 		}
 		return broker
+	} else if ofType == "webhook" {
+		broker, err := webhook.NewWebhookBroker(&webhook.Config{
+			URL:         os.Getenv("WEBHOOK_URL"),
+			CloudEvents: encoder,
+		})
+		if err != nil {
+			logrus.WithError(err).Fatal("error creating webhook broker")
+		}
+		return broker
 	}
 
 	// Used only for debugging purpose
@@ -137,12 +480,78 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.agones-event-broadcaster.yaml)")
 	rootCmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "Set KUBECONFIG")
-	rootCmd.Flags().StringVar(&brokerFlag, "broker", "", "The type of the broker to be used by the broadcaster")
+	rootCmd.Flags().StringSliceVar(&kubeconfigContexts, "kubeconfig-contexts", []string{}, "Contexts of the kubeconfig to publish events from. Each context is a cluster named after the context")
+	rootCmd.Flags().StringVar(&kubeconfigDir, "kubeconfig-dir", "", "Directory of kubeconfig files to publish events from. Each file is a cluster named after the file without extension")
+	rootCmd.Flags().StringSliceVar(&brokerFlag, "broker", nil, "The brokers to be used by the broadcaster, formatted as [name=]type, e.g. pubsub,audit=webhook. Brokers are named after their type by default")
 	rootCmd.Flags().StringVar(&syncPeriod, "sync-period", "15s", "Determines the minimum frequency at which watched resources are reconciled")
 	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Set log level to verbose, defaults to false")
-	rootCmd.Flags().IntVarP(&port, "port", "p", 8089, "Port used by the broadcaster to communicate via http")
+	rootCmd.Flags().IntVarP(&port, "port", "p", 8089, "Port of the health, readiness and debug endpoints. Disabled if 0")
 	rootCmd.Flags().StringVar(&metricsBindAddress, "metrics-bind-address", "0.0.0.0:8095", "The TCP address that the controller should bind to for serving prometheus metrics")
 	rootCmd.Flags().IntVar(&maxConcurrencyReconcile, "max-concurrency", 5, "Maximum number of concurrent Reconciles which can be run")
+	rootCmd.Flags().StringVar(&clusterName, "cluster-name", "", "The name of the cluster added to the metadata of every event")
+	rootCmd.Flags().StringSliceVar(&projectionConfig.Include, "include-fields", nil, "Field masks of the resource fields to be published, e.g. metadata.name,status. Publishes all fields if empty")
+	rootCmd.Flags().StringSliceVar(&projectionConfig.Exclude, "exclude-fields", nil, "Field masks of the resource fields to be removed before publishing, e.g. spec.template")
+	rootCmd.Flags().BoolVar(&projectionConfig.DropManagedFields, "drop-managed-fields", projectionConfig.DropManagedFields, "Remove managedFields from resources before publishing")
+	rootCmd.Flags().StringSliceVar(&projectionConfig.DropLabels, "drop-labels", projectionConfig.DropLabels, "Label keys, or glob patterns, removed from resources before publishing")
+	rootCmd.Flags().StringSliceVar(&projectionConfig.HashLabels, "hash-labels", projectionConfig.HashLabels, "Label keys, or glob patterns, which values are replaced by their sha256 hash")
+	rootCmd.Flags().StringSliceVar(&projectionConfig.DropAnnotations, "drop-annotations", projectionConfig.DropAnnotations, "Annotation keys, or glob patterns, removed from resources before publishing")
+	rootCmd.Flags().StringSliceVar(&projectionConfig.HashAnnotations, "hash-annotations", projectionConfig.HashAnnotations, "Annotation keys, or glob patterns, which values are replaced by their sha256 hash")
+	rootCmd.Flags().StringVar(&filterFlag, "filter", "", `CEL expression that decides if an event is published, e.g. object.status.state == "Allocated". Publishes all events if empty`)
+	rootCmd.Flags().StringArrayVar(&brokerFilters, "broker-filter", nil, `CEL expression that decides if an event is published by a particular broker, keyed by the name of the broker, e.g. audit=eventSource == "OnDelete". Can be repeated`)
+	rootCmd.Flags().StringSliceVar(&watch, "watch", []string{"fleets", "gameservers"}, "Resources watched by the broadcaster: gameservers, fleets, gameserversets and fleetautoscalers")
+	rootCmd.Flags().StringArrayVar(&watchKinds, "watch-kind", nil, "Kind watched as an unstructured object in the format group/version/Kind, e.g. games.example.com/v1/MatchTicket. Can be repeated")
+	rootCmd.Flags().BoolVar(&diagnosticsEnabled, "diagnostics", false, "Watch the Pods and Nodes backing GameServers and publish events like gameserver.pod.oomkilled and gameserver.node.notready")
+	rootCmd.Flags().BoolVar(&leaderElect, "leader-elect", false, "Run several replicas where only the elected leader publishes events. Other replicas are kept on standby with a warm cache")
+	rootCmd.Flags().StringVar(&leaderElection.ID, "leader-election-id", "agones-event-broadcaster", "Name of the Lease used for leader election")
+	rootCmd.Flags().StringVar(&leaderElection.Namespace, "leader-election-namespace", "", "Namespace of the Lease used for leader election. Defaults to the namespace the broadcaster is running on")
+	rootCmd.Flags().DurationVar(&leaderElection.LeaseDuration, "lease-duration", 15*time.Second, "Duration that replicas on standby wait before trying to acquire leadership")
+	rootCmd.Flags().DurationVar(&leaderElection.RenewDeadline, "renew-deadline", 10*time.Second, "Duration the leader retries to renew leadership before giving it up")
+	rootCmd.Flags().DurationVar(&leaderElection.RetryPeriod, "retry-period", 2*time.Second, "Duration replicas wait between leader election actions")
+	rootCmd.Flags().BoolVar(&shardingEnabled, "sharding", false, "Run several replicas where each replica publishes the events of the resources assigned to it. Can't be used with --leader-elect")
+	rootCmd.Flags().StringVar(&shardingConfig.Group, "sharding-group", "agones-event-broadcaster", "Name of the group of replicas sharing resources. Used as prefix of the Lease of each replica")
+	rootCmd.Flags().StringVar(&shardingConfig.Namespace, "sharding-namespace", "", "Namespace of the Leases used for sharding. Defaults to the namespace the broadcaster is running on")
+	rootCmd.Flags().StringVar(&shardingConfig.Identity, "sharding-identity", "", "Identity of the replica among the replicas sharing resources. It names the Lease and the checkpoint of the replica and must be stable across restarts. Defaults to the hostname")
+	rootCmd.Flags().StringVar(&shardingStrategy, "sharding-strategy", string(sharding.STRATEGY_NAME), "How resources are assigned to replicas: name (namespace and name) or namespace")
+	rootCmd.Flags().DurationVar(&shardingConfig.LeaseDuration, "sharding-lease-duration", sharding.DEFAULT_LEASE_DURATION, "Duration after which resources of a replica that stopped renewing its Lease are assigned to other replicas")
+	rootCmd.Flags().DurationVar(&shardingConfig.RenewPeriod, "sharding-renew-period", sharding.DEFAULT_RENEW_PERIOD, "Duration replicas wait between renewals of their Lease")
+	rootCmd.Flags().StringVar(&startupMode, "startup-mode", string(broadcaster.STARTUP_MODE_ADDED), "How resources that existed when the broadcaster started are published: added, snapshot (*.events.snapshot events followed by snapshot.events.completed), skip or resume (only the changes since the last checkpoint)")
+	rootCmd.Flags().StringVar(&checkpointConfig.Dir, "checkpoint-dir", "", "Directory of the file where the versions of the published resources are saved. Used by the resume startup mode")
+	rootCmd.Flags().StringVar(&checkpointConfig.ConfigMap, "checkpoint-configmap", "", "Name of the ConfigMap where the versions of the published resources are saved. Used by the resume startup mode")
+	rootCmd.Flags().StringVar(&checkpointConfig.Namespace, "checkpoint-namespace", "", "Namespace of the checkpoint ConfigMap. Defaults to the namespace the broadcaster is running on")
+	rootCmd.Flags().DurationVar(&checkpointConfig.Interval, "checkpoint-interval", checkpoint.DEFAULT_INTERVAL, "Time between saves of the checkpoint")
+	rootCmd.Flags().BoolVar(&streamEnabled, "stream", false, "Stream the published events on the /events (Server-Sent Events) and /events/ws (WebSocket) endpoints of --port")
+	rootCmd.Flags().IntVar(&streamConfig.BufferSize, "stream-buffer-size", stream.DEFAULT_BUFFER_SIZE, "Number of events waiting to be sent to a stream client before it is disconnected")
+	rootCmd.Flags().StringSliceVar(&streamConfig.AllowedOrigins, "stream-allowed-origins", nil, "Origins of the browsers allowed to connect to /events/ws, e.g. https://dashboard.example.com, in addition to the origin of the server. * allows every origin")
+	rootCmd.Flags().StringVar(&rpcConfig.Address, "grpc-address", "", "Address of the gRPC API streaming the published events and listing resources, e.g. :8090. Disabled if empty")
+	rootCmd.Flags().StringVar(&rpcConfig.CertFile, "grpc-tls-cert", "", "Certificate file of the gRPC API. Enables TLS")
+	rootCmd.Flags().StringVar(&rpcConfig.KeyFile, "grpc-tls-key", "", "Private key file of the gRPC API certificate")
+	rootCmd.Flags().StringVar(&rpcConfig.ClientCAFile, "grpc-tls-client-ca", "", "CA file used to verify the certificates of gRPC clients. Enables mutual TLS")
+	rootCmd.Flags().BoolVar(&rpcConfig.Insecure, "grpc-insecure", false, "Serve the gRPC API without TLS when no certificate is set, e.g. behind a service mesh encrypting the traffic")
+	rootCmd.Flags().IntVar(&rpcConfig.BufferSize, "grpc-buffer-size", rpc.DEFAULT_BUFFER_SIZE, "Number of events waiting to be sent to a gRPC subscriber before it is disconnected")
+	rootCmd.Flags().IntVar(&rpcConfig.ReplaySize, "grpc-replay-size", rpc.DEFAULT_REPLAY_SIZE, "Number of events kept for gRPC subscribers resuming the stream after reconnecting")
+	rootCmd.Flags().BoolVar(&adminEnabled, "admin", false, "Serve the /admin/pause, /admin/resume and /admin/replay endpoints on --port. The endpoints are not authenticated")
+	rootCmd.Flags().BoolVar(&profilingEnabled, "profiling", false, "Serve the Go profiles on /debug/pprof on --port. The endpoints are not authenticated")
+	rootCmd.Flags().StringVar(&pausePolicy, "pause-policy", string(broadcaster.PAUSE_POLICY_BUFFER), "What happens to the events published while publishing is paused: buffer (published once resumed) or drop")
+	rootCmd.Flags().IntVar(&pauseConfig.BufferSize, "pause-buffer-size", broadcaster.DEFAULT_PAUSE_BUFFER_SIZE, "Number of events buffered while publishing is paused. Events published once the buffer is full are dropped")
+	rootCmd.Flags().StringVar(&pauseConfig.Dir, "pause-buffer-dir", "", "Directory of the file where events are buffered while publishing is paused. Events are buffered in memory if empty")
+	rootCmd.Flags().StringVar(&tracingExporter, "tracing-exporter", "", "Export OpenTelemetry spans of the publishing pipeline using otlp (OTLP over gRPC) or stdout. Disabled if empty")
+	rootCmd.Flags().StringVar(&tracingConfig.Endpoint, "tracing-endpoint", "", "Address of the OTLP collector, e.g. otel-collector:4317. Defaults to OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317")
+	rootCmd.Flags().BoolVar(&tracingConfig.Insecure, "tracing-insecure", false, "Connect to the OTLP collector without TLS")
+	rootCmd.Flags().Float64Var(&tracingConfig.SampleRatio, "tracing-sample-ratio", 1, "Fraction of the events traced, between 0 and 1")
+	rootCmd.Flags().IntVar(&maxRetries, "max-retries", controller.DEFAULT_MAX_RETRIES, "Number of times an event that failed to be published is retried before it is dropped")
+	rootCmd.Flags().DurationVar(&retryTimeout, "retry-timeout", controller.DEFAULT_RETRY_TIMEOUT, "How long an event that failed to be published is retried before it is dropped")
+	rootCmd.Flags().IntVar(&maxPending, "max-pending", controller.DEFAULT_MAX_PENDING, "Maximum number of events waiting to be retried per kind of resource. Events failing once it is reached are dropped")
+	rootCmd.Flags().BoolVar(&deletingEvents, "deleting-events", false, "Publish a *.events.deleting event when the deletion of a resource is requested")
+	rootCmd.Flags().BoolVar(&enrichmentEnabled, "enrich", false, "Attach the node and pod information of GameServers to their events")
+	rootCmd.Flags().StringSliceVar(&enrichmentConfig.NodeLabels, "enrich-node-labels", enrichmentConfig.NodeLabels, "Node label keys, or glob patterns, attached to GameServer events")
+	rootCmd.Flags().StringSliceVar(&enrichmentConfig.NodeAddressTypes, "enrich-node-addresses", enrichmentConfig.NodeAddressTypes, "Types of the node addresses attached to GameServer events, e.g. ExternalIP,InternalIP")
+	rootCmd.Flags().StringSliceVar(&enrichmentConfig.PodLabels, "enrich-pod-labels", enrichmentConfig.PodLabels, "Pod label keys, or glob patterns, attached to GameServer events")
+	rootCmd.Flags().StringSliceVar(&enrichmentConfig.PodAnnotations, "enrich-pod-annotations", enrichmentConfig.PodAnnotations, "Pod annotation keys, or glob patterns, attached to GameServer events")
+	rootCmd.Flags().DurationVar(&enrichmentConfig.Timeout, "enrich-timeout", enrichmentConfig.Timeout, "Maximum amount of time spent reading the node and pod of a GameServer. Events are retried if it is exceeded")
+	rootCmd.Flags().StringSliceVar(&namespaces, "namespaces", nil, "Namespaces watched by the broadcaster. Watches all namespaces if empty")
+	rootCmd.Flags().StringArrayVar(&labelSelectors, "label-selector", nil, "Label selector restricting the resources of a type that are watched, e.g. gameservers=mode=ranked. Can be repeated")
+	rootCmd.Flags().StringArrayVar(&fieldSelectors, "field-selector", nil, "Field selector restricting the resources of a type that are watched, e.g. fleets=metadata.name=simple-udp. Can be repeated")
+	rootCmd.Flags().StringVar(&cloudEventsMode, "cloudevents-mode", "", "Publish events as CloudEvents using the structured or binary content mode. Disabled if empty")
 }
 
 // initConfig reads in config file and ENV variables if set.
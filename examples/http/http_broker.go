@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"reflect"
 	"sync"
 	"time"
 
@@ -86,17 +85,20 @@ func (h *HTTPBroker) BuildEnvelope(event events.Event) (*events.Envelope, error)
 }
 
 func (h *HTTPBroker) SendMessage(envelope *events.Envelope) error {
-	message := envelope.Message.(events.Message).Content()
+	message := envelope.Message.(events.Message)
 	eventType := envelope.Header.Headers["event_type"]
 
+	gsAgones, ok := events.ObjectAs[*v1.GameServer](message)
+	if !ok {
+		return nil
+	}
+
 	switch eventType {
 	case "gameserver.events.added":
-		gsAgones := message.(*v1.GameServer)
 		return h.handleAdded(gsAgones)
 	case "gameserver.events.updated":
 		return h.handleUpdated(message)
 	case "gameserver.events.deleted":
-		gsAgones := message.(*v1.GameServer)
 		return h.handleDeleted(gsAgones)
 	}
 
@@ -154,9 +156,11 @@ func (h *HTTPBroker) handleAdded(gsAgones *v1.GameServer) error {
 	return nil
 }
 
-func (h *HTTPBroker) handleUpdated(message interface{}) error {
-	msgUpdate := reflect.ValueOf(message)
-	gsAgones := msgUpdate.Field(1).Interface().(*v1.GameServer)
+func (h *HTTPBroker) handleUpdated(message events.Message) error {
+	_, gsAgones, ok := events.UpdatedAs[*v1.GameServer](message)
+	if !ok {
+		return fmt.Errorf("message is not a gameserver update")
+	}
 
 	gs := GameServer(gsAgones)
 	if gsAgones.Status.State == v1.GameServerStateReady {
@@ -0,0 +1,99 @@
+package broadcaster
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	ADMIN_PAUSE_PATH  = "/admin/pause"
+	ADMIN_RESUME_PATH = "/admin/resume"
+	ADMIN_REPLAY_PATH = "/admin/replay"
+)
+
+// adminError is the body of the responses of failed admin requests
+type adminError struct {
+	Error string `json:"error"`
+}
+
+// adminHandlers returns the handlers of the admin endpoints:
+// GET /admin/pause returns the state of publishing. POST /admin/pause pauses publishing and POST /admin/resume resumes it.
+// POST /admin/replay replays the resources on the caches. Parameters: broker, kind, namespace and labelSelector.
+// kind and namespace are comma-separated lists.
+func (b *Broadcaster) adminHandlers() map[string]http.Handler {
+	return map[string]http.Handler{
+		ADMIN_PAUSE_PATH: http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			switch req.Method {
+			case http.MethodGet:
+				respond(resp, http.StatusOK, b.PauseState())
+			case http.MethodPost:
+				respond(resp, http.StatusOK, b.Pause())
+			default:
+				respond(resp, http.StatusMethodNotAllowed, adminError{Error: "only GET and POST requests are supported"})
+			}
+		}),
+		ADMIN_RESUME_PATH: http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if req.Method != http.MethodPost {
+				respond(resp, http.StatusMethodNotAllowed, adminError{Error: "only POST requests are supported"})
+				return
+			}
+			respond(resp, http.StatusOK, b.Resume())
+		}),
+		ADMIN_REPLAY_PATH: http.HandlerFunc(b.serveReplay),
+	}
+}
+
+func (b *Broadcaster) serveReplay(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		respond(resp, http.StatusMethodNotAllowed, adminError{Error: "only POST requests are supported"})
+		return
+	}
+
+	params := req.URL.Query()
+	selector, err := labels.Parse(params.Get("labelSelector"))
+	if err != nil {
+		respond(resp, http.StatusBadRequest, adminError{Error: "invalid labelSelector: " + err.Error()})
+		return
+	}
+
+	result, err := b.Replay(req.Context(), &ReplayRequest{
+		Broker:     params.Get("broker"),
+		Kinds:      splitValues(params["kind"]),
+		Namespaces: splitValues(params["namespace"]),
+		Selector:   selector,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Cause(err) == ErrInvalidReplayRequest {
+			status = http.StatusBadRequest
+		}
+		respond(resp, status, adminError{Error: err.Error()})
+		return
+	}
+
+	respond(resp, http.StatusOK, result)
+}
+
+func respond(resp http.ResponseWriter, status int, body interface{}) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(status)
+	_ = json.NewEncoder(resp).Encode(body)
+}
+
+// splitValues returns the comma-separated values of a parameter
+func splitValues(params []string) []string {
+	var values []string
+	for _, param := range params {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
@@ -2,19 +2,56 @@ package broadcaster
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"time"
 
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
+	"github.com/Octops/agones-event-broadcaster/pkg/checkpoint"
 	"github.com/Octops/agones-event-broadcaster/pkg/controller"
+	"github.com/Octops/agones-event-broadcaster/pkg/enrichment"
 	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/filter"
 	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
+	"github.com/Octops/agones-event-broadcaster/pkg/projection"
+	"github.com/Octops/agones-event-broadcaster/pkg/rpc"
 	"github.com/Octops/agones-event-broadcaster/pkg/runtime/log"
+	"github.com/Octops/agones-event-broadcaster/pkg/sharding"
+	"github.com/Octops/agones-event-broadcaster/pkg/stream"
+	"github.com/Octops/agones-event-broadcaster/pkg/tracing"
+	"github.com/Octops/agones-event-broadcaster/pkg/transform"
+)
+
+// StartupMode decides how the resources that existed when the broadcaster started are published
+type StartupMode string
+
+const (
+	// STARTUP_MODE_ADDED publishes an added event for every resource that existed when the broadcaster started
+	STARTUP_MODE_ADDED StartupMode = "added"
+	// STARTUP_MODE_SNAPSHOT publishes a snapshot event for every resource that existed when the broadcaster started,
+	// followed by a snapshot.events.completed event for each kind of resource
+	STARTUP_MODE_SNAPSHOT StartupMode = "snapshot"
+	// STARTUP_MODE_SKIP publishes only the changes that happened after the broadcaster started
+	STARTUP_MODE_SKIP StartupMode = "skip"
+	// STARTUP_MODE_RESUME publishes the changes that happened while the broadcaster was not running,
+	// comparing the resources with the versions saved on the checkpoint
+	STARTUP_MODE_RESUME StartupMode = "resume"
 )
 
 // Broadcaster receives events (Add, Update and Delete) sent by the controller
@@ -23,15 +60,89 @@ type Broadcaster struct {
 	logger      *logrus.Entry
 	controllers []*controller.AgonesController
 	brokers.Broker
-	error   error
-	Manager *manager.Manager
+	error       error
+	Manager     *manager.Manager
+	clusterName string
+	instance    string
+	sequencer   *sequencer
+	projector   *projection.Projector
+	transformer *transform.Transformer
+	filter      *filter.Filter
+	routes      []*route
+	watchers    []*watcher
+	enricher    *enrichment.Enricher
+	sharder     *sharding.Sharder
+	config      *Config
+	restConfig  *rest.Config
+	clusters    []Cluster
+	children    []*Broadcaster
+	status      *clusterStatus
+	initial     *initialList
+	tracker     *checkpoint.Tracker
+	stream      *stream.StreamBroker
+	rpc         *rpc.RPCBroker
+	gate        *gate
+}
+
+// route is a broker and the filter that decides which events it publishes.
+// name identifies the broker on filters, metrics and replays. It is unique across the routes of the broadcaster.
+type route struct {
+	broker brokers.Broker
+	filter *filter.Filter
+	name   string
 }
 
+// Config holds the broadcaster settings.
+// ClusterName and Instance identify where events come from. Instance defaults to the hostname.
+// Projection is applied to resources before envelopes are built. Defaults to projection.DefaultConfig().
+// Templates render custom message bodies for particular event types.
+// Filter is a CEL expression evaluated against every event. BrokerFilter only applies to the broker passed to New.
+// BrokerName is the name of the broker passed to New. Defaults to its type, e.g. pubsub.
+// Events are published only when the expressions evaluate to true. Empty expressions match every event.
+// Namespaces restricts every watcher to resources deployed on these namespaces. All namespaces are watched if empty.
+// Enrichment attaches node and pod information to GameServer events. Disabled if nil.
+// LeaderElection makes only the elected replica publish events. Other replicas keep a warm cache. Disabled if nil.
+// Sharding splits resources across replicas so each event is published by the replica that owns the resource. Disabled if nil.
+// The Identity of the sharding defaults to Instance. It names the checkpoint of the replica, so it must be stable across restarts.
+// MaxRetries is the number of times an event that failed to be published is retried. Defaults to controller.DEFAULT_MAX_RETRIES.
+// RetryTimeout is how long an event that failed to be published is retried. Defaults to controller.DEFAULT_RETRY_TIMEOUT.
+// MaxPending is the maximum number of events waiting to be retried, per watcher. Defaults to controller.DEFAULT_MAX_PENDING.
+// DeletingEvents publishes a *.events.deleting event, in addition to the update event, when the DeletionTimestamp of a resource is set.
+// ServerPort is the port of the health, readiness and debug endpoints. The endpoints are not served if zero.
+// Profiling serves the Go profiles on ServerPort. Disabled by default since the endpoints are not authenticated.
+// StartupMode decides how the resources that existed when the broadcaster started are published. Defaults to STARTUP_MODE_ADDED.
+// Checkpoint is where the versions of the published resources are saved. Required by STARTUP_MODE_RESUME.
+// Stream streams the published events to the clients of the server on ServerPort. Disabled if nil.
+// RPC serves the published events and the resources on the caches over gRPC. Disabled if nil.
+// Pause decides what happens to the events published while publishing is paused. Defaults to buffering them in memory.
+// Admin serves the endpoints that pause and resume publishing and replay the resources on the caches on ServerPort.
 type Config struct {
 	SyncPeriod             time.Duration
 	ServerPort             int
 	MetricsBindAddress     string
 	MaxConcurrentReconcile int
+	ClusterName            string
+	Instance               string
+	Projection             *projection.Config
+	Templates              []transform.Template
+	Filter                 string
+	BrokerFilter           string
+	BrokerName             string
+	Namespaces             []string
+	Enrichment             *enrichment.Config
+	DeletingEvents         bool
+	MaxRetries             int
+	RetryTimeout           time.Duration
+	MaxPending             int
+	LeaderElection         *manager.LeaderElection
+	Sharding               *sharding.Config
+	StartupMode            StartupMode
+	Checkpoint             *checkpoint.Config
+	Stream                 *stream.Config
+	RPC                    *rpc.Config
+	Pause                  *PauseConfig
+	Admin                  bool
+	Profiling              bool
 }
 
 // New returns a new GameServer broadcaster
@@ -40,77 +151,362 @@ type Config struct {
 func New(clientConfig *rest.Config, broker brokers.Broker, config *Config) *Broadcaster {
 	logger := log.NewLoggerWithField("source", "broadcaster")
 
+	instance := config.Instance
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+
 	broadcaster := &Broadcaster{
-		logger: logger,
-		Broker: broker,
+		logger:      logger,
+		Broker:      broker,
+		clusterName: config.ClusterName,
+		instance:    instance,
+		sequencer:   newSequencer(),
+		status:      &clusterStatus{},
+		initial:     &initialList{},
+		config:      config,
+		restConfig:  clientConfig,
 	}
 
-	mgr, err := manager.New(clientConfig, manager.Options{
-		SyncPeriod:             &config.SyncPeriod,
-		ServerPort:             config.ServerPort,
-		MetricsBindAddress:     config.MetricsBindAddress,
-		MaxConcurrentReconcile: config.MaxConcurrentReconcile,
-	})
+	switch config.StartupMode {
+	case "", STARTUP_MODE_ADDED, STARTUP_MODE_SNAPSHOT, STARTUP_MODE_SKIP:
+	case STARTUP_MODE_RESUME:
+		if config.Checkpoint == nil || (config.Checkpoint.Dir == "") == (config.Checkpoint.ConfigMap == "") {
+			broadcaster.error = errors.New("resume startup mode requires the checkpoint directory or configmap to be set")
+			return broadcaster
+		}
+	default:
+		broadcaster.error = errors.Errorf("invalid startup mode %q, valid modes are %s, %s, %s and %s", config.StartupMode, STARTUP_MODE_ADDED, STARTUP_MODE_SNAPSHOT, STARTUP_MODE_SKIP, STARTUP_MODE_RESUME)
+		return broadcaster
+	}
 
+	projectionConfig := config.Projection
+	if projectionConfig == nil {
+		projectionConfig = projection.DefaultConfig()
+	}
+
+	projector, err := projection.New(projectionConfig, manager.Scheme)
 	if err != nil {
-		broadcaster.error = errors.Wrap(err, "error creating manager")
+		broadcaster.error = errors.Wrap(err, "error creating projector")
+		return broadcaster
+	}
+	broadcaster.projector = projector
+
+	transformer, err := transform.New(config.Templates)
+	if err != nil {
+		broadcaster.error = errors.Wrap(err, "error creating transformer")
+		return broadcaster
+	}
+	broadcaster.transformer = transformer
+
+	if broadcaster.filter, err = filter.New(config.Filter); err != nil {
+		broadcaster.error = errors.Wrap(err, "error creating filter")
 		return broadcaster
 	}
 
-	broadcaster.Manager = mgr
+	if broadcaster.gate, err = newGate(logger, config.Pause); err != nil {
+		broadcaster.error = errors.Wrap(err, "error creating pause buffer")
+		return broadcaster
+	}
+
+	if broker != nil {
+		name := config.BrokerName
+		if name == "" {
+			name = broadcaster.routeName(broker)
+		}
+		broadcaster.WithNamedBroker(name, broker, config.BrokerFilter)
+	}
 
 	return broadcaster
 }
 
+// WithBroker adds a broker that publishes the events matching the filter expression.
+// Every event that passes the global filter is published by all brokers which filters match the event.
+// The broker is named after its type, e.g. pubsub. Brokers of the same type are named pubsub-2, pubsub-3 and so on.
+func (b *Broadcaster) WithBroker(broker brokers.Broker, expression string) *Broadcaster {
+	return b.WithNamedBroker(b.routeName(broker), broker, expression)
+}
+
+// WithNamedBroker adds a broker named name that publishes the events matching the filter expression.
+// Names identify brokers on metrics and replays and must be unique.
+func (b *Broadcaster) WithNamedBroker(name string, broker brokers.Broker, expression string) *Broadcaster {
+	if b.error != nil {
+		return b
+	}
+
+	if b.hasBroker(name) {
+		b.error = errors.Errorf("broker %q already exists", name)
+		return b
+	}
+
+	brokerFilter, err := filter.New(expression)
+	if err != nil {
+		b.error = errors.Wrapf(err, "error creating filter for broker %s", name)
+		return b
+	}
+
+	if b.Broker == nil {
+		b.Broker = broker
+	}
+
+	b.routes = append(b.routes, &route{
+		broker: broker,
+		filter: brokerFilter,
+		name:   name,
+	})
+
+	return b
+}
+
+// routeName returns the name of the type of the broker, followed by a number if a broker of the same type exists
+func (b *Broadcaster) routeName(broker brokers.Broker) string {
+	name := brokerName(broker)
+	for i := 2; b.hasBroker(name); i++ {
+		name = fmt.Sprintf("%s-%d", brokerName(broker), i)
+	}
+
+	return name
+}
+
 // WithWatcherFor adds a controller for the specified obj. The controller reports back to the broadcaster events of type
 // OnAdd, OnUpdate and OnDelete associated to that particular resource type.
 // Examples of obj arguments are: &v1.GameServer and &v1.Fleet
-func (b *Broadcaster) WithWatcherFor(obj client.Object) *Broadcaster {
+// Options restrict the resources watched, e.g. WithLabelSelector("mode=ranked"). Controllers are created by Build.
+func (b *Broadcaster) WithWatcherFor(obj client.Object, options ...WatcherOption) *Broadcaster {
 	if b.error != nil {
 		return b
 	}
 
-	ctrlFor, err := controller.NewAgonesController(b.Manager, b, controller.Options{
-		For:  obj,
-		Owns: &corev1.Pod{},
-	})
-
+	w, err := newWatcher(obj, options...)
 	if err != nil {
-		return nil
+		b.error = err
+		return b
 	}
 
-	b.addController(ctrlFor)
+	b.watchers = append(b.watchers, w)
 
 	return b
 }
 
-// Build will check for required broadcaster components e return error if the requirements are not satisfied
+// Build creates the manager and the controllers for the resources added using WithWatcherFor.
+// When broadcasting from several clusters, a manager is created for each cluster. The Manager field is not set in that case.
+// It returns error if the requirements are not satisfied
 func (b *Broadcaster) Build() error {
-	if b.Manager == nil {
-		b.error = errors.Wrap(b.error, "broadcaster requires a manager to operate")
+	if b.error != nil {
+		return b.error
 	}
 
-	if len(b.controllers) == 0 {
-		b.error = errors.Wrap(b.error, "can't build a broadcaster without controllers, use WithController method to add a controller")
+	if len(b.watchers) == 0 {
+		return errors.New("can't build a broadcaster without controllers, use WithWatcherFor method to add a controller")
 	}
 
-	if b.error != nil {
-		return b.error
+	if b.config.Enrichment != nil && !b.watches(&corev1.Pod{}) {
+		// Only GameServer pods are read when enriching events
+		pods, err := newWatcher(&corev1.Pod{}, WithLabelSelector(v1.GameServerPodLabel))
+		if err != nil {
+			return err
+		}
+		pods.cacheOnly = true
+		b.watchers = append(b.watchers, pods)
+	}
+
+	b.addRPC()
+	b.addStream()
+
+	if len(b.clusters) == 0 {
+		return b.build()
+	}
+
+	b.children = nil
+	for i, cluster := range b.clusters {
+		// Every manager shares the same metrics registry, served by the manager of the first cluster
+		metricsBindAddress := "0"
+		if i == 0 {
+			metricsBindAddress = b.config.MetricsBindAddress
+		}
+
+		child := b.forCluster(cluster, metricsBindAddress)
+		if err := child.build(); err != nil {
+			return errors.Wrapf(err, "error building broadcaster for cluster %s", cluster.Name)
+		}
+		b.children = append(b.children, child)
+	}
+
+	return nil
+}
+
+// build creates the manager of the cluster and the controllers of the watchers
+func (b *Broadcaster) build() error {
+	mgr, err := manager.New(b.restConfig, manager.Options{
+		SyncPeriod:             &b.config.SyncPeriod,
+		MetricsBindAddress:     b.config.MetricsBindAddress,
+		MaxConcurrentReconcile: b.config.MaxConcurrentReconcile,
+		Namespaces:             namespaceConfig(b.config.Namespaces),
+		ByObject:               byObject(b.watchers),
+		LeaderElection:         b.config.LeaderElection,
+		ClusterName:            b.clusterName,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error creating manager")
+	}
+
+	b.Manager = mgr
+	b.controllers = nil
+	b.resetInitialList()
+
+	if b.config.Enrichment != nil {
+		if b.enricher, err = enrichment.New(b.config.Enrichment, mgr.GetClient()); err != nil {
+			return errors.Wrap(err, "error creating enricher")
+		}
+	}
+
+	if b.config.Sharding != nil {
+		if b.config.LeaderElection != nil {
+			return errors.New("sharding and leader election can't be enabled at the same time")
+		}
+
+		rebalancer := newRebalancer(b, mgr)
+		shardingConfig := *b.config.Sharding
+		shardingConfig.ClusterName = b.clusterName
+		shardingConfig.Identity = b.shardIdentity()
+		shardingConfig.OnChange = rebalancer.changed
+
+		// Leases are read from the API server so the cache doesn't watch every Lease of the cluster.
+		// The sharder is started before the manager, see startManager.
+		if b.sharder, err = sharding.New(&shardingConfig, mgr.GetClient(), mgr.GetAPIReader()); err != nil {
+			return errors.Wrap(err, "error creating sharder")
+		}
+
+		if err := mgr.Add(rebalancer); err != nil {
+			return errors.Wrap(err, "error adding rebalancer")
+		}
+	}
+
+	for _, w := range b.watchers {
+		if w.cacheOnly {
+			continue
+		}
+
+		var handler handlers.EventHandler = b
+		if w.newHandler != nil {
+			if handler, err = w.newHandler(b, mgr); err != nil {
+				return errors.Wrap(err, "error creating handler")
+			}
+		}
+
+		ctrlFor, err := controller.NewAgonesController(b.Manager, handler, controller.Options{
+			Cluster:            b.clusterName,
+			For:                w.obj,
+			Owns:               &corev1.Pod{},
+			MaxRetries:         b.config.MaxRetries,
+			RetryTimeout:       b.config.RetryTimeout,
+			MaxPending:         b.config.MaxPending,
+			OnPermanentFailure: b.dropped(w.obj),
+		})
+		if err != nil {
+			return errors.Wrap(err, "error creating controller")
+		}
+
+		b.addController(ctrlFor)
+	}
+
+	if err := mgr.Add(&initialLister{broadcaster: b, mgr: mgr}); err != nil {
+		return errors.Wrap(err, "error adding initial lister")
+	}
+
+	if b.config.StartupMode == STARTUP_MODE_SNAPSHOT {
+		if err := mgr.Add(&snapshotter{broadcaster: b, mgr: mgr}); err != nil {
+			return errors.Wrap(err, "error adding snapshotter")
+		}
+	}
+
+	if b.config.StartupMode == STARTUP_MODE_RESUME {
+		if err := b.addResumer(mgr); err != nil {
+			return errors.Wrap(err, "error adding resumer")
+		}
+	}
+
+	if b.config.LeaderElection != nil {
+		// Informers are started with the cache, before the election, so replicas on standby have a warm cache
+		for _, w := range b.watchers {
+			if _, err := mgr.GetCache().GetInformer(context.Background(), w.obj, cache.BlockUntilSynced(false)); err != nil {
+				return errors.Wrap(err, "error creating informer")
+			}
+		}
 	}
 
 	return nil
 }
 
-// Start run the controller that sends events back to the broadcaster event handlers
+// Start run the controller that sends events back to the broadcaster event handlers.
+// When broadcasting from several clusters, each cluster runs on its own and is restarted if it fails.
+// The health, readiness and debug endpoints are served on ServerPort, if set, and the gRPC API if RPC is set.
 func (b *Broadcaster) Start(ctx context.Context) error {
 	b.logger.Info("starting broadcaster")
-	if err := b.Manager.Start(ctx); err != nil {
+	if b.config.ServerPort > 0 {
+		// The process is stopped if the endpoints can't be served, so the liveness and readiness probes don't fail
+		// with no explanation
+		go func() {
+			if err := b.Server(fmt.Sprintf(":%d", b.config.ServerPort)).Start(ctx); err != nil {
+				b.logger.Fatal(errors.Wrap(err, "error serving health, readiness and debug endpoints"))
+			}
+		}()
+	}
+
+	if b.rpc != nil {
+		go func() {
+			if err := b.rpc.Start(ctx); err != nil {
+				b.logger.WithError(err).Error("error serving gRPC API")
+			}
+		}()
+	}
+
+	go b.drainLeftover(ctx)
+
+	if len(b.children) > 0 {
+		b.startClusters(ctx)
+		return nil
+	}
+
+	go b.status.watch(ctx, b.clusterName, b.Manager)
+	if err := b.startManager(ctx); err != nil {
 		b.logger.Fatal(errors.Wrap(err, "broadcaster could not start"))
 	}
 
 	return nil
 }
 
+// startManager starts the manager of the cluster until ctx is cancelled. When sharding is enabled, the sharder runs
+// alongside the manager, which is started once the members are synced so controllers don't handle events before the
+// replica knows the resources assigned to it.
+func (b *Broadcaster) startManager(ctx context.Context) error {
+	if b.sharder == nil {
+		return b.Manager.Start(ctx)
+	}
+
+	shardingCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		b.sharder.Start(shardingCtx)
+	}()
+
+	// The Lease of the replica is released once the manager stops
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	b.logger.Info("waiting for sharding members to be synced")
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-b.sharder.Synced():
+	}
+
+	return b.Manager.Start(ctx)
+}
+
 // OnAdd is the event handler that reacts to Add events
 func (b *Broadcaster) OnAdd(obj interface{}) error {
 	if b.Broker == nil {
@@ -118,13 +514,27 @@ func (b *Broadcaster) OnAdd(obj interface{}) error {
 		return nil
 	}
 
-	message := &events.EventMessage{
-		Body: obj,
+	resource, err := toObject(obj)
+	if err != nil {
+		return err
 	}
 
-	event := events.OnAdded(message)
+	// OnAdd is also triggered for every resource listed when the cache is synced
+	if b.config.StartupMode != "" && b.config.StartupMode != STARTUP_MODE_ADDED && b.existed(resource) {
+		return nil
+	}
+
+	return b.add(resource)
+}
+
+// add publishes the added event of the resource
+func (b *Broadcaster) add(resource runtime.Object) error {
+	message := &events.AddedMessage{
+		Obj:  resource,
+		Meta: b.metadataFor(resource, false),
+	}
 
-	return b.Publish(event)
+	return b.dispatch(&delivery{event: events.OnAdded(message), project: b.projectEvent, resource: resource})
 }
 
 // OnUpdate is the event handler that reacts to Update events
@@ -134,55 +544,579 @@ func (b *Broadcaster) OnUpdate(oldObj interface{}, newObj interface{}) error {
 		return nil
 	}
 
-	body := struct {
-		OldObj interface{} `json:"old_obj"`
-		NewObj interface{} `json:"new_obj"`
-	}{
-		OldObj: oldObj,
-		NewObj: newObj,
+	oldResource, err := toObject(oldObj)
+	if err != nil {
+		return err
+	}
+
+	newResource, err := toObject(newObj)
+	if err != nil {
+		return err
+	}
+
+	message := &events.UpdatedMessage{
+		Old:  oldResource,
+		New:  newResource,
+		Meta: b.metadataFor(newResource, false),
+	}
+
+	err = b.dispatch(&delivery{event: events.OnUpdated(message), project: b.projectEvent, resource: newResource})
+	if !b.config.DeletingEvents || !deletionRequested(oldResource, newResource) {
+		return err
+	}
+
+	deleting := &events.UpdatedMessage{
+		Old:  oldResource,
+		New:  newResource,
+		Meta: b.metadataFor(newResource, false),
+	}
+	deletingEvent := &delivery{event: events.OnDeleting(deleting), project: b.projectEvent}
+
+	// The deleting event is kept as a separate event, published once the update event is, so retries don't publish
+	// the update event again or build the deleting event with a new id
+	if failed, ok := handlers.FailedEvents(err); ok {
+		waiting := b.failedEvent(deletingEvent, nil, errors.New("waiting for the update event to be published"))
+		return &handlers.PublishError{Events: append(failed, waiting)}
+	}
+	if err != nil && !errors.Is(err, handlers.ErrDropped) {
+		return err
+	}
+
+	if deletingErr := b.dispatch(deletingEvent); deletingErr != nil {
+		return deletingErr
 	}
 
-	message := &events.EventMessage{
-		Body: body,
+	return err
+}
+
+// deletionRequested returns true when the DeletionTimestamp of the resource is set for the first time
+func deletionRequested(oldObj, newObj runtime.Object) bool {
+	oldAccessor, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
 	}
 
-	event := events.OnUpdated(message)
+	newAccessor, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
 
-	return b.Publish(event)
+	return oldAccessor.GetDeletionTimestamp() == nil && newAccessor.GetDeletionTimestamp() != nil
 }
 
 // OnDelete is the event handler that reacts to Delete events
 func (b *Broadcaster) OnDelete(obj interface{}) error {
+	return b.OnDeleteWithState(obj, false)
+}
+
+// OnDeleteWithState is the event handler that reacts to Delete events. finalStateUnknown is set on the event metadata
+// when the deletion was missed and obj is the last state known by the broadcaster.
+func (b *Broadcaster) OnDeleteWithState(obj interface{}, finalStateUnknown bool) error {
 	if b.Broker == nil {
 		b.logger.Warn("a broker is not available for the broadcaster, message will not be published")
 		return nil
 	}
 
-	message := &events.EventMessage{
-		Body: obj,
+	resource, err := toObject(obj)
+	if err != nil {
+		return err
 	}
 
-	event := events.OnDeleted(message)
+	message := &events.DeletedMessage{
+		Obj:  resource,
+		Meta: b.metadataFor(resource, true),
+	}
+	message.Meta.FinalStateUnknown = finalStateUnknown
 
-	return b.Publish(event)
+	return b.dispatch(&delivery{event: events.OnDeleted(message), project: b.projectEvent, resource: resource})
 }
 
-// Publish will publish the event wrapped on a envelope using the broker available
+// Publish will publish the event wrapped on a envelope using the brokers which filters match the event
 func (b *Broadcaster) Publish(event events.Event) error {
-	envelope, err := b.Broker.BuildEnvelope(event)
+	return b.dispatch(&delivery{event: event})
+}
+
+// delivery is an event being published. project returns a copy of the event with the projection applied to its resources.
+// brokers restricts the brokers the event is published to, every broker matching the event if empty.
+// resource, if set, is recorded on the checkpoint once the event is published, or forgotten for deleted events.
+// retry is set when the event is published again after failing, so it is not counted as received or failed twice.
+type delivery struct {
+	event    events.Event
+	project  func(event events.Event) (events.Event, error)
+	brokers  []string
+	resource runtime.Object
+	retry    bool
+}
+
+// dispatch publishes the event using the matching brokers. While publishing is paused, the event is held by the gate
+// and published once resumed. Events dropped by the gate return handlers.ErrDropped.
+func (b *Broadcaster) dispatch(d *delivery) error {
+	if d.event == nil {
+		return b.send(context.Background(), d)
+	}
+
+	held, err := b.gate.hold(&pending{cluster: b.clusterName, brokers: d.brokers, event: d.event, project: d.project, resource: d.resource})
+	if held {
+		return err
+	}
+
+	return b.send(context.Background(), d)
+}
+
+// shardIdentity returns the identity of the replica among the replicas sharing the resources. It must be stable across
+// restarts, e.g. the name of a StatefulSet pod, so replicas find their checkpoint. Defaults to Instance.
+func (b *Broadcaster) shardIdentity() string {
+	if b.config.Sharding != nil && b.config.Sharding.Identity != "" {
+		return b.config.Sharding.Identity
+	}
+
+	return b.instance
+}
+
+// checkpoint records the resource of the delivery on the checkpoint once its event is published. Resources of deleted
+// events are forgotten even if the event is not published, e.g. filtered, so they are not deleted again on the next start.
+func (b *Broadcaster) checkpoint(d *delivery, published bool) {
+	if b.tracker == nil || d.resource == nil {
+		return
+	}
+
+	if d.event.EventSource() == events.EventSourceOnDelete {
+		b.tracker.Forget(d.resource)
+		return
+	}
+
+	if published {
+		b.tracker.Record(d.resource)
+	}
+}
+
+// send evaluates the filters against the event and publishes it using every matching broker, even if some of them fail.
+// Failures are returned as a *handlers.PublishError so only the brokers that failed are retried, with the same event.
+// Filters are evaluated before project is called so expressions have access to every field of the resource.
+// Every step is traced by a child of the span of the receipt of the event.
+func (b *Broadcaster) send(ctx context.Context, d *delivery) (err error) {
+	if d.event == nil {
+		b.logger.Warn("no event factory registered for the resource type, message will not be published")
+		return nil
+	}
+
+	kind, eventType := kindOf(d.event), d.event.EventType().String()
+	if !d.retry {
+		metrics.EventsReceived.WithLabelValues(b.clusterName, kind, eventType).Inc()
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "receive "+eventType, trace.WithAttributes(b.spanAttributes(d.event, kind)...))
+	defer func() { tracing.End(span, err) }()
+
+	// Changes are read before the projection, which may remove the managed fields
+	var changedAt time.Time
+	if d.project != nil {
+		changedAt = b.lastChange(d.event)
+	}
+
+	routes, err := b.route(ctx, d.event, kind, d.brokers)
 	if err != nil {
-		b.logger.WithError(err).Error("error building envelope")
+		b.countFailure(d, kind, eventType, metrics.ALL_BROKERS)
+		return b.failed(d, d.brokers, err)
+	}
+	if len(routes) == 0 {
+		b.checkpoint(d, false)
+		return nil
+	}
+
+	event := d.event
+	if err := b.prepare(ctx, &event, d.project); err != nil {
+		b.countFailure(d, kind, eventType, metrics.ALL_BROKERS)
+		return b.failed(d, routeNames(routes), err)
+	}
+
+	if err := b.publishTo(ctx, d, event, kind, routes); err != nil {
 		return err
 	}
 
-	if err = b.Broker.SendMessage(envelope); err != nil {
-		b.logger.WithError(err).Error("error sending envelope")
+	if !changedAt.IsZero() {
+		metrics.PublishLagSeconds.WithLabelValues(b.clusterName, kind, eventType).Observe(time.Since(changedAt).Seconds())
+	}
+
+	return nil
+}
+
+// countFailure counts the event as failed by the broker the first time it fails, and as retried afterwards
+func (b *Broadcaster) countFailure(d *delivery, kind, eventType, broker string) {
+	if d.retry {
+		metrics.EventsRetried.WithLabelValues(b.clusterName, kind, eventType, broker).Inc()
+		return
+	}
+
+	metrics.EventsFailed.WithLabelValues(b.clusterName, kind, eventType, broker).Inc()
+}
+
+// failed returns the error of an event that failed before being handed to the brokers
+func (b *Broadcaster) failed(d *delivery, brokers []string, err error) error {
+	return &handlers.PublishError{Events: []*handlers.FailedEvent{b.failedEvent(d, brokers, err)}}
+}
+
+// failedEvent returns the event of the delivery as failed to be published by brokers, or every broker if empty.
+// Retries evaluate the filters and apply the projection again to the same event.
+func (b *Broadcaster) failedEvent(d *delivery, brokers []string, err error) *handlers.FailedEvent {
+	return &handlers.FailedEvent{
+		Event:   d.event,
+		Brokers: brokers,
+		Err:     err,
+		Publish: func(brokers []string) error {
+			retried := *d
+			retried.brokers, retried.retry = brokers, true
+			return b.send(context.Background(), &retried)
+		},
+	}
+}
+
+// publishTo publishes the prepared event of the delivery using every route. It returns a *handlers.PublishError with the
+// routes that failed, which retries publish the same envelope content again without evaluating the filters.
+// The resource of the delivery is recorded on the checkpoint once every route published the event.
+func (b *Broadcaster) publishTo(ctx context.Context, d *delivery, event events.Event, kind string, routes []*route) error {
+	eventType := event.EventType().String()
+
+	var failed, messages []string
+	for _, r := range routes {
+		if d.retry {
+			metrics.EventsRetried.WithLabelValues(b.clusterName, kind, eventType, r.name).Inc()
+		}
+
+		if err := r.publish(ctx, event); err != nil {
+			b.logger.WithError(err).WithField("broker", r.name).Error("error publishing event")
+			if !d.retry {
+				metrics.EventsFailed.WithLabelValues(b.clusterName, kind, eventType, r.name).Inc()
+			}
+			failed = append(failed, r.name)
+			messages = append(messages, fmt.Sprintf("%s: %v", r.name, err))
+			continue
+		}
+		metrics.EventsPublished.WithLabelValues(b.clusterName, kind, eventType, r.name).Inc()
+	}
+
+	if len(failed) == 0 {
+		b.checkpoint(d, true)
+		return nil
+	}
+
+	return &handlers.PublishError{Events: []*handlers.FailedEvent{{
+		Event:   event,
+		Brokers: failed,
+		Err:     errors.New(strings.Join(messages, "; ")),
+		Publish: func(brokers []string) (err error) {
+			ctx, span := tracing.Tracer().Start(context.Background(), "retry "+eventType, trace.WithAttributes(b.spanAttributes(event, kind)...))
+			defer func() { tracing.End(span, err) }()
+
+			retried := *d
+			retried.retry = true
+			return b.publishTo(ctx, &retried, event, kind, b.routesNamed(brokers))
+		},
+	}}}
+}
+
+// route returns the routes publishing the event, traced by the filter span. Only the routes named brokers are returned,
+// or every route if empty. It is empty when the resource of the event is not assigned to the broadcaster instance
+// or no filter matches the event.
+func (b *Broadcaster) route(ctx context.Context, event events.Event, kind string, brokers []string) (routes []*route, err error) {
+	_, span := tracing.Tracer().Start(ctx, "filter")
+	defer func() {
+		span.SetAttributes(attribute.Int("broadcaster.brokers", len(routes)))
+		tracing.End(span, err)
+	}()
+
+	owned, err := b.owns(event)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		metrics.EventsFiltered.WithLabelValues(b.clusterName, kind, event.EventType().String(), metrics.ALL_BROKERS).Inc()
+		return nil, nil
+	}
+
+	return b.match(event, kind, brokers), nil
+}
+
+// routesNamed returns the routes named brokers, or every route if empty
+func (b *Broadcaster) routesNamed(brokers []string) []*route {
+	if len(brokers) == 0 {
+		return b.routes
+	}
+
+	var routes []*route
+	for _, r := range b.routes {
+		if contains(brokers, r.name) {
+			routes = append(routes, r)
+		}
+	}
+
+	return routes
+}
+
+func routeNames(routes []*route) []string {
+	names := make([]string, 0, len(routes))
+	for _, r := range routes {
+		names = append(names, r.name)
+	}
+
+	return names
+}
+
+// prepare projects, transforms and enriches the event before it is handed to the brokers. The event is replaced by the
+// prepared one, the original event is not modified so retries evaluate the filters against it again.
+func (b *Broadcaster) prepare(ctx context.Context, event *events.Event, project func(events.Event) (events.Event, error)) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "prepare")
+	defer func() { tracing.End(span, err) }()
+
+	// Resources are enriched before they are projected, since field masks may remove the node of GameServers
+	var enriched *enrichment.Enrichment
+	if b.enricher != nil {
+		if message, ok := (*event).(events.Message); ok {
+			if obj, ok := events.ResourceObject(message); ok {
+				if enriched, err = b.enricher.Lookup(ctx, obj); err != nil {
+					b.logger.WithError(err).Error("error enriching event")
+					return err
+				}
+			}
+		}
+	}
+
+	if project != nil {
+		if *event, err = project(*event); err != nil {
+			return err
+		}
+	}
+
+	if b.transformer != nil {
+		transformed, err := b.transformer.Transform(*event)
+		if err != nil {
+			b.logger.WithError(err).Error("error transforming event")
+			return err
+		}
+		*event = transformed
+	}
+
+	*event = enrichment.Attach(*event, enriched)
+
+	return nil
+}
+
+// publish builds the envelope of the event and sends it using the broker of the route.
+// The trace context of the send span is added to the envelope so consumers of the message continue the trace.
+func (r *route) publish(ctx context.Context, event events.Event) error {
+	envelope, err := r.build(ctx, event)
+	if err != nil {
 		return err
 	}
 
+	return r.send(ctx, envelope)
+}
+
+// build builds the envelope of the event, traced by the build envelope span
+func (r *route) build(ctx context.Context, event events.Event) (envelope *events.Envelope, err error) {
+	_, span := tracing.Tracer().Start(ctx, "build envelope", trace.WithAttributes(attribute.String("messaging.system", r.name)))
+	defer func() { tracing.End(span, err) }()
+
+	started := time.Now()
+	envelope, err = r.broker.BuildEnvelope(event)
+	if err != nil {
+		return nil, errors.Wrap(err, "error building envelope")
+	}
+	metrics.EnvelopeBuildSeconds.WithLabelValues(r.name).Observe(time.Since(started).Seconds())
+
+	return envelope, nil
+}
+
+// send sends the envelope using the broker, traced by the send span
+func (r *route) send(ctx context.Context, envelope *events.Envelope) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "send "+r.name, trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.system", r.name)))
+	defer func() { tracing.End(span, err) }()
+
+	tracing.Inject(ctx, envelope)
+
+	inFlight := metrics.InFlightSends.WithLabelValues(r.name)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	started := time.Now()
+	if err := r.broker.SendMessage(envelope); err != nil {
+		return errors.Wrap(err, "error sending envelope")
+	}
+	metrics.SendSeconds.WithLabelValues(r.name).Observe(time.Since(started).Seconds())
+
 	return nil
 }
 
+// owns returns true if the resource of the event is assigned to the broadcaster instance or sharding is disabled.
+// It returns an error when the members are not in sync so the event is retried.
+func (b *Broadcaster) owns(event events.Event) (bool, error) {
+	if b.sharder == nil {
+		return true, nil
+	}
+
+	message, ok := event.(events.Message)
+	if !ok {
+		return true, nil
+	}
+
+	obj, ok := events.ResourceObject(message)
+	if !ok {
+		return true, nil
+	}
+
+	owned, err := b.sharder.Owns(obj)
+	if err != nil {
+		return false, errors.Wrap(err, "error deciding the owner of the resource")
+	}
+
+	return owned, nil
+}
+
+// match returns the routes named brokers, or every route if empty, which filters match the event.
+// The resources of the event are encoded once and shared by the global filter and the filters of the brokers.
+// Events failing the evaluation of a filter are not published since evaluating it again would fail the same way.
+// Evaluation errors are logged and counted by the filter errors metric, except for expressions accessing fields
+// that are not set, which don't match the event.
+func (b *Broadcaster) match(event events.Event, kind string, brokers []string) []*route {
+	eventType := event.EventType().String()
+	logger := b.logger.WithField("event_type", eventType)
+	activation := filter.NewActivation(event)
+
+	ok, err := b.filter.Eval(activation)
+	if err != nil && !errors.Is(err, filter.ErrMissingField) {
+		logger.WithError(err).Error("error evaluating filter, message will not be published")
+		metrics.FilterErrors.WithLabelValues(b.clusterName, kind, eventType, metrics.ALL_BROKERS).Inc()
+		metrics.EventsFiltered.WithLabelValues(b.clusterName, kind, eventType, metrics.ALL_BROKERS).Inc()
+		return nil
+	}
+
+	if !ok {
+		logger.WithError(err).Debug("event does not match the filter, message will not be published")
+		metrics.EventsFiltered.WithLabelValues(b.clusterName, kind, eventType, metrics.ALL_BROKERS).Inc()
+		return nil
+	}
+
+	var routes []*route
+	for _, r := range b.routes {
+		if len(brokers) > 0 && !contains(brokers, r.name) {
+			continue
+		}
+
+		ok, err := r.filter.Eval(activation)
+		switch {
+		case errors.Is(err, filter.ErrMissingField):
+			logger.WithError(err).Debugf("event does not match the filter of broker %s", r.name)
+		case err != nil:
+			logger.WithError(err).Errorf("error evaluating filter of broker %s, message will not be published", r.name)
+			metrics.FilterErrors.WithLabelValues(b.clusterName, kind, eventType, r.name).Inc()
+		}
+
+		if err != nil || !ok {
+			metrics.EventsFiltered.WithLabelValues(b.clusterName, kind, eventType, r.name).Inc()
+			continue
+		}
+
+		routes = append(routes, r)
+	}
+
+	return routes
+}
+
+// project applies the projection to the resource. Events are built from the original resource
+// before the projection since field masks turn the resource into an unstructured object.
+func (b *Broadcaster) project(obj runtime.Object) (runtime.Object, error) {
+	if b.projector == nil {
+		return obj, nil
+	}
+
+	projected, err := b.projector.Project(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "error projecting resource")
+	}
+
+	return projected, nil
+}
+
+// projectEvent returns a copy of the event with the projection applied to the resources of its added, updated or
+// deleted message. The event is not modified, so it keeps the original resources when it is retried.
+func (b *Broadcaster) projectEvent(event events.Event) (events.Event, error) {
+	message, ok := event.(events.Message)
+	if !ok || b.projector == nil {
+		return event, nil
+	}
+
+	switch content := message.Content().(type) {
+	case *events.UpdatedMessage:
+		oldResource, err := b.project(content.Old)
+		if err != nil {
+			return nil, err
+		}
+
+		newResource, err := b.project(content.New)
+		if err != nil {
+			return nil, err
+		}
+
+		projected := &events.UpdatedMessage{Old: oldResource, New: newResource, Meta: content.Meta}
+		return &projectedEvent{Event: event, message: message, content: projected, obj: newResource}, nil
+	case runtime.Object:
+		projected, err := b.project(content)
+		if err != nil {
+			return nil, err
+		}
+
+		return &projectedEvent{Event: event, message: message, content: projected, obj: projected}, nil
+	}
+
+	return event, nil
+}
+
+// projectedEvent is an event which content is the projection of the resources of the original event.
+// It keeps the source, type and metadata of the original event.
+type projectedEvent struct {
+	events.Event
+	message events.Message
+	content interface{}
+	obj     runtime.Object
+}
+
+// Content returns the projected content
+func (e *projectedEvent) Content() interface{} {
+	return e.content
+}
+
+// Metadata returns the metadata of the original event
+func (e *projectedEvent) Metadata() *events.Metadata {
+	return events.MetadataOf(e.message)
+}
+
+// Object returns the projected resource, the new version of the resource for update events
+func (e *projectedEvent) Object() runtime.Object {
+	return e.obj
+}
+
+// toObject returns obj as a runtime.Object. Event handlers only publish Kubernetes resources.
+func toObject(obj interface{}) (runtime.Object, error) {
+	resource, ok := obj.(runtime.Object)
+	if !ok {
+		return nil, errors.Errorf("%T is not a kubernetes resource", obj)
+	}
+
+	return resource, nil
+}
+
+// watches returns true if a watcher has been added for the type of obj
+func (b *Broadcaster) watches(obj client.Object) bool {
+	for _, w := range b.watchers {
+		if reflect.TypeOf(w.obj) == reflect.TypeOf(obj) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (b *Broadcaster) addController(controller *controller.AgonesController) {
 	b.controllers = append(b.controllers, controller)
 }
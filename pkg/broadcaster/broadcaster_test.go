@@ -0,0 +1,229 @@
+package broadcaster
+
+import (
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/enrichment"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
+	"github.com/Octops/agones-event-broadcaster/pkg/projection"
+	"github.com/Octops/agones-event-broadcaster/pkg/transform"
+)
+
+// failingRecorder is a broker that fails to publish the first failures events
+type failingRecorder struct {
+	recorder
+	failures int
+}
+
+func (r *failingRecorder) SendMessage(envelope *events.Envelope) error {
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("broker unavailable")
+	}
+
+	return r.recorder.SendMessage(envelope)
+}
+
+func Test_Broadcaster_Publish_FailedBrokers(t *testing.T) {
+	healthy := &recorder{}
+	failing := &failingRecorder{failures: 1}
+	b := New(nil, failing, &Config{BrokerName: "audit"}).WithBroker(healthy, "")
+	require.NoError(t, b.error)
+
+	event := events.OnAdded(&events.AddedMessage{Obj: newGameServerCreatedAt("ranked", time.Now())})
+
+	err := b.Publish(event)
+	require.Error(t, err)
+	require.Len(t, healthy.events, 1, "it should publish the event using the brokers after the failing one")
+	require.Empty(t, failing.events)
+
+	failed, ok := handlers.FailedEvents(err)
+	require.True(t, ok)
+	require.Len(t, failed, 1)
+	require.Equal(t, []string{"audit"}, failed[0].Brokers)
+	require.Equal(t, event, failed[0].Event)
+
+	require.NoError(t, handlers.Republish(failed))
+	require.Len(t, healthy.events, 1, "it should not publish the event again using the brokers that succeeded")
+	require.Len(t, failing.events, 1)
+	require.Equal(t, event, failing.events[0], "it should publish the same event")
+}
+
+func Test_Broadcaster_WithNamedBroker(t *testing.T) {
+	t.Run("it should name brokers of the same type after their type", func(t *testing.T) {
+		b := New(nil, &recorder{}, &Config{}).WithBroker(&recorder{}, "").WithBroker(&recorder{}, "")
+		require.NoError(t, b.error)
+		require.Equal(t, []string{"recorder", "recorder-2", "recorder-3"}, routeNames(b.routes))
+	})
+
+	t.Run("it should use the name of the broker passed to New", func(t *testing.T) {
+		b := New(nil, &recorder{}, &Config{BrokerName: "audit"}).WithBroker(&recorder{}, "")
+		require.NoError(t, b.error)
+		require.Equal(t, []string{"audit", "recorder"}, routeNames(b.routes))
+	})
+
+	t.Run("it should fail if the name is taken", func(t *testing.T) {
+		b := New(nil, &recorder{}, &Config{}).WithNamedBroker("recorder", &recorder{}, "")
+		require.EqualError(t, b.error, `broker "recorder" already exists`)
+	})
+
+	t.Run("it should only apply the filter to the named broker", func(t *testing.T) {
+		ranked, casual := &recorder{}, &recorder{}
+		b := New(nil, &recorder{}, &Config{}).
+			WithNamedBroker("ranked", ranked, `object.metadata.name == "ranked"`).
+			WithNamedBroker("casual", casual, `object.metadata.name == "casual"`)
+		require.NoError(t, b.error)
+
+		require.NoError(t, b.Publish(events.OnAdded(&events.AddedMessage{Obj: &v1.GameServer{}})))
+		require.Empty(t, ranked.events)
+		require.Empty(t, casual.events)
+
+		require.NoError(t, b.Publish(events.OnAdded(&events.AddedMessage{Obj: newGameServerCreatedAt("casual", time.Now())})))
+		require.Empty(t, ranked.events)
+		require.Len(t, casual.events, 1)
+	})
+}
+
+func Test_Broadcaster_Publish_MissingFields(t *testing.T) {
+	broker, audit := &recorder{}, &recorder{}
+	b := New(nil, broker, &Config{
+		ClusterName: "missing-fields",
+		Filter:      `object.metadata.labels["mode"] == "ranked"`,
+	}).WithNamedBroker("audit", audit, `object.metadata.labels["team"] == "platform"`)
+	require.NoError(t, b.error)
+
+	unlabeled := newGameServerCreatedAt("unlabeled", time.Now())
+	require.NoError(t, b.Publish(events.OnAdded(&events.AddedMessage{Obj: unlabeled})))
+
+	ranked := newGameServerCreatedAt("ranked", time.Now())
+	ranked.Labels = map[string]string{"mode": "ranked"}
+	require.NoError(t, b.Publish(events.OnAdded(&events.AddedMessage{Obj: ranked})))
+
+	require.Len(t, broker.events, 1, "it should not publish resources without the label")
+	require.Empty(t, audit.events)
+
+	eventType := events.GameServerEventAdded.String()
+	require.Zero(t, testutil.ToFloat64(metrics.FilterErrors.WithLabelValues("missing-fields", "GameServer", eventType, metrics.ALL_BROKERS)))
+	require.Zero(t, testutil.ToFloat64(metrics.FilterErrors.WithLabelValues("missing-fields", "GameServer", eventType, "audit")))
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.EventsFiltered.WithLabelValues("missing-fields", "GameServer", eventType, metrics.ALL_BROKERS)))
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.EventsFiltered.WithLabelValues("missing-fields", "GameServer", eventType, "audit")))
+}
+
+func Test_Broadcaster_OnUpdate_Deleting(t *testing.T) {
+	broker := &failingRecorder{failures: 1}
+	b := New(nil, broker, &Config{DeletingEvents: true})
+	require.NoError(t, b.error)
+
+	oldGS := newGameServerCreatedAt("ranked", time.Now())
+	newGS := oldGS.DeepCopy()
+	now := metav1.Now()
+	newGS.DeletionTimestamp = &now
+
+	err := b.OnUpdate(oldGS, newGS)
+	failed, ok := handlers.FailedEvents(err)
+	require.True(t, ok)
+	require.Len(t, failed, 2, "it should keep the update and deleting events as separate events")
+	require.Equal(t, events.EventType(events.GameServerEventUpdated), failed[0].Event.EventType())
+	require.Equal(t, []string{"failingrecorder"}, failed[0].Brokers)
+	require.Equal(t, events.EventType(events.GameServerEventDeleting), failed[1].Event.EventType())
+	require.Empty(t, broker.events, "it should not publish the deleting event before the update event")
+
+	require.NoError(t, handlers.Republish(failed))
+	require.Len(t, broker.events, 2)
+	require.Equal(t, failed[0].Event, broker.events[0], "it should publish the same update event")
+	require.Equal(t, events.EventType(events.GameServerEventDeleting), broker.events[1].EventType())
+}
+
+func Test_Broadcaster_projectEvent(t *testing.T) {
+	b := New(nil, &recorder{}, &Config{Projection: &projection.Config{Include: []string{"status.state"}}})
+	require.NoError(t, b.error)
+
+	t.Run("it should return a projected copy of added events", func(t *testing.T) {
+		gs := newGameServerCreatedAt("ranked", time.Now())
+		message := &events.AddedMessage{Obj: gs, Meta: &events.Metadata{ID: "event-1"}}
+		event := events.OnAdded(message)
+
+		projected, err := b.projectEvent(event)
+		require.NoError(t, err)
+		require.Same(t, gs, message.Obj, "it should not modify the original event")
+		require.Equal(t, event.EventType(), projected.EventType())
+		require.Equal(t, "event-1", events.MetadataOf(projected.(events.Message)).ID)
+
+		obj, ok := events.ResourceObject(projected.(events.Message))
+		require.True(t, ok)
+		require.IsType(t, &unstructured.Unstructured{}, obj)
+		require.Equal(t, obj, projected.(events.Message).Content())
+	})
+
+	t.Run("it should return a projected copy of updated events", func(t *testing.T) {
+		oldResource, newResource := newGameServerCreatedAt("ranked", time.Now()), newGameServerCreatedAt("ranked", time.Now())
+		message := &events.UpdatedMessage{Old: oldResource, New: newResource, Meta: &events.Metadata{ID: "event-2"}}
+
+		projected, err := b.projectEvent(events.OnUpdated(message))
+		require.NoError(t, err)
+		require.Same(t, oldResource, message.Old)
+		require.Same(t, newResource, message.New)
+
+		content, ok := projected.(events.Message).Content().(*events.UpdatedMessage)
+		require.True(t, ok)
+		require.IsType(t, &unstructured.Unstructured{}, content.Old)
+		require.IsType(t, &unstructured.Unstructured{}, content.New)
+		require.Equal(t, "event-2", events.MetadataOf(projected.(events.Message)).ID)
+	})
+}
+
+func Test_Broadcaster_OnAdd_RetryAfterProjection(t *testing.T) {
+	broker := &recorder{}
+	b := New(nil, broker, &Config{
+		Filter:     `object.metadata.labels["mode"] == "ranked"`,
+		Projection: &projection.Config{Include: []string{"status.state"}},
+		Templates:  []transform.Template{{EventType: events.GameServerEventAdded.String(), Template: "not json"}},
+	})
+	require.NoError(t, b.error)
+
+	gs := newGameServerCreatedAt("ranked", time.Now())
+	gs.Labels = map[string]string{"mode": "ranked"}
+
+	failed, ok := handlers.FailedEvents(b.OnAdd(gs))
+	require.True(t, ok, "it should fail to transform the projected event")
+	obj, _ := events.ResourceObject(failed[0].Event.(events.Message))
+	require.Same(t, gs, obj, "it should keep the original resource on the failed event")
+
+	err := handlers.Republish(failed)
+	require.Error(t, err, "it should evaluate the filter against the original resource and fail to transform it again")
+	require.Contains(t, err.Error(), "valid json")
+	require.Empty(t, broker.events)
+}
+
+func Test_Broadcaster_Publish_Enrichment(t *testing.T) {
+	broker := &recorder{}
+	b := New(nil, broker, &Config{Projection: &projection.Config{Include: []string{"status.state"}}})
+	require.NoError(t, b.error)
+
+	reader := fake.NewClientBuilder().WithScheme(manager.Scheme).WithObjects(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}).Build()
+	var err error
+	b.enricher, err = enrichment.New(enrichment.DefaultConfig(), reader)
+	require.NoError(t, err)
+
+	gs := newGameServerCreatedAt("ranked", time.Now())
+	gs.Status = v1.GameServerStatus{State: v1.GameServerStateReady, NodeName: "node-1"}
+	require.NoError(t, b.Publish(events.OnAdded(&events.AddedMessage{Obj: gs})))
+
+	require.Len(t, broker.events, 1)
+	enriched, ok := broker.events[0].(*enrichment.EnrichedEvent)
+	require.True(t, ok, "it should enrich the resource before the field masks remove its node")
+	require.Equal(t, "node-1", enriched.Enrichment().Node.Name)
+}
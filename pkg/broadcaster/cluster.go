@@ -0,0 +1,232 @@
+package broadcaster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
+	"github.com/Octops/agones-event-broadcaster/pkg/query"
+)
+
+const (
+	// CLUSTER_MIN_BACKOFF is the time waited before restarting a cluster that failed for the first time
+	CLUSTER_MIN_BACKOFF = 5 * time.Second
+	// CLUSTER_MAX_BACKOFF is the maximum time waited before restarting a cluster that keeps failing
+	CLUSTER_MAX_BACKOFF = 5 * time.Minute
+)
+
+// Cluster is a Kubernetes cluster the broadcaster publishes events from.
+// Name is set as the ClusterName of the metadata of every event published from the cluster.
+type Cluster struct {
+	Name       string
+	RestConfig *rest.Config
+}
+
+// ClusterStatus reports if the broadcaster is watching the resources of a cluster.
+// Synced is true once the caches of the cluster are synced. Elected is true once the broadcaster is the leader of the
+// cluster, or right away if leader election is disabled. Error is the last error that stopped the cluster, if any.
+type ClusterStatus struct {
+	Name    string
+	Synced  bool
+	Elected bool
+	Error   error
+}
+
+// NewMultiCluster returns a broadcaster that publishes the events of several clusters using the same brokers.
+// Each cluster has its own manager and controllers, so a cluster that fails doesn't stop the others.
+// The ClusterName of the config is ignored in favour of the name of each cluster.
+func NewMultiCluster(clusters []Cluster, broker brokers.Broker, config *Config) *Broadcaster {
+	broadcaster := New(nil, broker, config)
+	if broadcaster.error != nil {
+		return broadcaster
+	}
+
+	if len(clusters) == 0 {
+		broadcaster.error = errors.New("at least one cluster is required")
+		return broadcaster
+	}
+
+	names := map[string]bool{}
+	for _, cluster := range clusters {
+		if cluster.Name == "" || cluster.RestConfig == nil {
+			broadcaster.error = errors.New("clusters require a name and a rest config")
+			return broadcaster
+		}
+
+		if names[cluster.Name] {
+			broadcaster.error = errors.Errorf("cluster %s is duplicated", cluster.Name)
+			return broadcaster
+		}
+		names[cluster.Name] = true
+	}
+
+	broadcaster.clusters = clusters
+
+	return broadcaster
+}
+
+// Clusters returns the status of the clusters the broadcaster publishes events from
+func (b *Broadcaster) Clusters() []ClusterStatus {
+	if len(b.children) == 0 {
+		return []ClusterStatus{b.status.get(b.clusterName)}
+	}
+
+	statuses := make([]ClusterStatus, 0, len(b.children))
+	for _, child := range b.children {
+		statuses = append(statuses, child.status.get(child.clusterName))
+	}
+
+	return statuses
+}
+
+// forCluster returns a broadcaster for the cluster sharing the brokers, filters and watchers of b
+func (b *Broadcaster) forCluster(cluster Cluster, metricsBindAddress string) *Broadcaster {
+	config := *b.config
+	config.ClusterName = cluster.Name
+	config.MetricsBindAddress = metricsBindAddress
+
+	child := *b
+	child.logger = b.logger.WithField("cluster", cluster.Name)
+	child.clusterName = cluster.Name
+	child.restConfig = cluster.RestConfig
+	child.config = &config
+	child.status = &clusterStatus{}
+	child.initial = &initialList{}
+	child.Manager = nil
+	child.controllers = nil
+	child.clusters = nil
+	child.children = nil
+
+	return &child
+}
+
+// startClusters runs every cluster until ctx is cancelled
+func (b *Broadcaster) startClusters(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, child := range b.children {
+		wg.Add(1)
+		go func(child *Broadcaster) {
+			defer wg.Done()
+			child.run(ctx)
+		}(child)
+	}
+
+	wg.Wait()
+}
+
+// run starts the manager of the cluster. Managers can't be started twice, so a new manager is built
+// every time the cluster is restarted after a failure, e.g. when the API server is not reachable.
+func (b *Broadcaster) run(ctx context.Context) {
+	backoff := CLUSTER_MIN_BACKOFF
+	for {
+		var err error
+		if b.Manager == nil {
+			err = b.build()
+		}
+
+		if err == nil {
+			runCtx, cancel := context.WithCancel(ctx)
+			go b.status.watch(runCtx, b.clusterName, b.Manager)
+			b.logger.Info("starting cluster")
+			err = b.startManager(runCtx)
+			cancel()
+			b.Manager = nil
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err == nil {
+				err = errors.New("manager stopped")
+			}
+		}
+
+		// Clusters that were watched before failing start over from the minimum backoff, instead of waiting
+		// as long as clusters that keep failing to sync
+		if b.status.get(b.clusterName).Synced {
+			backoff = CLUSTER_MIN_BACKOFF
+		}
+		b.status.fail(b.clusterName, err)
+		b.logger.WithError(err).Errorf("cluster failed, restarting in %s", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > CLUSTER_MAX_BACKOFF {
+			backoff = CLUSTER_MAX_BACKOFF
+		}
+	}
+}
+
+// clusterStatus tracks if the caches of a cluster are synced. reader is the cache of the cluster once synced.
+type clusterStatus struct {
+	mutex   sync.RWMutex
+	synced  bool
+	elected bool
+	err     error
+	reader  client.Reader
+}
+
+// watch marks the cluster as synced once the caches of the manager are synced, and as elected once the manager is elected
+func (s *clusterStatus) watch(ctx context.Context, name string, mgr *manager.Manager) {
+	if !mgr.GetCache().WaitForCacheSync(ctx) {
+		return
+	}
+
+	s.mutex.Lock()
+	s.synced, s.err = true, nil
+	s.reader = mgr.GetCache()
+	metrics.ClusterUp.WithLabelValues(name).Set(1)
+	s.mutex.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-mgr.Elected():
+	}
+
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	s.elected = true
+}
+
+// fail marks the cluster as failed with err
+func (s *clusterStatus) fail(name string, err error) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	s.synced, s.elected, s.err = false, false, err
+	s.reader = nil
+	metrics.ClusterUp.WithLabelValues(name).Set(0)
+}
+
+// source returns the cache of the cluster to be queried. The reader is nil while the caches are not synced.
+func (s *clusterStatus) source(name string) query.Source {
+	defer s.mutex.RUnlock()
+	s.mutex.RLock()
+
+	return query.Source{Cluster: name, Reader: s.reader}
+}
+
+func (s *clusterStatus) get(name string) ClusterStatus {
+	defer s.mutex.RUnlock()
+	s.mutex.RLock()
+
+	return ClusterStatus{
+		Name:    name,
+		Synced:  s.synced,
+		Elected: s.elected,
+		Error:   s.err,
+	}
+}
@@ -0,0 +1,97 @@
+package broadcaster
+
+import (
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+// recorder is a broker that keeps the events it publishes
+type recorder struct {
+	events []events.Event
+}
+
+func (r *recorder) BuildEnvelope(event events.Event) (*events.Envelope, error) {
+	return &events.Envelope{Message: event}, nil
+}
+
+func (r *recorder) SendMessage(envelope *events.Envelope) error {
+	r.events = append(r.events, envelope.Message.(events.Event))
+	return nil
+}
+
+func Test_NewMultiCluster(t *testing.T) {
+	restConfig := &rest.Config{Host: "https://localhost:6443"}
+
+	testCases := []struct {
+		desc     string
+		clusters []Cluster
+		wantErr  bool
+	}{
+		{
+			desc:     "it should accept clusters with unique names",
+			clusters: []Cluster{{Name: "us-central1", RestConfig: restConfig}, {Name: "europe-west1", RestConfig: restConfig}},
+		},
+		{
+			desc:    "it should fail without clusters",
+			wantErr: true,
+		},
+		{
+			desc:     "it should fail on clusters without name",
+			clusters: []Cluster{{RestConfig: restConfig}},
+			wantErr:  true,
+		},
+		{
+			desc:     "it should fail on duplicated clusters",
+			clusters: []Cluster{{Name: "us-central1", RestConfig: restConfig}, {Name: "us-central1", RestConfig: restConfig}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			b := NewMultiCluster(tc.clusters, &recorder{}, &Config{})
+			if tc.wantErr {
+				require.Error(t, b.error)
+				return
+			}
+			require.NoError(t, b.error)
+		})
+	}
+}
+
+func Test_Broadcaster_forCluster(t *testing.T) {
+	broker := &recorder{}
+	clusters := []Cluster{
+		{Name: "us-central1", RestConfig: &rest.Config{Host: "https://us-central1:6443"}},
+		{Name: "europe-west1", RestConfig: &rest.Config{Host: "https://europe-west1:6443"}},
+	}
+	b := NewMultiCluster(clusters, broker, &Config{ClusterName: "ignored"})
+	require.NoError(t, b.error)
+
+	gs := &v1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "simple-udp-agones", Namespace: "default"}}
+	for _, cluster := range clusters {
+		child := b.forCluster(cluster, "0")
+		require.Equal(t, cluster.RestConfig, child.restConfig)
+		require.NoError(t, child.OnAdd(gs))
+	}
+
+	t.Run("it should publish events of every cluster using the same brokers", func(t *testing.T) {
+		require.Len(t, broker.events, 2)
+	})
+
+	t.Run("it should set the name of the cluster on the event metadata", func(t *testing.T) {
+		for i, event := range broker.events {
+			require.Equal(t, clusters[i].Name, events.MetadataOf(event.(events.Message)).ClusterName)
+		}
+	})
+
+	t.Run("it should not change the config of the broadcaster", func(t *testing.T) {
+		require.Equal(t, "ignored", b.config.ClusterName)
+	})
+}
@@ -0,0 +1,164 @@
+package broadcaster
+
+import (
+	"context"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/diagnostics"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+// GAMESERVER_NODE_INDEX is the cache index of GameServers by the name of the node running them
+const GAMESERVER_NODE_INDEX = "status.nodeName"
+
+// WithDiagnostics watches the Pods and Nodes backing GameServers and publishes diagnostic events like
+// gameserver.pod.oomkilled, gameserver.pod.evicted, gameserver.pod.restarted and gameserver.node.notready.
+// Only pods labeled with agones.dev/gameserver are cached. Watching nodes requires cluster wide permissions.
+func (b *Broadcaster) WithDiagnostics() *Broadcaster {
+	if b.error != nil {
+		return b
+	}
+
+	pods, err := newWatcher(&corev1.Pod{}, WithLabelSelector(v1.GameServerPodLabel))
+	if err != nil {
+		b.error = err
+		return b
+	}
+
+	nodes, err := newWatcher(&corev1.Node{})
+	if err != nil {
+		b.error = err
+		return b
+	}
+
+	pods.newHandler = func(b *Broadcaster, mgr *manager.Manager) (handlers.EventHandler, error) {
+		return &diagnosticsHandler{
+			broadcaster: b,
+			reader:      mgr.GetClient(),
+		}, nil
+	}
+	// GameServers on a node that is not ready are read using the index instead of listing every GameServer
+	nodes.newHandler = func(b *Broadcaster, mgr *manager.Manager) (handlers.EventHandler, error) {
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1.GameServer{}, GAMESERVER_NODE_INDEX, gameServerNode); err != nil {
+			return nil, errors.Wrap(err, "error indexing gameservers by node")
+		}
+
+		return &diagnosticsHandler{
+			broadcaster: b,
+			reader:      mgr.GetClient(),
+		}, nil
+	}
+
+	b.watchers = append(b.watchers, pods, nodes)
+
+	return b
+}
+
+// diagnosticsHandler publishes diagnostic events for updates of the Pods and Nodes backing GameServers
+type diagnosticsHandler struct {
+	broadcaster *Broadcaster
+	reader      client.Reader
+}
+
+// OnAdd ignores pods and nodes that have been added
+func (h *diagnosticsHandler) OnAdd(obj interface{}) error {
+	return nil
+}
+
+// OnUpdate publishes diagnostic events for pods and nodes that changed
+func (h *diagnosticsHandler) OnUpdate(oldObj interface{}, newObj interface{}) error {
+	switch newResource := newObj.(type) {
+	case *corev1.Pod:
+		oldResource, ok := oldObj.(*corev1.Pod)
+		if !ok {
+			return nil
+		}
+
+		eventType, diagnostic, ok := diagnostics.PodDiagnostic(oldResource, newResource)
+		if !ok {
+			return nil
+		}
+
+		return h.publish(eventType, diagnostic, h.gameServer(newResource.Namespace, diagnostic.GameServer))
+	case *corev1.Node:
+		oldResource, ok := oldObj.(*corev1.Node)
+		if !ok || !diagnostics.NodeNotReady(oldResource, newResource) {
+			return nil
+		}
+
+		list := &v1.GameServerList{}
+		if err := h.reader.List(context.Background(), list, client.MatchingFields{GAMESERVER_NODE_INDEX: newResource.Name}); err != nil {
+			return errors.Wrapf(err, "error listing gameservers on node %s", newResource.Name)
+		}
+
+		// Every GameServer is published even if some of them fail, so only the failed ones are retried
+		var failed []*handlers.FailedEvent
+		var err error
+		for i := range list.Items {
+			gs := &list.Items[i]
+			publishErr := h.publish(events.GameServerNodeNotReady, diagnostics.NodeDiagnostic(newResource, gs), gs)
+			if failedEvents, ok := handlers.FailedEvents(publishErr); ok {
+				failed = append(failed, failedEvents...)
+			} else if publishErr != nil {
+				err = publishErr
+			}
+		}
+
+		if len(failed) > 0 {
+			return &handlers.PublishError{Events: failed}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// OnDelete ignores pods and nodes that have been deleted
+func (h *diagnosticsHandler) OnDelete(obj interface{}) error {
+	return nil
+}
+
+func (h *diagnosticsHandler) publish(eventType events.DiagnosticEventType, diagnostic *events.Diagnostic, gs *v1.GameServer) error {
+	message := &events.DiagnosticMessage{
+		Diagnostic: diagnostic,
+		Obj:        gs,
+		Meta:       h.broadcaster.metadataFor(gs, false),
+	}
+
+	return h.broadcaster.Publish(events.NewDiagnosticEvent(eventType, message))
+}
+
+// gameServerNode returns the name of the node running the GameServer, if it is scheduled
+func gameServerNode(obj client.Object) []string {
+	gs, ok := obj.(*v1.GameServer)
+	if !ok || gs.Status.NodeName == "" {
+		return nil
+	}
+
+	return []string{gs.Status.NodeName}
+}
+
+// gameServer returns the GameServer from the cache. GameServers that are gone are returned with their name only.
+func (h *diagnosticsHandler) gameServer(namespace, name string) *v1.GameServer {
+	gs := &v1.GameServer{}
+	err := h.reader.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, gs)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			h.broadcaster.logger.WithError(err).Warnf("error getting gameserver %s/%s", namespace, name)
+		}
+
+		return &v1.GameServer{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+
+	return gs
+}
@@ -0,0 +1,164 @@
+package broadcaster
+
+import (
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+func newDiagnosticsHandler(t *testing.T, broker brokers.Broker, objects ...client.Object) *diagnosticsHandler {
+	b := New(nil, broker, &Config{})
+	require.NoError(t, b.error)
+
+	reader := fake.NewClientBuilder().
+		WithScheme(manager.Scheme).
+		WithObjects(objects...).
+		WithIndex(&v1.GameServer{}, GAMESERVER_NODE_INDEX, gameServerNode).
+		Build()
+
+	return &diagnosticsHandler{broadcaster: b, reader: reader}
+}
+
+func newGameServerOnNode(name, nodeName string) *v1.GameServer {
+	gs := newGameServerCreatedAt(name, time.Now())
+	gs.Status.NodeName = nodeName
+	return gs
+}
+
+func newGameServerPod(name string, statuses ...corev1.ContainerStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{v1.GameServerPodLabel: name},
+		},
+		Status: corev1.PodStatus{ContainerStatuses: statuses},
+	}
+}
+
+func newNode(name string, ready corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready, Reason: "KubeletNotReady"}},
+		},
+	}
+}
+
+func Test_diagnosticsHandler_OnUpdate_Pod(t *testing.T) {
+	oomKilled := corev1.ContainerStatus{
+		Name:  "simple-udp",
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"}},
+	}
+
+	t.Run("it should publish the diagnostic with the GameServer from the cache", func(t *testing.T) {
+		broker := &recorder{}
+		h := newDiagnosticsHandler(t, broker, newGameServerOnNode("ranked", "node-1"))
+
+		require.NoError(t, h.OnUpdate(newGameServerPod("ranked"), newGameServerPod("ranked", oomKilled)))
+
+		require.Len(t, broker.events, 1)
+		require.Equal(t, events.EventType(events.GameServerPodOOMKilled), broker.events[0].EventType())
+		message := broker.events[0].(*events.DiagnosticEvent).Message.(*events.DiagnosticMessage)
+		require.Equal(t, "ranked", message.Diagnostic.GameServer)
+		require.Equal(t, "node-1", message.Obj.(*v1.GameServer).Status.NodeName)
+		require.NotNil(t, message.Metadata())
+	})
+
+	t.Run("it should publish the diagnostic of GameServers that are gone with their name", func(t *testing.T) {
+		broker := &recorder{}
+		h := newDiagnosticsHandler(t, broker)
+
+		require.NoError(t, h.OnUpdate(newGameServerPod("ranked"), newGameServerPod("ranked", oomKilled)))
+
+		require.Len(t, broker.events, 1)
+		gs := broker.events[0].(*events.DiagnosticEvent).Message.(*events.DiagnosticMessage).Obj.(*v1.GameServer)
+		require.Equal(t, "default", gs.Namespace)
+		require.Equal(t, "ranked", gs.Name)
+	})
+
+	t.Run("it should not publish updates without diagnostics", func(t *testing.T) {
+		broker := &recorder{}
+		h := newDiagnosticsHandler(t, broker, newGameServerOnNode("ranked", "node-1"))
+
+		require.NoError(t, h.OnUpdate(newGameServerPod("ranked"), newGameServerPod("ranked")))
+		require.Empty(t, broker.events)
+	})
+}
+
+func Test_diagnosticsHandler_OnUpdate_Node(t *testing.T) {
+	t.Run("it should publish one event per GameServer on the node that is not ready", func(t *testing.T) {
+		broker := &recorder{}
+		h := newDiagnosticsHandler(t, broker,
+			newGameServerOnNode("ranked", "node-1"),
+			newGameServerOnNode("casual", "node-1"),
+			newGameServerOnNode("other", "node-2"),
+			newGameServerOnNode("unscheduled", ""),
+		)
+
+		require.NoError(t, h.OnUpdate(newNode("node-1", corev1.ConditionTrue), newNode("node-1", corev1.ConditionFalse)))
+
+		var names []string
+		for _, event := range broker.events {
+			require.Equal(t, events.EventType(events.GameServerNodeNotReady), event.EventType())
+			message := event.(*events.DiagnosticEvent).Message.(*events.DiagnosticMessage)
+			require.Equal(t, "node-1", message.Diagnostic.Node)
+			require.Equal(t, "KubeletNotReady", message.Diagnostic.Reason)
+			names = append(names, message.Diagnostic.GameServer)
+		}
+		require.ElementsMatch(t, []string{"ranked", "casual"}, names)
+	})
+
+	t.Run("it should publish every GameServer and only retry the ones that failed", func(t *testing.T) {
+		broker := &failingRecorder{failures: 1}
+		h := newDiagnosticsHandler(t, broker,
+			newGameServerOnNode("ranked", "node-1"),
+			newGameServerOnNode("casual", "node-1"),
+		)
+
+		err := h.OnUpdate(newNode("node-1", corev1.ConditionTrue), newNode("node-1", corev1.ConditionFalse))
+		failed, ok := handlers.FailedEvents(err)
+		require.True(t, ok)
+		require.Len(t, failed, 1)
+		require.Len(t, broker.events, 1, "it should publish the GameServers after the one that failed")
+
+		require.NoError(t, handlers.Republish(failed))
+		require.Len(t, broker.events, 2)
+		require.Equal(t, failed[0].Event, broker.events[1], "it should publish the failed event again")
+
+		var names []string
+		for _, event := range broker.events {
+			names = append(names, event.(*events.DiagnosticEvent).Message.(*events.DiagnosticMessage).Diagnostic.GameServer)
+		}
+		require.ElementsMatch(t, []string{"ranked", "casual"}, names)
+	})
+
+	t.Run("it should not publish nodes that are still ready or were not ready", func(t *testing.T) {
+		broker := &recorder{}
+		h := newDiagnosticsHandler(t, broker, newGameServerOnNode("ranked", "node-1"))
+
+		require.NoError(t, h.OnUpdate(newNode("node-1", corev1.ConditionTrue), newNode("node-1", corev1.ConditionTrue)))
+		require.NoError(t, h.OnUpdate(newNode("node-1", corev1.ConditionFalse), newNode("node-1", corev1.ConditionFalse)))
+		require.Empty(t, broker.events)
+	})
+}
+
+func Test_diagnosticsHandler_OnAdd_OnDelete(t *testing.T) {
+	broker := &recorder{}
+	h := newDiagnosticsHandler(t, broker, newGameServerOnNode("ranked", "node-1"))
+
+	require.NoError(t, h.OnAdd(newGameServerPod("ranked")))
+	require.NoError(t, h.OnDelete(newNode("node-1", corev1.ConditionFalse)))
+	require.Empty(t, broker.events)
+}
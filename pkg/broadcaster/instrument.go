@@ -0,0 +1,177 @@
+package broadcaster
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
+	"github.com/Octops/agones-event-broadcaster/pkg/controller"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
+)
+
+// brokerName returns the name of the broker used on metrics. For example: pubsub for *pubsub.PubSubBroker
+func brokerName(broker brokers.Broker) string {
+	t := reflect.TypeOf(broker)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return strings.TrimSuffix(strings.ToLower(t.Name()), "broker")
+}
+
+// kindOf returns the kind of the resource of the event. It is empty for events without a resource.
+func kindOf(event events.Event) string {
+	message, ok := event.(events.Message)
+	if !ok {
+		return ""
+	}
+
+	obj, ok := events.ResourceObject(message)
+	if !ok {
+		return ""
+	}
+
+	gvk, err := apiutil.GVKForObject(obj, manager.Scheme)
+	if err != nil {
+		return ""
+	}
+
+	return gvk.Kind
+}
+
+// dropped returns the hook counting the events of the resources of the type of obj dropped by the controllers.
+// Events are counted once per broker that failed to publish them. Events failing before being built have no event type.
+func (b *Broadcaster) dropped(obj runtime.Object) func(reconcile.Request, *controller.PendingEvent, error) {
+	var kind string
+	if gvk, err := apiutil.GVKForObject(obj, manager.Scheme); err == nil {
+		kind = gvk.Kind
+	}
+
+	return func(request reconcile.Request, pending *controller.PendingEvent, err error) {
+		reason := metrics.DROP_REASON_RETRY_TIMEOUT
+		switch {
+		case errors.Is(err, controller.ErrPendingStoreFull):
+			reason = metrics.DROP_REASON_PENDING_FULL
+		case errors.Is(err, controller.ErrMaxRetries):
+			reason = metrics.DROP_REASON_MAX_RETRIES
+		}
+
+		if len(pending.Failed) == 0 {
+			metrics.EventsDropped.WithLabelValues(b.clusterName, kind, "", metrics.ALL_BROKERS, reason).Inc()
+			return
+		}
+
+		for _, failed := range pending.Failed {
+			countDropped(b.clusterName, failed.Event, failed.Brokers, reason)
+		}
+	}
+}
+
+// countDropped counts the event of the cluster as dropped by the brokers, or every broker if empty
+func countDropped(cluster string, event events.Event, brokers []string, reason string) {
+	var kind, eventType string
+	if event != nil {
+		kind, eventType = kindOf(event), event.EventType().String()
+	}
+
+	if len(brokers) == 0 {
+		brokers = []string{metrics.ALL_BROKERS}
+	}
+
+	for _, broker := range brokers {
+		metrics.EventsDropped.WithLabelValues(cluster, kind, eventType, broker, reason).Inc()
+	}
+}
+
+// spanAttributes returns the attributes of the span of the receipt of the event: its type, id and cluster,
+// and the kind, namespace and name of its resource, if any
+func (b *Broadcaster) spanAttributes(event events.Event, kind string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("event.type", event.EventType().String()),
+		attribute.String("event.source", event.EventSource().String()),
+	}
+
+	if b.clusterName != "" {
+		attrs = append(attrs, attribute.String("k8s.cluster.name", b.clusterName))
+	}
+
+	message, ok := event.(events.Message)
+	if !ok {
+		return attrs
+	}
+
+	if metadata := events.MetadataOf(message); metadata != nil && metadata.ID != "" {
+		attrs = append(attrs, attribute.String("event.id", metadata.ID))
+	}
+
+	obj, ok := events.ResourceObject(message)
+	if !ok {
+		return attrs
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return attrs
+	}
+
+	attrs = append(attrs, attribute.String("k8s.resource.kind", kind), attribute.String("k8s.resource.name", accessor.GetName()))
+	if accessor.GetNamespace() != "" {
+		attrs = append(attrs, attribute.String("k8s.namespace.name", accessor.GetNamespace()))
+	}
+
+	return attrs
+}
+
+// lastChange returns the time of the last change of the resource of the event, from its creation and deletion
+// timestamps and the time of its managed fields. Timestamps have a precision of seconds.
+// It is zero for resources listed when the broadcaster started, which changed before the broadcaster was watching.
+func (b *Broadcaster) lastChange(event events.Event) time.Time {
+	message, ok := event.(events.Message)
+	if !ok {
+		return time.Time{}
+	}
+
+	obj, ok := events.ResourceObject(message)
+	if !ok {
+		return time.Time{}
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return time.Time{}
+	}
+
+	switch event.EventSource() {
+	case events.EventSourceOnSnapshot:
+		return time.Time{}
+	case events.EventSourceOnAdd:
+		if b.existed(obj) {
+			return time.Time{}
+		}
+	case events.EventSourceOnDelete:
+		// Resources deleted while the broadcaster was not watching don't carry the time of the deletion
+		if deletionTimestamp := accessor.GetDeletionTimestamp(); deletionTimestamp != nil {
+			return deletionTimestamp.Time
+		}
+		return time.Time{}
+	}
+
+	changedAt := accessor.GetCreationTimestamp().Time
+	for _, field := range accessor.GetManagedFields() {
+		if field.Time != nil && field.Time.After(changedAt) {
+			changedAt = field.Time.Time
+		}
+	}
+
+	return changedAt
+}
@@ -0,0 +1,173 @@
+package broadcaster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers/stdout"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
+)
+
+func Test_brokerName(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		broker brokers.Broker
+		want   string
+	}{
+		{
+			desc:   "it should trim the broker suffix",
+			broker: &stdout.StdoutBroker{},
+			want:   "stdout",
+		},
+		{
+			desc:   "it should keep names without the broker suffix",
+			broker: &recorder{},
+			want:   "recorder",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Equal(t, tc.want, brokerName(tc.broker))
+		})
+	}
+}
+
+func Test_Broadcaster_lastChange(t *testing.T) {
+	startedAt := time.Now().Truncate(time.Second)
+	updatedAt := startedAt.Add(time.Minute)
+
+	created := newGameServerCreatedAt("created", startedAt.Add(time.Second))
+	updated := newGameServerCreatedAt("updated", startedAt.Add(time.Second))
+	updated.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: "agones-controller", Time: &metav1.Time{Time: updatedAt}}}
+	deleted := newGameServerCreatedAt("deleted", startedAt.Add(time.Second))
+	deleted.DeletionTimestamp = &metav1.Time{Time: updatedAt}
+	existing := newGameServerCreatedAt("existing", startedAt.Add(-time.Hour))
+
+	b := New(nil, &recorder{}, &Config{StartupMode: STARTUP_MODE_SNAPSHOT})
+	require.NoError(t, b.error)
+	withInitialList(b, existing)
+
+	t.Run("it should use the creation timestamp of added resources", func(t *testing.T) {
+		require.Equal(t, created.CreationTimestamp.Time, b.lastChange(events.GameServerAdded(&events.AddedMessage{Obj: created})))
+	})
+
+	t.Run("it should use the latest managed field of updated resources", func(t *testing.T) {
+		require.Equal(t, updatedAt, b.lastChange(events.GameServerUpdated(&events.UpdatedMessage{Old: created, New: updated})))
+	})
+
+	t.Run("it should use the deletion timestamp of deleted resources", func(t *testing.T) {
+		require.Equal(t, updatedAt, b.lastChange(events.GameServerDeleted(&events.DeletedMessage{Obj: deleted})))
+	})
+
+	t.Run("it should ignore resources that existed when the broadcaster started", func(t *testing.T) {
+		require.True(t, b.lastChange(events.GameServerAdded(&events.AddedMessage{Obj: existing})).IsZero())
+	})
+}
+
+// envelopeRecorder keeps the envelopes sent by the broadcaster
+type envelopeRecorder struct {
+	envelopes []*events.Envelope
+}
+
+func (r *envelopeRecorder) BuildEnvelope(event events.Event) (*events.Envelope, error) {
+	envelope := &events.Envelope{Message: event}
+	envelope.AddMetadataHeaders(events.MetadataOf(event.(events.Message)))
+	return envelope, nil
+}
+
+func (r *envelopeRecorder) SendMessage(envelope *events.Envelope) error {
+	r.envelopes = append(r.envelopes, envelope)
+	return nil
+}
+
+func Test_Broadcaster_send_Tracing(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	broker := &envelopeRecorder{}
+	b := New(nil, broker, &Config{ClusterName: "us-central1", Filter: `object.metadata.name != "filtered"`})
+	require.NoError(t, b.error)
+
+	t.Run("it should trace the receipt, filtering, envelope building and send of the event", func(t *testing.T) {
+		require.NoError(t, b.OnAdd(newGameServerCreatedAt("simple-udp", time.Now())))
+
+		ended := spans.Ended()
+		names := make([]string, 0, len(ended))
+		for _, span := range ended {
+			names = append(names, span.Name())
+		}
+		require.Equal(t, []string{"filter", "prepare", "build envelope", "send enveloperecorder", "receive gameserver.events.added"}, names)
+
+		receive := ended[len(ended)-1]
+		for _, span := range ended[:len(ended)-1] {
+			require.Equal(t, receive.SpanContext().TraceID(), span.SpanContext().TraceID())
+			require.Equal(t, receive.SpanContext().SpanID(), span.Parent().SpanID())
+		}
+		require.Contains(t, receive.Attributes(), attribute.String("k8s.resource.name", "simple-udp"))
+		require.Contains(t, receive.Attributes(), attribute.String("k8s.cluster.name", "us-central1"))
+
+		send := ended[3]
+		require.Equal(t, trace.SpanKindProducer, send.SpanKind())
+
+		require.Len(t, broker.envelopes, 1)
+		traceHeaders := broker.envelopes[0].TraceHeaders()
+		require.Contains(t, traceHeaders[events.TRACEPARENT_HEADER_KEY], send.SpanContext().SpanID().String())
+		require.Contains(t, traceHeaders[events.TRACEPARENT_HEADER_KEY], send.SpanContext().TraceID().String())
+	})
+
+	t.Run("it should only trace the receipt and filtering of filtered events", func(t *testing.T) {
+		published := len(spans.Ended())
+		require.NoError(t, b.OnAdd(newGameServerCreatedAt("filtered", time.Now())))
+
+		ended := spans.Ended()[published:]
+		require.Len(t, ended, 2)
+		require.Equal(t, "filter", ended[0].Name())
+		require.Equal(t, "receive gameserver.events.added", ended[1].Name())
+		require.Len(t, broker.envelopes, 1)
+	})
+}
+
+func Test_Broadcaster_send_Metrics(t *testing.T) {
+	broker := &failingRecorder{failures: 2}
+	b := New(nil, broker, &Config{BrokerName: "metrics"})
+	require.NoError(t, b.error)
+	b.clusterName = "metrics-cluster"
+
+	event := events.OnAdded(&events.AddedMessage{Obj: newGameServerCreatedAt("ranked", time.Now())})
+	eventType := event.EventType().String()
+	received := metrics.EventsReceived.WithLabelValues("metrics-cluster", "GameServer", eventType)
+	failed := metrics.EventsFailed.WithLabelValues("metrics-cluster", "GameServer", eventType, "metrics")
+	retried := metrics.EventsRetried.WithLabelValues("metrics-cluster", "GameServer", eventType, "metrics")
+	published := metrics.EventsPublished.WithLabelValues("metrics-cluster", "GameServer", eventType, "metrics")
+
+	err := b.Publish(event)
+	pending, ok := handlers.FailedEvents(err)
+	require.True(t, ok)
+
+	require.Error(t, handlers.Republish(pending))
+	require.NoError(t, handlers.Republish(pending))
+
+	require.Equal(t, float64(1), testutil.ToFloat64(received), "it should not count retries as received events")
+	require.Equal(t, float64(1), testutil.ToFloat64(failed), "it should count the event as failed once")
+	require.Equal(t, float64(2), testutil.ToFloat64(retried))
+	require.Equal(t, float64(1), testutil.ToFloat64(published))
+}
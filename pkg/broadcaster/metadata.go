@@ -0,0 +1,74 @@
+package broadcaster
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+// sequencer keeps track of the sequence number of the events emitted for each resource.
+// Sequences are not persisted, every broadcaster process starts them over at 1.
+type sequencer struct {
+	mutex     sync.Mutex
+	sequences map[types.UID]uint64
+}
+
+func newSequencer() *sequencer {
+	return &sequencer{
+		sequences: map[types.UID]uint64{},
+	}
+}
+
+// Next returns the next sequence number for the resource identified by uid
+func (s *sequencer) Next(uid types.UID) uint64 {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	s.sequences[uid]++
+	return s.sequences[uid]
+}
+
+// Forget stops tracking the sequence number of the resource identified by uid
+func (s *sequencer) Forget(uid types.UID) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	delete(s.sequences, uid)
+}
+
+// metadataFor builds the metadata of an event emitted for obj.
+// The sequence number of deleted resources is no longer tracked after the delete event.
+// Delete events carry the DeletionTimestamp of the resource, if any, so consumers can compare it with ObservedAt.
+func (b *Broadcaster) metadataFor(obj interface{}, deleted bool) *events.Metadata {
+	metadata := &events.Metadata{
+		ID:          string(uuid.NewUUID()),
+		ObservedAt:  time.Now().UTC(),
+		ClusterName: b.clusterName,
+		Instance:    b.instance,
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return metadata
+	}
+
+	metadata.ResourceUID = string(accessor.GetUID())
+	metadata.ResourceVersion = accessor.GetResourceVersion()
+	metadata.Sequence = b.sequencer.Next(accessor.GetUID())
+
+	if deleted {
+		b.sequencer.Forget(accessor.GetUID())
+
+		if deletionTimestamp := accessor.GetDeletionTimestamp(); deletionTimestamp != nil {
+			t := deletionTimestamp.UTC()
+			metadata.DeletionTimestamp = &t
+		}
+	}
+
+	return metadata
+}
@@ -0,0 +1,140 @@
+package broadcaster
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_sequencer(t *testing.T) {
+	t.Run("it should increase the sequence of each resource on its own", func(t *testing.T) {
+		s := newSequencer()
+		require.Equal(t, uint64(1), s.Next("ranked"))
+		require.Equal(t, uint64(2), s.Next("ranked"))
+		require.Equal(t, uint64(1), s.Next("casual"))
+		require.Equal(t, uint64(3), s.Next("ranked"))
+	})
+
+	t.Run("it should start over once the resource is forgotten", func(t *testing.T) {
+		s := newSequencer()
+		s.Next("ranked")
+		s.Next("ranked")
+		s.Forget("ranked")
+
+		require.Equal(t, uint64(1), s.Next("ranked"))
+		require.Len(t, s.sequences, 1)
+	})
+
+	t.Run("it should return unique sequences to concurrent callers", func(t *testing.T) {
+		s := newSequencer()
+		var mutex sync.Mutex
+		seen := map[uint64]bool{}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sequence := s.Next("ranked")
+
+				mutex.Lock()
+				seen[sequence] = true
+				mutex.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		require.Len(t, seen, 100)
+		for i := uint64(1); i <= 100; i++ {
+			require.True(t, seen[i], "sequence %d is missing", i)
+		}
+	})
+}
+
+func Test_Broadcaster_metadataFor(t *testing.T) {
+	newBroadcaster := func(t *testing.T) *Broadcaster {
+		b := New(nil, &recorder{}, &Config{ClusterName: "us-central1", Instance: "broadcaster-0"})
+		require.NoError(t, b.error)
+		return b
+	}
+
+	t.Run("it should identify the event and the resource", func(t *testing.T) {
+		b := newBroadcaster(t)
+		gs := newGameServerCreatedAt("ranked", time.Now())
+		gs.UID = types.UID("uid-ranked")
+		gs.ResourceVersion = "42"
+
+		before := time.Now().UTC()
+		metadata := b.metadataFor(gs, false)
+
+		require.NotEmpty(t, metadata.ID)
+		require.False(t, metadata.ObservedAt.Before(before))
+		require.Equal(t, time.UTC, metadata.ObservedAt.Location())
+		require.Equal(t, "us-central1", metadata.ClusterName)
+		require.Equal(t, "broadcaster-0", metadata.Instance)
+		require.Equal(t, "uid-ranked", metadata.ResourceUID)
+		require.Equal(t, "42", metadata.ResourceVersion)
+		require.Equal(t, uint64(1), metadata.Sequence)
+		require.Nil(t, metadata.DeletionTimestamp)
+		require.NotEqual(t, metadata.ID, b.metadataFor(gs, false).ID, "it should generate a new id for every event")
+	})
+
+	t.Run("it should increase the sequence of the resource on every event", func(t *testing.T) {
+		b := newBroadcaster(t)
+		ranked := newGameServerCreatedAt("ranked", time.Now())
+		ranked.UID = types.UID("uid-ranked")
+		casual := newGameServerCreatedAt("casual", time.Now())
+		casual.UID = types.UID("uid-casual")
+
+		require.Equal(t, uint64(1), b.metadataFor(ranked, false).Sequence)
+		require.Equal(t, uint64(2), b.metadataFor(ranked, false).Sequence)
+		require.Equal(t, uint64(1), b.metadataFor(casual, false).Sequence)
+	})
+
+	t.Run("it should forget the sequence and set the deletion timestamp of deleted resources", func(t *testing.T) {
+		b := newBroadcaster(t)
+		gs := newGameServerCreatedAt("ranked", time.Now())
+		gs.UID = types.UID("uid-ranked")
+		deletedAt := metav1.NewTime(time.Date(2026, 10, 19, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)))
+		gs.DeletionTimestamp = &deletedAt
+
+		b.metadataFor(gs, false)
+		metadata := b.metadataFor(gs, true)
+		require.Equal(t, uint64(2), metadata.Sequence)
+		require.NotNil(t, metadata.DeletionTimestamp)
+		require.True(t, deletedAt.Time.Equal(*metadata.DeletionTimestamp))
+		require.Equal(t, time.UTC, metadata.DeletionTimestamp.Location())
+
+		require.NotContains(t, b.sequencer.sequences, gs.UID)
+		require.Equal(t, uint64(1), b.metadataFor(gs, false).Sequence, "it should start over if the resource is added again")
+	})
+
+	t.Run("it should start the sequences over when the broadcaster restarts", func(t *testing.T) {
+		gs := newGameServerCreatedAt("ranked", time.Now())
+		gs.UID = types.UID("uid-ranked")
+
+		b := newBroadcaster(t)
+		b.metadataFor(gs, false)
+		require.Equal(t, uint64(2), b.metadataFor(gs, false).Sequence)
+
+		restarted := newBroadcaster(t)
+		metadata := restarted.metadataFor(gs, false)
+		require.Equal(t, uint64(1), metadata.Sequence, "sequences are not persisted")
+		require.Equal(t, "broadcaster-0", metadata.Instance)
+	})
+
+	t.Run("it should only identify the event when there is no resource", func(t *testing.T) {
+		b := newBroadcaster(t)
+		metadata := b.metadataFor(nil, false)
+
+		require.NotEmpty(t, metadata.ID)
+		require.Equal(t, "us-central1", metadata.ClusterName)
+		require.Empty(t, metadata.ResourceUID)
+		require.Zero(t, metadata.Sequence)
+		require.Empty(t, b.sequencer.sequences)
+	})
+}
@@ -0,0 +1,344 @@
+package broadcaster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
+)
+
+// PausePolicy decides what happens to the events published while publishing is paused
+type PausePolicy string
+
+const (
+	// PAUSE_POLICY_BUFFER keeps the events published while paused and publishes them, in order, once resumed.
+	// Events published once the buffer is full are dropped.
+	PAUSE_POLICY_BUFFER PausePolicy = "buffer"
+	// PAUSE_POLICY_DROP drops the events published while paused
+	PAUSE_POLICY_DROP PausePolicy = "drop"
+
+	// DEFAULT_PAUSE_BUFFER_SIZE is the default number of events buffered while paused
+	DEFAULT_PAUSE_BUFFER_SIZE = 10000
+)
+
+// PauseConfig holds the settings used while publishing is paused. Policy defaults to PAUSE_POLICY_BUFFER.
+// BufferSize is the maximum number of events buffered. Defaults to DEFAULT_PAUSE_BUFFER_SIZE.
+// Dir is the directory of the file where events are buffered. Events are buffered in memory if empty.
+// Events buffered in memory are lost if the broadcaster stops while paused. Events left on the file by a previous run
+// are published once the caches of every cluster are synced, before new events.
+type PauseConfig struct {
+	Policy     PausePolicy
+	BufferSize int
+	Dir        string
+}
+
+// PauseState is the state of publishing. Resuming is set while the buffered events are being published.
+type PauseState struct {
+	Paused   bool        `json:"paused"`
+	PausedAt *time.Time  `json:"paused_at,omitempty"`
+	Resuming bool        `json:"resuming"`
+	Policy   PausePolicy `json:"policy"`
+	Buffered int         `json:"buffered"`
+	Dropped  uint64      `json:"dropped"`
+}
+
+// pending is an event held while paused. cluster is the cluster it was observed on and brokers, if set,
+// the only brokers it is published to, e.g. for replays. project and resource are the ones passed to dispatch.
+// Events read from a file carry their message instead, so they are projected by the cluster they are published to.
+type pending struct {
+	cluster  string
+	brokers  []string
+	event    events.Event
+	project  func(event events.Event) (events.Event, error)
+	resource runtime.Object
+	message  events.Message
+}
+
+// pauseQueue holds the pending events in the order they were published
+type pauseQueue interface {
+	push(p *pending) error
+	pop() (*pending, error)
+	len() int
+}
+
+// gate holds the events published while paused. Events published while the buffered ones are being published
+// are buffered too, so the order of the events is kept. leftover is set when events were left on the file by a previous
+// run, which are published before new events.
+type gate struct {
+	logger   *logrus.Entry
+	mutex    sync.Mutex
+	config   *PauseConfig
+	queue    pauseQueue
+	paused   bool
+	pausedAt time.Time
+	resuming bool
+	leftover bool
+	dropped  uint64
+}
+
+func newGate(logger *logrus.Entry, config *PauseConfig) (*gate, error) {
+	if config == nil {
+		config = &PauseConfig{}
+	}
+
+	switch config.Policy {
+	case "":
+		config.Policy = PAUSE_POLICY_BUFFER
+	case PAUSE_POLICY_BUFFER, PAUSE_POLICY_DROP:
+	default:
+		return nil, errors.Errorf("invalid pause policy %q, valid policies are %s and %s", config.Policy, PAUSE_POLICY_BUFFER, PAUSE_POLICY_DROP)
+	}
+
+	if config.BufferSize <= 0 {
+		config.BufferSize = DEFAULT_PAUSE_BUFFER_SIZE
+	}
+
+	g := &gate{logger: logger, config: config, queue: &memoryQueue{}}
+	if config.Dir != "" {
+		queue, err := newFileQueue(config.Dir)
+		if err != nil {
+			return nil, err
+		}
+		g.queue = queue
+	}
+
+	if g.queue.len() > 0 {
+		logger.Infof("%d events were buffered by a previous run, they will be published once the caches are synced", g.queue.len())
+		g.resuming, g.leftover = true, true
+		metrics.PausedEvents.Set(float64(g.queue.len()))
+	}
+
+	return g, nil
+}
+
+// hold returns true if the event is held by the gate and must not be published now.
+// It returns handlers.ErrDropped if the event was dropped instead of buffered.
+func (g *gate) hold(p *pending) (bool, error) {
+	defer g.mutex.Unlock()
+	g.mutex.Lock()
+
+	if !g.paused && !g.resuming {
+		return false, nil
+	}
+
+	if g.config.Policy == PAUSE_POLICY_DROP || g.queue.len() >= g.config.BufferSize {
+		g.drop(p, metrics.DROP_REASON_PAUSED)
+		return true, handlers.ErrDropped
+	}
+
+	if err := g.queue.push(p); err != nil {
+		g.logger.WithError(err).Error("error buffering event, event will not be published")
+		g.drop(p, metrics.DROP_REASON_PAUSED)
+		return true, handlers.ErrDropped
+	}
+	metrics.PausedEvents.Set(float64(g.queue.len()))
+
+	return true, nil
+}
+
+// drop counts the event as dropped. p is nil for buffered events that can't be read.
+func (g *gate) drop(p *pending, reason string) {
+	g.dropped++
+	metrics.PauseDroppedEvents.Inc()
+
+	if p != nil {
+		countDropped(p.cluster, p.event, p.brokers, reason)
+	} else {
+		countDropped("", nil, nil, reason)
+	}
+}
+
+// failed counts a buffered event that failed to be published once resumed as dropped
+func (g *gate) failed(p *pending) {
+	defer g.mutex.Unlock()
+	g.mutex.Lock()
+
+	g.drop(p, metrics.DROP_REASON_RESUME_FAILED)
+}
+
+// takeLeftover returns true, only once, if events were left on the file by a previous run
+func (g *gate) takeLeftover() bool {
+	defer g.mutex.Unlock()
+	g.mutex.Lock()
+
+	leftover := g.leftover
+	g.leftover = false
+
+	return leftover
+}
+
+// pause stops publishing. It returns false if publishing was already paused.
+func (g *gate) pause() bool {
+	defer g.mutex.Unlock()
+	g.mutex.Lock()
+
+	if g.paused {
+		return false
+	}
+
+	g.paused = true
+	g.pausedAt = time.Now().UTC()
+	metrics.Paused.Set(1)
+
+	return true
+}
+
+// resume starts publishing again. It returns true if the buffered events must be published by the caller.
+func (g *gate) resume() bool {
+	defer g.mutex.Unlock()
+	g.mutex.Lock()
+
+	if !g.paused {
+		return false
+	}
+
+	g.paused = false
+	metrics.Paused.Set(0)
+	if g.resuming || g.queue.len() == 0 {
+		return false
+	}
+
+	g.resuming = true
+	return true
+}
+
+// next returns the next buffered event to be published. It returns false once the buffer is empty,
+// or publishing was paused again, and events are published as usual.
+func (g *gate) next() (*pending, bool) {
+	defer g.mutex.Unlock()
+	g.mutex.Lock()
+
+	if g.paused {
+		g.resuming = false
+		return nil, false
+	}
+
+	for g.queue.len() > 0 {
+		p, err := g.queue.pop()
+		metrics.PausedEvents.Set(float64(g.queue.len()))
+		if err != nil {
+			g.logger.WithError(err).Error("error reading buffered event, event will not be published")
+			g.drop(nil, metrics.DROP_REASON_PAUSED)
+			continue
+		}
+
+		return p, true
+	}
+
+	g.resuming = false
+	return nil, false
+}
+
+func (g *gate) state() PauseState {
+	defer g.mutex.Unlock()
+	g.mutex.Lock()
+
+	state := PauseState{
+		Paused:   g.paused,
+		Resuming: g.resuming,
+		Policy:   g.config.Policy,
+		Buffered: g.queue.len(),
+		Dropped:  g.dropped,
+	}
+	if g.paused {
+		pausedAt := g.pausedAt
+		state.PausedAt = &pausedAt
+	}
+
+	return state
+}
+
+// Pause stops publishing events. Events are buffered or dropped depending on the pause policy until Resume is called.
+func (b *Broadcaster) Pause() PauseState {
+	if b.gate.pause() {
+		b.logger.Info("publishing paused")
+	}
+
+	return b.gate.state()
+}
+
+// Resume starts publishing events again. Buffered events are published in the background, in the order they were
+// published, before new events.
+func (b *Broadcaster) Resume() PauseState {
+	state := b.gate.state()
+	if b.gate.resume() {
+		b.logger.Infof("publishing resumed, publishing %d buffered events", state.Buffered)
+		go b.drain()
+	} else if state.Paused {
+		b.logger.Info("publishing resumed")
+	}
+
+	return b.gate.state()
+}
+
+// PauseState returns the state of publishing
+func (b *Broadcaster) PauseState() PauseState {
+	return b.gate.state()
+}
+
+// drain publishes the buffered events using the broadcaster of the cluster they were observed on.
+// Events are retried for a short time, e.g. while the brokers are recovering from the maintenance,
+// and counted as dropped if they still fail.
+func (b *Broadcaster) drain() {
+	clusters := map[string]*Broadcaster{b.clusterName: b}
+	for _, child := range b.children {
+		clusters[child.clusterName] = child
+	}
+
+	for {
+		p, ok := b.gate.next()
+		if !ok {
+			return
+		}
+
+		cluster, ok := clusters[p.cluster]
+		if !ok {
+			cluster = b
+		}
+
+		project := p.project
+		if project == nil && p.message != nil {
+			project = cluster.projectEvent
+		}
+
+		ctx := context.Background()
+		err := retryPublish(ctx, func() error {
+			return cluster.send(ctx, &delivery{event: p.event, project: project, brokers: p.brokers, resource: p.resource})
+		})
+		if err != nil {
+			cluster.logger.WithError(err).Error("error publishing buffered event, event will not be published")
+			b.gate.failed(p)
+		}
+	}
+}
+
+// drainLeftover publishes the events left on the buffer file by a previous run once the caches of every cluster are
+// synced and, when leader election is enabled, the broadcaster is the leader. New events are buffered behind them meanwhile.
+func (b *Broadcaster) drainLeftover(ctx context.Context) {
+	if !b.gate.takeLeftover() {
+		return
+	}
+
+	err := wait.PollUntilContextCancel(ctx, time.Second, true, func(context.Context) (bool, error) {
+		for _, status := range b.Clusters() {
+			if !status.Synced || !status.Elected {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return
+	}
+
+	b.logger.Infof("publishing %d events buffered by a previous run", b.gate.state().Buffered)
+	b.drain()
+}
@@ -0,0 +1,242 @@
+package broadcaster
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/checkpoint"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+// waitForResume waits until the buffered events have been published
+func waitForResume(t *testing.T, b *Broadcaster) {
+	require.Eventually(t, func() bool {
+		state := b.PauseState()
+		return !state.Resuming && state.Buffered == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_Broadcaster_Pause(t *testing.T) {
+	testCases := []struct {
+		desc string
+		dir  bool
+	}{
+		{
+			desc: "it should buffer events in memory while paused and publish them in order once resumed",
+		},
+		{
+			desc: "it should buffer events on a file while paused and publish them in order once resumed",
+			dir:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			config := &PauseConfig{}
+			if tc.dir {
+				config.Dir = t.TempDir()
+			}
+
+			broker := &recorder{}
+			b := New(nil, broker, &Config{Pause: config})
+			require.NoError(t, b.error)
+
+			gs := newGameServerCreatedAt("simple-udp", time.Now())
+			allocated := gs.DeepCopy()
+			allocated.Status.State = v1.GameServerStateAllocated
+
+			state := b.Pause()
+			require.True(t, state.Paused)
+			require.NotNil(t, state.PausedAt)
+
+			require.NoError(t, b.OnAdd(gs))
+			require.NoError(t, b.OnUpdate(gs, allocated))
+			require.NoError(t, b.OnDelete(allocated))
+			require.Empty(t, broker.events)
+			require.Equal(t, 3, b.PauseState().Buffered)
+
+			require.False(t, b.Resume().Paused)
+			waitForResume(t, b)
+			require.NoError(t, b.OnAdd(gs))
+
+			var got []events.EventType
+			for _, event := range broker.events {
+				got = append(got, event.EventType())
+			}
+			require.Equal(t, []events.EventType{"gameserver.events.added", "gameserver.events.updated", "gameserver.events.deleted", "gameserver.events.added"}, got)
+
+			oldGS, newGS, ok := events.UpdatedAs[*v1.GameServer](broker.events[1].(events.Message))
+			require.True(t, ok)
+			require.Equal(t, "simple-udp", oldGS.Name)
+			require.Equal(t, v1.GameServerStateAllocated, newGS.Status.State)
+			require.Equal(t, uint64(2), events.MetadataOf(broker.events[1].(events.Message)).Sequence)
+		})
+	}
+}
+
+func Test_Broadcaster_Pause_Checkpoint(t *testing.T) {
+	broker := &recorder{}
+	b := New(nil, broker, &Config{})
+	require.NoError(t, b.error)
+	b.tracker = checkpoint.NewTracker(manager.Scheme)
+
+	gs := newGameServerCreatedAt("simple-udp", time.Now())
+	key := checkpoint.Key(checkpoint.Kind(gs, manager.Scheme), gs.Namespace, gs.Name)
+
+	b.Pause()
+	require.NoError(t, b.OnAdd(gs))
+	_, ok, _ := b.tracker.Get(key)
+	require.False(t, ok, "it should not record held events on the checkpoint")
+
+	b.Resume()
+	waitForResume(t, b)
+	_, ok, _ = b.tracker.Get(key)
+	require.True(t, ok, "it should record held events once published")
+}
+
+func Test_Broadcaster_Pause_Leftover(t *testing.T) {
+	dir := t.TempDir()
+
+	b := New(nil, &recorder{}, &Config{Pause: &PauseConfig{Dir: dir}})
+	require.NoError(t, b.error)
+	b.Pause()
+	require.NoError(t, b.OnAdd(newGameServerCreatedAt("first", time.Now())))
+	require.NoError(t, b.OnAdd(newGameServerCreatedAt("second", time.Now())))
+
+	// The broadcaster stops while paused, the next run publishes the events left on the file before new events
+	broker := &recorder{}
+	b = New(nil, broker, &Config{Pause: &PauseConfig{Dir: dir}})
+	require.NoError(t, b.error)
+	state := b.PauseState()
+	require.False(t, state.Paused)
+	require.Equal(t, 2, state.Buffered)
+
+	require.NoError(t, b.OnAdd(newGameServerCreatedAt("third", time.Now())))
+	require.Empty(t, broker.events, "it should buffer new events until the events left are published")
+
+	require.True(t, b.gate.takeLeftover())
+	b.drain()
+
+	var got []string
+	for _, event := range broker.events {
+		gs, ok := events.ObjectAs[*v1.GameServer](event.(events.Message))
+		require.True(t, ok)
+		got = append(got, gs.Name)
+	}
+	require.Equal(t, []string{"first", "second", "third"}, got)
+}
+
+func Test_Broadcaster_Pause_Drop(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		config      *PauseConfig
+		wantBuffer  int
+		wantDropped uint64
+	}{
+		{
+			desc:        "it should drop events while paused with the drop policy",
+			config:      &PauseConfig{Policy: PAUSE_POLICY_DROP},
+			wantDropped: 2,
+		},
+		{
+			desc:        "it should drop events once the buffer is full",
+			config:      &PauseConfig{BufferSize: 1},
+			wantBuffer:  1,
+			wantDropped: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			broker := &recorder{}
+			b := New(nil, broker, &Config{Pause: tc.config})
+			require.NoError(t, b.error)
+
+			b.Pause()
+			var dropped uint64
+			for _, name := range []string{"first", "second"} {
+				if err := b.OnAdd(newGameServerCreatedAt(name, time.Now())); err != nil {
+					require.ErrorIs(t, err, handlers.ErrDropped, "it should not report dropped events as published")
+					dropped++
+				}
+			}
+			require.Equal(t, tc.wantDropped, dropped)
+
+			state := b.PauseState()
+			require.Equal(t, tc.wantBuffer, state.Buffered)
+			require.Equal(t, tc.wantDropped, state.Dropped)
+
+			b.Resume()
+			waitForResume(t, b)
+			require.Len(t, broker.events, tc.wantBuffer)
+		})
+	}
+
+	t.Run("it should fail on unknown policies", func(t *testing.T) {
+		b := New(nil, &recorder{}, &Config{Pause: &PauseConfig{Policy: "block"}})
+		require.Error(t, b.error)
+	})
+}
+
+func Test_encodePending(t *testing.T) {
+	gs := newGameServerCreatedAt("simple-udp", time.Now())
+	meta := &events.Metadata{ID: "event-id", ClusterName: "us-central1"}
+
+	testCases := []struct {
+		desc  string
+		event events.Event
+	}{
+		{
+			desc:  "it should decode snapshot events",
+			event: events.OnSnapshot(&events.AddedMessage{Obj: gs, Meta: meta}),
+		},
+		{
+			desc:  "it should decode deleting events",
+			event: events.OnDeleting(&events.UpdatedMessage{Old: gs, New: gs, Meta: meta}),
+		},
+		{
+			desc:  "it should decode snapshot completed events",
+			event: events.SnapshotCompleted(&events.SnapshotMessage{Snapshot: &events.Snapshot{Kind: "GameServer", Count: 1}, Meta: meta}),
+		},
+		{
+			desc: "it should decode diagnostic events",
+			event: events.NewDiagnosticEvent(events.GameServerPodOOMKilled, &events.DiagnosticMessage{
+				Diagnostic: &events.Diagnostic{GameServer: "simple-udp", Reason: "OOMKilled"},
+				Obj:        gs,
+				Meta:       meta,
+			}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r, err := encodePending(&pending{cluster: "us-central1", brokers: []string{"pubsub"}, event: tc.event})
+			require.NoError(t, err)
+
+			data, err := json.Marshal(r)
+			require.NoError(t, err)
+			decoded := &record{}
+			require.NoError(t, json.Unmarshal(data, decoded))
+
+			p, err := decodePending(decoded)
+			require.NoError(t, err)
+			require.Equal(t, "us-central1", p.cluster)
+			require.Equal(t, []string{"pubsub"}, p.brokers)
+			require.Equal(t, tc.event.EventType(), p.event.EventType())
+			require.Equal(t, tc.event.EventSource(), p.event.EventSource())
+			require.Equal(t, "event-id", events.MetadataOf(p.event.(events.Message)).ID)
+
+			if obj, ok := events.ObjectAs[*v1.GameServer](tc.event.(events.Message)); ok {
+				got, ok := events.ObjectAs[*v1.GameServer](p.event.(events.Message))
+				require.True(t, ok)
+				require.Equal(t, obj.Name, got.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package broadcaster
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+// PAUSE_BUFFER_FILE is the name of the file where events are buffered when the pause buffer directory is set
+const PAUSE_BUFFER_FILE = "pause-buffer.jsonl"
+
+const (
+	messageAdded      = "added"
+	messageUpdated    = "updated"
+	messageDeleted    = "deleted"
+	messageSnapshot   = "snapshot"
+	messageDiagnostic = "diagnostic"
+)
+
+// memoryQueue buffers pending events in memory
+type memoryQueue struct {
+	items []*pending
+}
+
+func (q *memoryQueue) push(p *pending) error {
+	q.items = append(q.items, p)
+	return nil
+}
+
+func (q *memoryQueue) pop() (*pending, error) {
+	if len(q.items) == 0 {
+		return nil, io.EOF
+	}
+
+	p := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+
+	return p, nil
+}
+
+func (q *memoryQueue) len() int {
+	return len(q.items)
+}
+
+// fileQueue buffers pending events on a file, one JSON record per line. The file is truncated once every event
+// has been read. Events left on the file by a previous run are kept and read first. A record partially written
+// when the previous run stopped is discarded.
+type fileQueue struct {
+	writer *os.File
+	file   *os.File
+	reader *bufio.Reader
+	count  int
+}
+
+// record is the encoded version of a pending event. Resources are encoded with their kind so they can be decoded
+// to their type again. Content is the content of messages without resources, like diagnostics and snapshots.
+type record struct {
+	Cluster   string             `json:"cluster,omitempty"`
+	Brokers   []string           `json:"brokers,omitempty"`
+	Tracked   bool               `json:"tracked,omitempty"`
+	Source    events.EventSource `json:"source"`
+	Type      events.EventType   `json:"type"`
+	Message   string             `json:"message"`
+	Metadata  *events.Metadata   `json:"metadata,omitempty"`
+	Kind      *resourceKind      `json:"kind,omitempty"`
+	Object    json.RawMessage    `json:"object,omitempty"`
+	OldObject json.RawMessage    `json:"old_object,omitempty"`
+	Content   json.RawMessage    `json:"content,omitempty"`
+}
+
+type resourceKind struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+func newFileQueue(dir string) (*fileQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "error creating pause buffer directory")
+	}
+
+	path := filepath.Join(dir, PAUSE_BUFFER_FILE)
+	count, size, err := countRecords(path)
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening pause buffer file")
+	}
+
+	if err := writer.Truncate(size); err != nil {
+		writer.Close()
+		return nil, errors.Wrap(err, "error truncating pause buffer file")
+	}
+
+	// Records are read using a different file so reading and appending don't move each other's offset
+	file, err := os.Open(path)
+	if err != nil {
+		writer.Close()
+		return nil, errors.Wrap(err, "error opening pause buffer file")
+	}
+
+	return &fileQueue{writer: writer, file: file, reader: bufio.NewReader(file), count: count}, nil
+}
+
+// countRecords returns the number of complete records left on the file by a previous run and the size of the file
+// up to the end of the last one
+func countRecords(path string) (int, int64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "error opening pause buffer file")
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var count int
+	var size int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return count, size, nil
+		}
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "error reading pause buffer file")
+		}
+
+		count++
+		size += int64(len(line))
+	}
+}
+
+func (q *fileQueue) push(p *pending) error {
+	r, err := encodePending(p)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "error encoding buffered event")
+	}
+
+	if _, err := q.writer.Write(append(data, '\n')); err != nil {
+		return errors.Wrap(err, "error writing buffered event")
+	}
+	q.count++
+
+	return nil
+}
+
+func (q *fileQueue) pop() (*pending, error) {
+	if q.count == 0 {
+		return nil, io.EOF
+	}
+
+	line, err := q.reader.ReadBytes('\n')
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading buffered event")
+	}
+	q.count--
+
+	if q.count == 0 {
+		if err := q.reset(); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &record{}
+	if err := json.Unmarshal(line, r); err != nil {
+		return nil, errors.Wrap(err, "error decoding buffered event")
+	}
+
+	return decodePending(r)
+}
+
+func (q *fileQueue) len() int {
+	return q.count
+}
+
+// reset truncates the file once every event has been read, so it doesn't grow while paused several times
+func (q *fileQueue) reset() error {
+	if err := q.writer.Truncate(0); err != nil {
+		return errors.Wrap(err, "error truncating pause buffer file")
+	}
+
+	if _, err := q.file.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "error truncating pause buffer file")
+	}
+	q.reader.Reset(q.file)
+
+	return nil
+}
+
+// encodePending encodes the event of the pending event. Only the events built by the broadcaster can be encoded.
+func encodePending(p *pending) (*record, error) {
+	message, ok := p.event.(events.Message)
+	if !ok {
+		return nil, errors.Errorf("event %s can't be buffered on a file", p.event.EventType())
+	}
+
+	r := &record{
+		Cluster:  p.cluster,
+		Brokers:  p.brokers,
+		Tracked:  p.resource != nil,
+		Source:   p.event.EventSource(),
+		Type:     p.event.EventType(),
+		Metadata: events.MetadataOf(message),
+	}
+
+	var err error
+	switch content := message.Content().(type) {
+	case *events.UpdatedMessage:
+		r.Message = messageUpdated
+		if r.Kind, r.Object, err = encodeObject(content.New); err != nil {
+			return nil, err
+		}
+		if content.Old != nil {
+			if _, r.OldObject, err = encodeObject(content.Old); err != nil {
+				return nil, err
+			}
+		}
+	case *events.Snapshot:
+		r.Message = messageSnapshot
+		if r.Content, err = json.Marshal(content); err != nil {
+			return nil, errors.Wrap(err, "error encoding snapshot")
+		}
+	case *events.Diagnostic:
+		r.Message = messageDiagnostic
+		if r.Content, err = json.Marshal(content); err != nil {
+			return nil, errors.Wrap(err, "error encoding diagnostic")
+		}
+		if obj, ok := events.ResourceObject(message); ok {
+			if r.Kind, r.Object, err = encodeObject(obj); err != nil {
+				return nil, err
+			}
+		}
+	case runtime.Object:
+		r.Message = messageAdded
+		if r.Source == events.EventSourceOnDelete {
+			r.Message = messageDeleted
+		}
+		if r.Kind, r.Object, err = encodeObject(content); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("event %s can't be buffered on a file", p.event.EventType())
+	}
+
+	return r, nil
+}
+
+// decodePending builds the event of the record again, using the same factories used when it was published
+func decodePending(r *record) (*pending, error) {
+	p := &pending{cluster: r.Cluster, brokers: r.Brokers}
+
+	var candidates []events.Event
+	switch r.Message {
+	case messageAdded:
+		obj, err := decodeObject(r.Kind, r.Object)
+		if err != nil {
+			return nil, err
+		}
+		p.message = &events.AddedMessage{Obj: obj, Meta: r.Metadata}
+		candidates = []events.Event{events.OnAdded(p.message), events.OnSnapshot(p.message)}
+	case messageUpdated:
+		newObj, err := decodeObject(r.Kind, r.Object)
+		if err != nil {
+			return nil, err
+		}
+		message := &events.UpdatedMessage{New: newObj, Meta: r.Metadata}
+		if len(r.OldObject) > 0 {
+			if message.Old, err = decodeObject(r.Kind, r.OldObject); err != nil {
+				return nil, err
+			}
+		}
+		p.message = message
+		candidates = []events.Event{events.OnUpdated(p.message), events.OnDeleting(p.message)}
+	case messageDeleted:
+		obj, err := decodeObject(r.Kind, r.Object)
+		if err != nil {
+			return nil, err
+		}
+		p.message = &events.DeletedMessage{Obj: obj, Meta: r.Metadata}
+		candidates = []events.Event{events.OnDeleted(p.message)}
+	case messageSnapshot:
+		snapshot := &events.Snapshot{}
+		if err := json.Unmarshal(r.Content, snapshot); err != nil {
+			return nil, errors.Wrap(err, "error decoding snapshot")
+		}
+		candidates = []events.Event{events.SnapshotCompleted(&events.SnapshotMessage{Snapshot: snapshot, Meta: r.Metadata})}
+	case messageDiagnostic:
+		message := &events.DiagnosticMessage{Diagnostic: &events.Diagnostic{}, Meta: r.Metadata}
+		if err := json.Unmarshal(r.Content, message.Diagnostic); err != nil {
+			return nil, errors.Wrap(err, "error decoding diagnostic")
+		}
+		if r.Kind != nil {
+			obj, err := decodeObject(r.Kind, r.Object)
+			if err != nil {
+				return nil, err
+			}
+			message.Obj = obj
+		}
+		candidates = []events.Event{events.NewDiagnosticEvent(events.DiagnosticEventType(r.Type), message)}
+	}
+
+	// The factories of a message may build events of several types, e.g. updated and deleting
+	for _, event := range candidates {
+		if event != nil && event.EventType() == r.Type && event.EventSource() == r.Source {
+			p.event = event
+			if r.Tracked && p.message != nil {
+				p.resource, _ = events.ResourceObject(p.message)
+			}
+			return p, nil
+		}
+	}
+
+	return nil, errors.Errorf("no event factory registered for buffered event %s", r.Type)
+}
+
+func encodeObject(obj runtime.Object) (*resourceKind, json.RawMessage, error) {
+	gvk, err := apiutil.GVKForObject(obj, manager.Scheme)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error encoding resource")
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error encoding resource")
+	}
+
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	return &resourceKind{APIVersion: apiVersion, Kind: kind}, data, nil
+}
+
+// decodeObject decodes the resource to its type. Kinds not registered on the scheme are decoded as unstructured objects.
+func decodeObject(kind *resourceKind, data json.RawMessage) (runtime.Object, error) {
+	if kind == nil {
+		return nil, errors.New("error decoding resource: kind is missing")
+	}
+
+	gvk := schema.FromAPIVersionAndKind(kind.APIVersion, kind.Kind)
+	obj, err := manager.Scheme.New(gvk)
+	if err != nil {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		obj = u
+	}
+
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, errors.Wrap(err, "error decoding resource")
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,99 @@
+package broadcaster
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+// rebalancer publishes a snapshot event for every resource the replica gains when the sharding members change.
+// Events of those resources were discarded by the replica while they were assigned to another member, e.g. a member
+// which Lease was expiring, so consumers receive their current state.
+type rebalancer struct {
+	broadcaster *Broadcaster
+	mgr         *manager.Manager
+	mutex       sync.Mutex
+	previous    []string
+	pending     bool
+	notify      chan struct{}
+}
+
+func newRebalancer(b *Broadcaster, mgr *manager.Manager) *rebalancer {
+	return &rebalancer{
+		broadcaster: b,
+		mgr:         mgr,
+		notify:      make(chan struct{}, 1),
+	}
+}
+
+// changed is called by the sharder when the members change. Changes are handled one at a time, the members before
+// the first change not handled yet are kept so every resource gained since then is published.
+func (r *rebalancer) changed(previous []string) {
+	r.mutex.Lock()
+	if !r.pending {
+		r.previous, r.pending = previous, true
+	}
+	r.mutex.Unlock()
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the members before the changes not handled yet
+func (r *rebalancer) take() []string {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+
+	r.pending = false
+	return r.previous
+}
+
+// Start publishes the resources gained on every change of the members until ctx is cancelled
+func (r *rebalancer) Start(ctx context.Context) error {
+	if !r.mgr.GetCache().WaitForCacheSync(ctx) {
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.notify:
+			r.broadcaster.rebalance(ctx, r.mgr.GetClient(), r.mgr.GetScheme(), r.take())
+		}
+	}
+}
+
+// NeedLeaderElection returns false so every replica publishes the resources it gains
+func (r *rebalancer) NeedLeaderElection() bool {
+	return false
+}
+
+// rebalance publishes a snapshot event for every resource assigned to the replica that was assigned to another
+// member of previous
+func (b *Broadcaster) rebalance(ctx context.Context, reader client.Reader, scheme *runtime.Scheme, previous []string) {
+	gained := func(obj runtime.Object) bool {
+		ok, err := b.sharder.Gained(previous, obj)
+		if err != nil {
+			b.logger.WithError(err).Warn("error deciding if the resource was gained, snapshot event will not be published")
+		}
+
+		return ok
+	}
+
+	for _, w := range b.watchers {
+		if w.cacheOnly || w.newHandler != nil {
+			continue
+		}
+
+		if _, err := b.publishSnapshots(ctx, reader, scheme, w.obj, gained, ""); err != nil {
+			b.logger.WithError(err).Errorf("error publishing resources of %T gained by the replica", w.obj)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package broadcaster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/sharding"
+)
+
+func Test_Broadcaster_rebalance(t *testing.T) {
+	ctx := context.Background()
+	var objects []client.Object
+	for i := 0; i < 20; i++ {
+		objects = append(objects, newGameServerCreatedAt(fmt.Sprintf("gameserver-%d", i), time.Now()))
+	}
+	c := fake.NewClientBuilder().WithScheme(manager.Scheme).WithObjects(objects...).Build()
+
+	broker := &recorder{}
+	b := New(nil, broker, &Config{}).WithWatcherFor(&v1.GameServer{})
+	require.NoError(t, b.error)
+
+	var err error
+	b.sharder, err = sharding.New(&sharding.Config{Group: "broadcaster", Identity: "broadcaster-0", Namespace: "default"}, c, c)
+	require.NoError(t, err)
+	require.NoError(t, b.sharder.Sync(ctx))
+
+	previous := []string{"broadcaster-0", "broadcaster-1"}
+	b.rebalance(ctx, c, manager.Scheme, previous)
+
+	var gained []string
+	for _, object := range objects {
+		if sharding.Owner(previous, "default/"+object.GetName()) == "broadcaster-1" {
+			gained = append(gained, object.GetName())
+		}
+	}
+	require.NotEmpty(t, gained)
+
+	t.Run("it should publish a snapshot event for the resources assigned to the member that left", func(t *testing.T) {
+		var got []string
+		for _, event := range broker.events[:len(broker.events)-1] {
+			require.Equal(t, events.EventType(events.GameServerEventSnapshot), event.EventType())
+			gs, ok := events.ObjectAs[*v1.GameServer](event.(events.Message))
+			require.True(t, ok)
+			got = append(got, gs.Name)
+		}
+		require.ElementsMatch(t, gained, got)
+	})
+
+	t.Run("it should publish the snapshot completed event", func(t *testing.T) {
+		completed := broker.events[len(broker.events)-1]
+		require.Equal(t, events.EventType(events.SnapshotEventCompleted), completed.EventType())
+		require.Equal(t, len(gained), completed.(events.Message).Content().(*events.Snapshot).Count)
+	})
+}
+
+func Test_rebalancer_changed(t *testing.T) {
+	r := newRebalancer(nil, nil)
+	r.changed([]string{"broadcaster-0", "broadcaster-1", "broadcaster-2"})
+	r.changed([]string{"broadcaster-0", "broadcaster-1"})
+
+	require.Len(t, r.notify, 1)
+	require.Equal(t, []string{"broadcaster-0", "broadcaster-1", "broadcaster-2"}, r.take(), "it should keep the members before the first change not handled")
+
+	r.changed([]string{"broadcaster-0"})
+	require.Equal(t, []string{"broadcaster-0"}, r.take())
+}
@@ -0,0 +1,158 @@
+package broadcaster
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+// ErrInvalidReplayRequest is the cause of the errors of requests replaying unknown brokers or kinds
+var ErrInvalidReplayRequest = errors.New("invalid replay request")
+
+// ReplayRequest selects the resources replayed and the broker their events are published to.
+// Broker is the name of the broker, e.g. pubsub or kafka. Events are published to every broker if empty.
+// Kinds, e.g. GameServer or Fleet, Namespaces and Selector restrict the resources replayed. Empty fields match every resource.
+type ReplayRequest struct {
+	Broker     string
+	Kinds      []string
+	Namespaces []string
+	Selector   labels.Selector
+}
+
+// ReplayResult is the number of resources replayed for every cluster and kind.
+// Clusters which caches are not synced are not replayed, their Error is set instead.
+type ReplayResult struct {
+	Broker string          `json:"broker,omitempty"`
+	Kinds  []*ReplayedKind `json:"kinds"`
+}
+
+// ReplayedKind is the snapshot of a kind of resource replayed from a cluster
+type ReplayedKind struct {
+	Cluster string `json:"cluster,omitempty"`
+	*events.Snapshot
+	Error string `json:"error,omitempty"`
+}
+
+// Replay publishes a snapshot event for every resource on the caches matching the request, followed by a
+// snapshot.events.completed event for each kind, so consumers can rebuild the state of the resources.
+// Events pass the filters of the broadcaster and the broker. While publishing is paused, they are buffered or dropped.
+func (b *Broadcaster) Replay(ctx context.Context, req *ReplayRequest) (*ReplayResult, error) {
+	if req.Broker != "" && !b.hasBroker(req.Broker) {
+		return nil, errors.Wrapf(ErrInvalidReplayRequest, "unknown broker %q", req.Broker)
+	}
+
+	watchers, err := b.replayWatchers(req.Kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	include := func(obj runtime.Object) bool {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return false
+		}
+
+		if len(req.Namespaces) > 0 && !contains(req.Namespaces, accessor.GetNamespace()) {
+			return false
+		}
+
+		return req.Selector == nil || req.Selector.Matches(labels.Set(accessor.GetLabels()))
+	}
+
+	clusters := b.children
+	if len(clusters) == 0 {
+		clusters = []*Broadcaster{b}
+	}
+
+	result := &ReplayResult{Broker: req.Broker, Kinds: []*ReplayedKind{}}
+	for _, cluster := range clusters {
+		reader := cluster.status.source(cluster.clusterName).Reader
+		if reader == nil {
+			result.Kinds = append(result.Kinds, &ReplayedKind{Cluster: cluster.clusterName, Error: "caches are not synced"})
+			continue
+		}
+
+		for _, w := range watchers {
+			snapshot, err := cluster.publishSnapshots(ctx, reader, manager.Scheme, w.obj, include, req.Broker)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error replaying resources of cluster %q", cluster.clusterName)
+			}
+
+			result.Kinds = append(result.Kinds, &ReplayedKind{Cluster: cluster.clusterName, Snapshot: snapshot})
+		}
+	}
+
+	b.logger.WithField("broker", req.Broker).Infof("replayed %d kinds of resources", len(result.Kinds))
+
+	return result, nil
+}
+
+// replayWatchers returns the watchers of the kinds, or every watcher publishing events if empty. Kinds are case-insensitive.
+func (b *Broadcaster) replayWatchers(kinds []string) ([]*watcher, error) {
+	var watchers []*watcher
+	found := map[string]bool{}
+	for _, w := range b.watchers {
+		if w.cacheOnly || w.newHandler != nil {
+			continue
+		}
+
+		gvk, err := apiutil.GVKForObject(w.obj, manager.Scheme)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(kinds) > 0 && !containsFold(kinds, gvk.Kind) {
+			continue
+		}
+
+		found[strings.ToLower(gvk.Kind)] = true
+		watchers = append(watchers, w)
+	}
+
+	for _, kind := range kinds {
+		if !found[strings.ToLower(kind)] {
+			return nil, errors.Wrapf(ErrInvalidReplayRequest, "kind %q is not watched", kind)
+		}
+	}
+
+	return watchers, nil
+}
+
+// hasBroker returns true if a broker is named name
+func (b *Broadcaster) hasBroker(name string) bool {
+	for _, r := range b.routes {
+		if r.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,147 @@
+package broadcaster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+func newReplayBroadcaster(t *testing.T, broker, replayed *recorder) *Broadcaster {
+	ranked := newGameServerCreatedAt("ranked", time.Now())
+	ranked.Labels = map[string]string{"mode": "ranked"}
+	casual := newGameServerCreatedAt("casual", time.Now())
+	casual.Labels = map[string]string{"mode": "casual"}
+
+	b := New(nil, broker, &Config{Admin: true}).
+		WithBroker(&checkedRecorder{recorder: *replayed}, "").
+		WithWatcherFor(&v1.GameServer{}).
+		WithWatcherFor(&v1.Fleet{})
+	require.NoError(t, b.error)
+	b.status.reader = fake.NewClientBuilder().WithScheme(manager.Scheme).WithObjects(ranked, casual).Build()
+
+	return b
+}
+
+func Test_Broadcaster_Replay(t *testing.T) {
+	broker := &recorder{}
+	b := newReplayBroadcaster(t, broker, &recorder{})
+	replayed := &b.routes[1].broker.(*checkedRecorder).recorder
+
+	t.Run("it should publish the snapshot of the matching resources only to the broker", func(t *testing.T) {
+		result, err := b.Replay(context.Background(), &ReplayRequest{
+			Broker:   "checkedrecorder",
+			Kinds:    []string{"gameserver"},
+			Selector: labels.SelectorFromSet(labels.Set{"mode": "ranked"}),
+		})
+		require.NoError(t, err)
+		require.Len(t, result.Kinds, 1)
+		require.Equal(t, &events.Snapshot{Group: "agones.dev", Kind: "GameServer", Count: 1}, result.Kinds[0].Snapshot)
+
+		require.Empty(t, broker.events)
+		require.Len(t, replayed.events, 2)
+		require.Equal(t, events.EventType(events.GameServerEventSnapshot), replayed.events[0].EventType())
+		gs, ok := events.ObjectAs[*v1.GameServer](replayed.events[0].(events.Message))
+		require.True(t, ok)
+		require.Equal(t, "ranked", gs.Name)
+		require.Equal(t, events.EventType(events.SnapshotEventCompleted), replayed.events[1].EventType())
+	})
+
+	testCases := []struct {
+		desc string
+		req  *ReplayRequest
+	}{
+		{
+			desc: "it should fail on unknown brokers",
+			req:  &ReplayRequest{Broker: "kafka"},
+		},
+		{
+			desc: "it should fail on kinds that are not watched",
+			req:  &ReplayRequest{Kinds: []string{"FleetAutoscaler"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := b.Replay(context.Background(), tc.req)
+			require.Equal(t, ErrInvalidReplayRequest, errors.Cause(err))
+		})
+	}
+}
+
+func Test_Broadcaster_adminHandlers(t *testing.T) {
+	broker := &recorder{}
+	b := newReplayBroadcaster(t, broker, &recorder{})
+	handler := b.Server("").Handler()
+
+	serve := func(method, target string, body interface{}) int {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest(method, target, nil))
+		if body != nil {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(body))
+		}
+		return resp.Code
+	}
+
+	t.Run("it should pause and resume publishing", func(t *testing.T) {
+		state := PauseState{}
+		require.Equal(t, http.StatusOK, serve(http.MethodPost, ADMIN_PAUSE_PATH, &state))
+		require.True(t, state.Paused)
+
+		require.Equal(t, http.StatusOK, serve(http.MethodGet, ADMIN_PAUSE_PATH, &state))
+		require.True(t, state.Paused)
+
+		require.Equal(t, http.StatusOK, serve(http.MethodPost, ADMIN_RESUME_PATH, &state))
+		require.False(t, state.Paused)
+	})
+
+	t.Run("it should replay the resources", func(t *testing.T) {
+		result := ReplayResult{}
+		require.Equal(t, http.StatusOK, serve(http.MethodPost, ADMIN_REPLAY_PATH+"?kind=GameServer&namespace=default", &result))
+		require.Len(t, result.Kinds, 1)
+		require.Equal(t, 2, result.Kinds[0].Count)
+	})
+
+	testCases := []struct {
+		desc   string
+		method string
+		target string
+		want   int
+	}{
+		{
+			desc:   "it should reject invalid label selectors",
+			method: http.MethodPost,
+			target: ADMIN_REPLAY_PATH + "?labelSelector=mode+in+ranked",
+			want:   http.StatusBadRequest,
+		},
+		{
+			desc:   "it should reject unknown brokers",
+			method: http.MethodPost,
+			target: ADMIN_REPLAY_PATH + "?broker=kafka",
+			want:   http.StatusBadRequest,
+		},
+		{
+			desc:   "it should only resume on POST requests",
+			method: http.MethodGet,
+			target: ADMIN_RESUME_PATH,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Equal(t, tc.want, serve(tc.method, tc.target, nil))
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package broadcaster
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/checkpoint"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+// resumer publishes the changes that happened while the broadcaster was not running.
+// It runs once the caches are synced and, when leader election is enabled, only on the leader.
+type resumer struct {
+	broadcaster *Broadcaster
+	mgr         *manager.Manager
+	store       checkpoint.Store
+}
+
+// addResumer adds the runnables that restore and save the checkpoint of the cluster
+func (b *Broadcaster) addResumer(mgr *manager.Manager) error {
+	store, err := b.checkpointStore(mgr)
+	if err != nil {
+		return err
+	}
+
+	b.tracker = checkpoint.NewTracker(mgr.GetScheme())
+	if err := mgr.Add(&resumer{broadcaster: b, mgr: mgr, store: store}); err != nil {
+		return err
+	}
+
+	return mgr.Add(checkpoint.NewSaver(b.tracker, store, b.config.Checkpoint.Interval))
+}
+
+// checkpointStore returns the store of the checkpoint of the cluster.
+// Replicas sharing resources save their checkpoint on their own ConfigMap, named after their sharding identity.
+func (b *Broadcaster) checkpointStore(mgr *manager.Manager) (checkpoint.Store, error) {
+	config := b.config.Checkpoint
+	name := b.clusterName
+	if name == "" {
+		name = "default"
+	}
+
+	if config.Dir != "" {
+		return checkpoint.NewFileStore(filepath.Join(config.Dir, name+".json")), nil
+	}
+
+	configMap := config.ConfigMap
+	if b.config.Sharding != nil {
+		configMap += "-" + b.shardIdentity()
+	}
+
+	// The ConfigMap is read from the API server so the cache doesn't watch every ConfigMap of the cluster
+	return checkpoint.NewConfigMapStore(mgr.GetClient(), mgr.GetAPIReader(), config.Namespace, configMap)
+}
+
+// Start restores the checkpoint and publishes the differences with the resources of every watcher handled by the broadcaster.
+// Events are published as added when the checkpoint can't be read.
+func (r *resumer) Start(ctx context.Context) error {
+	b := r.broadcaster
+	state, err := r.store.Load(ctx)
+	if err != nil {
+		b.logger.WithError(err).Error("error loading checkpoint, resources will be published as added")
+	}
+	b.tracker.Restore(state)
+
+	if !b.listInitial(ctx, r.mgr) {
+		return nil
+	}
+
+	for _, w := range b.watchers {
+		if w.cacheOnly || w.newHandler != nil {
+			continue
+		}
+
+		if err := b.resume(ctx, r.mgr.GetClient(), r.mgr.GetScheme(), w.obj); err != nil {
+			b.logger.WithError(err).Errorf("error resuming %T", w.obj)
+		}
+	}
+
+	return nil
+}
+
+// resume publishes the differences between the checkpoint and the resources of the type of obj:
+// added events for resources that are not on the checkpoint, updated events for resources that changed
+// and deleted events for resources on the checkpoint that no longer exist. Resources published since the broadcaster
+// started are skipped.
+func (b *Broadcaster) resume(ctx context.Context, reader client.Reader, scheme *runtime.Scheme, obj client.Object) error {
+	list, err := newListFor(obj, scheme)
+	if err != nil {
+		return err
+	}
+
+	if err := reader.List(ctx, list); err != nil {
+		return errors.Wrap(err, "error listing resources")
+	}
+
+	kind := checkpoint.Kind(obj, scheme)
+	seen := map[string]bool{}
+	var added, updated, deleted int
+
+	err = meta.EachListItem(list, func(item runtime.Object) error {
+		accessor, err := meta.Accessor(item)
+		if err != nil {
+			return err
+		}
+
+		key := checkpoint.Key(kind, accessor.GetNamespace(), accessor.GetName())
+		seen[key] = true
+
+		entry, ok, live := b.tracker.Get(key)
+		if live || !b.existed(item) {
+			return nil
+		}
+
+		var publish func() error
+		switch {
+		case !ok:
+			added++
+			publish = func() error { return b.add(item) }
+		case entry.UID != string(accessor.GetUID()):
+			// The resource was deleted and created again with the same name.
+			// Both events are retried on their own, so a failed delete doesn't skip the add and a failed add doesn't publish the delete again.
+			deleted++
+			added++
+			b.retry(ctx, func() error {
+				return b.OnDeleteWithState(deletedFrom(obj, entry), true)
+			})
+			publish = func() error { return b.add(item) }
+		default:
+			hash, err := checkpoint.Hash(item)
+			if err != nil || hash == entry.Hash {
+				return nil
+			}
+			updated++
+			publish = func() error { return b.resumeUpdate(item) }
+		}
+
+		b.retry(ctx, publish)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range b.tracker.Restored(kind) {
+		if seen[entry.Key()] {
+			continue
+		}
+
+		deleted++
+		// Entries are removed once the delete event is published, failed ones are retried on the next start
+		b.retry(ctx, func() error {
+			return b.OnDeleteWithState(deletedFrom(obj, entry), true)
+		})
+	}
+
+	b.logger.WithField("kind", kind).Infof("resumed from checkpoint: %d added, %d updated and %d deleted", added, updated, deleted)
+
+	return nil
+}
+
+// resumeUpdate publishes an update event for a resource that changed while the broadcaster was not running.
+// The previous version of the resource is unknown, so the message doesn't carry it.
+func (b *Broadcaster) resumeUpdate(resource runtime.Object) error {
+	message := &events.UpdatedMessage{
+		New:  resource,
+		Meta: b.metadataFor(resource, false),
+	}
+
+	return b.dispatch(&delivery{event: events.OnUpdated(message), project: b.projectEvent, resource: resource})
+}
+
+// deletedFrom returns a resource of the type of obj with the identity of the entry. It is the last state known of
+// resources deleted while the broadcaster was not running.
+func deletedFrom(obj client.Object, entry checkpoint.Entry) client.Object {
+	deleted := obj.DeepCopyObject().(client.Object)
+	deleted.SetNamespace(entry.Namespace)
+	deleted.SetName(entry.Name)
+	deleted.SetUID(types.UID(entry.UID))
+	deleted.SetResourceVersion(entry.ResourceVersion)
+
+	return deleted
+}
+
+// retry publishes events for a short time, e.g. while the sharding members are synced
+func (b *Broadcaster) retry(ctx context.Context, publish func() error) {
+	if err := retryPublish(ctx, publish); err != nil {
+		b.logger.WithError(err).Error("error publishing resumed event")
+	}
+}
+
+// retryPublish publishes events for a short time, e.g. while the brokers recover or the sharding members are synced.
+// Events are retried only by the brokers that failed to publish them. Dropped events are not retried.
+func retryPublish(ctx context.Context, publish func() error) error {
+	retriable := func(err error) bool { return ctx.Err() == nil && !errors.Is(err, handlers.ErrDropped) }
+	return retry.OnError(retry.DefaultBackoff, retriable, func() error {
+		err := publish()
+		if failed, ok := handlers.FailedEvents(err); ok {
+			publish = func() error { return handlers.Republish(failed) }
+		}
+
+		return err
+	})
+}
@@ -0,0 +1,197 @@
+package broadcaster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/checkpoint"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/sharding"
+)
+
+func Test_Broadcaster_resume(t *testing.T) {
+	createdAt := time.Now().Add(-time.Hour)
+
+	unchanged := newGameServerCreatedAt("unchanged", createdAt)
+	changed := newGameServerCreatedAt("changed", createdAt)
+	recreated := newGameServerCreatedAt("recreated", createdAt)
+	created := newGameServerCreatedAt("created", createdAt)
+	deleted := newGameServerCreatedAt("deleted", createdAt)
+	for _, gs := range []*v1.GameServer{unchanged, changed, recreated, created, deleted} {
+		gs.UID = types.UID("uid-" + gs.Name)
+		gs.Status.State = v1.GameServerStateReady
+	}
+
+	state := checkpoint.State{}
+	for _, gs := range []*v1.GameServer{unchanged, changed, recreated, deleted} {
+		entry, err := checkpoint.NewEntry(gs, manager.Scheme)
+		require.NoError(t, err)
+		state[entry.Key()] = entry
+	}
+
+	changed.Status.State = v1.GameServerStateAllocated
+	recreated.UID = "uid-recreated-again"
+	reader := fake.NewClientBuilder().WithScheme(manager.Scheme).WithObjects(unchanged, changed, recreated, created).Build()
+
+	broker := &recorder{}
+	b := New(nil, broker, &Config{StartupMode: STARTUP_MODE_RESUME, Checkpoint: &checkpoint.Config{Dir: t.TempDir()}})
+	require.NoError(t, b.error)
+	withInitialList(b, unchanged, changed, recreated, created)
+	b.tracker = checkpoint.NewTracker(manager.Scheme)
+	b.tracker.Restore(state)
+
+	require.NoError(t, b.resume(context.Background(), reader, manager.Scheme, &v1.GameServer{}))
+
+	got := map[string][]events.EventType{}
+	for _, event := range broker.events {
+		gs, ok := events.ObjectAs[*v1.GameServer](event.(events.Message))
+		require.True(t, ok)
+		got[gs.Name] = append(got[gs.Name], event.EventType())
+	}
+
+	require.Equal(t, map[string][]events.EventType{
+		"changed":   {events.EventType(events.GameServerEventUpdated)},
+		"recreated": {events.EventType(events.GameServerEventDeleted), events.EventType(events.GameServerEventAdded)},
+		"created":   {events.EventType(events.GameServerEventAdded)},
+		"deleted":   {events.EventType(events.GameServerEventDeleted)},
+	}, got)
+
+	t.Run("it should publish deleted events with the final state unknown", func(t *testing.T) {
+		for _, event := range broker.events {
+			if event.EventType() == events.EventType(events.GameServerEventDeleted) {
+				require.True(t, events.MetadataOf(event.(events.Message)).FinalStateUnknown)
+			}
+		}
+	})
+
+	t.Run("it should publish updated events without the previous version", func(t *testing.T) {
+		for _, event := range broker.events {
+			if updated, ok := event.(events.Message).Content().(*events.UpdatedMessage); ok {
+				require.Nil(t, updated.Old)
+			}
+		}
+	})
+
+	t.Run("it should not publish the changes again", func(t *testing.T) {
+		broker.events = nil
+		require.NoError(t, b.resume(context.Background(), reader, manager.Scheme, &v1.GameServer{}))
+		require.Empty(t, broker.events)
+	})
+
+	t.Run("it should fail without a checkpoint", func(t *testing.T) {
+		b := New(nil, broker, &Config{StartupMode: STARTUP_MODE_RESUME})
+		require.Error(t, b.error)
+	})
+}
+
+// failingTypeRecorder is a broker that fails to publish the first failures events of eventType
+type failingTypeRecorder struct {
+	recorder
+	eventType events.EventType
+	failures  int
+}
+
+func (r *failingTypeRecorder) SendMessage(envelope *events.Envelope) error {
+	if r.failures > 0 && envelope.Message.(events.Event).EventType() == r.eventType {
+		r.failures--
+		return errors.New("broker unavailable")
+	}
+
+	return r.recorder.SendMessage(envelope)
+}
+
+func Test_Broadcaster_resume_Recreated(t *testing.T) {
+	gs := newGameServerCreatedAt("recreated", time.Now().Add(-time.Hour))
+	gs.UID = "uid-recreated"
+
+	entry, err := checkpoint.NewEntry(gs, manager.Scheme)
+	require.NoError(t, err)
+
+	gs.UID = "uid-recreated-again"
+	reader := fake.NewClientBuilder().WithScheme(manager.Scheme).WithObjects(gs).Build()
+
+	testCases := []struct {
+		desc      string
+		eventType events.EventType
+	}{
+		{
+			desc:      "it should publish the added event after retrying the deleted event",
+			eventType: events.EventType(events.GameServerEventDeleted),
+		},
+		{
+			desc:      "it should retry the added event without publishing the deleted event again",
+			eventType: events.EventType(events.GameServerEventAdded),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			broker := &failingTypeRecorder{eventType: tc.eventType, failures: 1}
+			b := New(nil, broker, &Config{StartupMode: STARTUP_MODE_RESUME, Checkpoint: &checkpoint.Config{Dir: t.TempDir()}})
+			require.NoError(t, b.error)
+			withInitialList(b, gs)
+			b.tracker = checkpoint.NewTracker(manager.Scheme)
+			b.tracker.Restore(checkpoint.State{entry.Key(): entry})
+
+			require.NoError(t, b.resume(context.Background(), reader, manager.Scheme, &v1.GameServer{}))
+
+			var got []events.EventType
+			for _, event := range broker.events {
+				got = append(got, event.EventType())
+			}
+			require.Equal(t, []events.EventType{
+				events.EventType(events.GameServerEventDeleted),
+				events.EventType(events.GameServerEventAdded),
+			}, got)
+		})
+	}
+}
+
+func Test_Broadcaster_checkpoint(t *testing.T) {
+	gs := newGameServerCreatedAt("simple-udp", time.Now())
+	key := checkpoint.Key(checkpoint.Kind(gs, manager.Scheme), gs.Namespace, gs.Name)
+
+	t.Run("it should not record filtered events", func(t *testing.T) {
+		b := New(nil, &recorder{}, &Config{Filter: `object.metadata.name == "other"`})
+		require.NoError(t, b.error)
+		b.tracker = checkpoint.NewTracker(manager.Scheme)
+
+		require.NoError(t, b.OnAdd(gs))
+		_, ok, _ := b.tracker.Get(key)
+		require.False(t, ok)
+	})
+
+	t.Run("it should record events once every broker published them", func(t *testing.T) {
+		b := New(nil, &failingRecorder{failures: 1}, &Config{})
+		require.NoError(t, b.error)
+		b.tracker = checkpoint.NewTracker(manager.Scheme)
+
+		failed, ok := handlers.FailedEvents(b.OnAdd(gs))
+		require.True(t, ok)
+		_, ok, _ = b.tracker.Get(key)
+		require.False(t, ok)
+
+		require.NoError(t, handlers.Republish(failed))
+		_, ok, _ = b.tracker.Get(key)
+		require.True(t, ok)
+	})
+
+	t.Run("it should name the checkpoint of a replica after its sharding identity", func(t *testing.T) {
+		b := New(nil, &recorder{}, &Config{
+			Instance:   "broadcaster-7d9f8-x2x9k",
+			Sharding:   &sharding.Config{Identity: "broadcaster-0"},
+			Checkpoint: &checkpoint.Config{ConfigMap: "checkpoint"},
+		})
+		require.NoError(t, b.error)
+		require.Equal(t, "broadcaster-0", b.shardIdentity())
+	})
+}
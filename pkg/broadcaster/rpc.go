@@ -0,0 +1,17 @@
+package broadcaster
+
+import (
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/rpc"
+)
+
+// addRPC adds the broker serving the published events over gRPC. It is added after the brokers passed to the broadcaster,
+// so events are sent to subscribers once those brokers published them. Resources are listed from the stream snapshot.
+func (b *Broadcaster) addRPC() {
+	if b.config.RPC == nil || b.rpc != nil {
+		return
+	}
+
+	b.rpc = rpc.New(b.config.RPC, manager.Scheme, b.streamSnapshot)
+	b.WithBroker(b.rpc, "")
+}
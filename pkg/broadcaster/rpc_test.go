@@ -0,0 +1,27 @@
+package broadcaster
+
+import (
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/rpc"
+	"github.com/Octops/agones-event-broadcaster/pkg/stream"
+)
+
+func Test_Broadcaster_addRPC(t *testing.T) {
+	b := New(nil, &recorder{}, &Config{
+		RPC:    &rpc.Config{},
+		Stream: &stream.Config{},
+	}).WithWatcherFor(&v1.GameServer{})
+	require.NoError(t, b.error)
+
+	b.addRPC()
+	b.addStream()
+
+	require.Len(t, b.routes, 3)
+	require.Equal(t, b.rpc, b.routes[1].broker)
+	require.Equal(t, "rpc", b.routes[1].name)
+	require.Equal(t, b.stream, b.routes[2].broker)
+}
@@ -0,0 +1,186 @@
+package broadcaster
+
+import (
+	"context"
+	"runtime"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/pkg/errors"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
+	"github.com/Octops/agones-event-broadcaster/pkg/checkpoint"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/query"
+	"github.com/Octops/agones-event-broadcaster/pkg/server"
+)
+
+// DebugState is the state of the broadcaster served on the /debug endpoint
+type DebugState struct {
+	Instance       string              `json:"instance"`
+	StartupMode    StartupMode         `json:"startup_mode,omitempty"`
+	LeaderElection bool                `json:"leader_election"`
+	ShardingGroup  string              `json:"sharding_group,omitempty"`
+	Brokers        []string            `json:"brokers"`
+	Watchers       []string            `json:"watchers"`
+	Clusters       []ClusterDebugState `json:"clusters"`
+	Pause          PauseState          `json:"pause"`
+	Goroutines     int                 `json:"goroutines"`
+}
+
+// ClusterDebugState is the status of a cluster served on the /debug endpoint
+type ClusterDebugState struct {
+	Name   string `json:"name,omitempty"`
+	Synced bool   `json:"synced"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Server returns the server of the health, readiness and debug endpoints of the broadcaster listening on address.
+// The broadcaster is ready once the caches of every cluster are synced and the health checks of its brokers pass.
+// The GameServers and Fleets watched by the broadcaster are served by the query API on /api/v1.
+// When the stream is enabled, events are streamed on /events using Server-Sent Events and on /events/ws using WebSockets.
+// When Admin is set, publishing is paused and resumed on /admin/pause and /admin/resume, and resources replayed on /admin/replay.
+// It must be called after Build.
+func (b *Broadcaster) Server(address string) *server.Server {
+	srv := server.New(&server.Config{Address: address, Profiling: b.config.Profiling}, func() interface{} {
+		return b.Debug()
+	})
+
+	for name, check := range b.readyChecks() {
+		srv.AddReadyCheck(name, check)
+	}
+
+	if api := b.queryAPI(); api != nil {
+		srv.Handle(query.PREFIX, api)
+	}
+
+	if b.stream != nil {
+		srv.Handle("/events", b.stream.SSEHandler())
+		srv.Handle("/events/ws", b.stream.WebSocketHandler())
+	}
+
+	if b.config.Admin {
+		for path, handler := range b.adminHandlers() {
+			srv.Handle(path, handler)
+		}
+	}
+
+	return srv
+}
+
+// queryAPI returns the API serving the GameServers and Fleets watched by the broadcaster. It is nil if neither is watched.
+func (b *Broadcaster) queryAPI() *query.API {
+	var resources []string
+	if b.watches(&v1.GameServer{}) {
+		resources = append(resources, query.RESOURCE_GAMESERVERS)
+	}
+	if b.watches(&v1.Fleet{}) {
+		resources = append(resources, query.RESOURCE_FLEETS)
+	}
+
+	if len(resources) == 0 {
+		return nil
+	}
+
+	api, err := query.New(&query.Config{Resources: resources, Projector: b.projector, Scheme: manager.Scheme}, b.querySources)
+	if err != nil {
+		b.logger.WithError(err).Error("error creating query API")
+		return nil
+	}
+
+	return api
+}
+
+// querySources returns the caches of every cluster
+func (b *Broadcaster) querySources() []query.Source {
+	if len(b.children) == 0 {
+		return []query.Source{b.status.source(b.clusterName)}
+	}
+
+	sources := make([]query.Source, 0, len(b.children))
+	for _, child := range b.children {
+		sources = append(sources, child.status.source(child.clusterName))
+	}
+
+	return sources
+}
+
+// Debug returns the state of the broadcaster
+func (b *Broadcaster) Debug() DebugState {
+	state := DebugState{
+		Instance:       b.instance,
+		StartupMode:    b.config.StartupMode,
+		LeaderElection: b.config.LeaderElection != nil,
+		Brokers:        []string{},
+		Watchers:       []string{},
+		Clusters:       []ClusterDebugState{},
+		Pause:          b.PauseState(),
+		Goroutines:     runtime.NumGoroutine(),
+	}
+
+	if b.config.Sharding != nil {
+		state.ShardingGroup = b.config.Sharding.Group
+	}
+
+	for _, r := range b.routes {
+		state.Brokers = append(state.Brokers, r.name)
+	}
+
+	for _, w := range b.watchers {
+		if !w.cacheOnly {
+			state.Watchers = append(state.Watchers, checkpoint.Kind(w.obj, manager.Scheme))
+		}
+	}
+
+	for _, status := range b.Clusters() {
+		cluster := ClusterDebugState{Name: status.Name, Synced: status.Synced}
+		if status.Error != nil {
+			cluster.Error = status.Error.Error()
+		}
+		state.Clusters = append(state.Clusters, cluster)
+	}
+
+	return state
+}
+
+// readyChecks returns the checks of the caches of every cluster and of the brokers implementing brokers.HealthChecker
+func (b *Broadcaster) readyChecks() map[string]server.Check {
+	checks := map[string]server.Check{}
+
+	clusters := b.children
+	if len(clusters) == 0 {
+		clusters = []*Broadcaster{b}
+	}
+
+	for _, cluster := range clusters {
+		name := "cache-sync"
+		if len(b.children) > 0 {
+			name = "cache-sync-" + cluster.clusterName
+		}
+		checks[name] = cluster.cacheSynced
+	}
+
+	for _, r := range b.routes {
+		checker, ok := r.broker.(brokers.HealthChecker)
+		if !ok {
+			continue
+		}
+
+		checks["broker-"+r.name] = checker.HealthCheck
+	}
+
+	return checks
+}
+
+// cacheSynced reports an error until the caches of the cluster are synced
+func (b *Broadcaster) cacheSynced(ctx context.Context) error {
+	status := b.status.get(b.clusterName)
+	if status.Synced {
+		return nil
+	}
+
+	if status.Error != nil {
+		return errors.Wrap(status.Error, "cluster failed")
+	}
+
+	return errors.New("caches are not synced")
+}
@@ -0,0 +1,58 @@
+package broadcaster
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+// checkedRecorder is a broker that reports its health
+type checkedRecorder struct {
+	recorder
+	err error
+}
+
+func (r *checkedRecorder) HealthCheck(ctx context.Context) error {
+	return r.err
+}
+
+func Test_Broadcaster_readyChecks(t *testing.T) {
+	unhealthy := &checkedRecorder{err: errors.New("topic does not exist")}
+
+	t.Run("it should check the cache and the brokers implementing health checks", func(t *testing.T) {
+		b := New(nil, &recorder{}, &Config{}).WithBroker(unhealthy, "").WithBroker(&checkedRecorder{}, "")
+		require.NoError(t, b.error)
+
+		checks := b.readyChecks()
+		require.Len(t, checks, 3)
+		require.Error(t, checks["cache-sync"](context.Background()))
+		require.Error(t, checks["broker-checkedrecorder"](context.Background()))
+		require.NoError(t, checks["broker-checkedrecorder-2"](context.Background()))
+
+		b.status.synced = true
+		require.NoError(t, checks["cache-sync"](context.Background()))
+	})
+
+	t.Run("it should check the cache of every cluster", func(t *testing.T) {
+		clusters := []Cluster{
+			{Name: "us-central1", RestConfig: &rest.Config{}},
+			{Name: "europe-west1", RestConfig: &rest.Config{}},
+		}
+		b := NewMultiCluster(clusters, &recorder{}, &Config{})
+		require.NoError(t, b.error)
+		for _, cluster := range clusters {
+			b.children = append(b.children, b.forCluster(cluster, "0"))
+		}
+
+		b.children[0].status.fail("us-central1", errors.New("connection refused"))
+		b.children[1].status.synced = true
+
+		checks := b.readyChecks()
+		require.Len(t, checks, 2)
+		require.ErrorContains(t, checks["cache-sync-us-central1"](context.Background()), "connection refused")
+		require.NoError(t, checks["cache-sync-europe-west1"](context.Background()))
+	})
+}
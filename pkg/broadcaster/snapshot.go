@@ -0,0 +1,237 @@
+package broadcaster
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+// snapshotter publishes a snapshot event for every resource that existed when the broadcaster started.
+// It runs once the caches are synced and, when leader election is enabled, only on the leader.
+type snapshotter struct {
+	broadcaster *Broadcaster
+	mgr         *manager.Manager
+}
+
+// Start publishes the snapshot of the resources of every watcher handled by the broadcaster
+func (s *snapshotter) Start(ctx context.Context) error {
+	b := s.broadcaster
+	if !b.listInitial(ctx, s.mgr) {
+		return nil
+	}
+
+	for _, w := range b.watchers {
+		if w.cacheOnly || w.newHandler != nil {
+			continue
+		}
+
+		if err := b.snapshot(ctx, s.mgr.GetClient(), s.mgr.GetScheme(), w.obj); err != nil {
+			b.logger.WithError(err).Errorf("error publishing snapshot of %T", w.obj)
+		}
+	}
+
+	return nil
+}
+
+// initialLister records the resources that existed when the broadcaster started publishing the events of the cluster.
+// It runs on every startup mode, since the lag of added events is only observed for resources created afterwards.
+type initialLister struct {
+	broadcaster *Broadcaster
+	mgr         *manager.Manager
+}
+
+func (l *initialLister) Start(ctx context.Context) error {
+	l.broadcaster.listInitial(ctx, l.mgr)
+	return nil
+}
+
+// initialList holds the UIDs of the resources on the cache the first time it is synced after the broadcaster started
+// publishing the events of the cluster. UIDs is nil until the resources are listed.
+type initialList struct {
+	mutex sync.RWMutex
+	uids  map[types.UID]bool
+}
+
+// listInitial lists the resources of every watcher handled by the broadcaster once the cache is synced. Only the first
+// caller after the manager is built lists them: the initial lister, the snapshotter or the resumer. They only run on the
+// leader when leader election is enabled, so a replica elected after a failover takes the resources that existed
+// when it was elected as existing, instead of the ones that existed when it started on standby.
+// It returns false if ctx is cancelled before the cache is synced.
+func (b *Broadcaster) listInitial(ctx context.Context, mgr *manager.Manager) bool {
+	if !mgr.GetCache().WaitForCacheSync(ctx) {
+		return false
+	}
+
+	b.recordInitialList(ctx, mgr.GetClient(), mgr.GetScheme())
+	return true
+}
+
+// recordInitialList records the UIDs of the resources of every watcher listed from reader, unless they are already recorded
+func (b *Broadcaster) recordInitialList(ctx context.Context, reader client.Reader, scheme *runtime.Scheme) {
+	defer b.initial.mutex.Unlock()
+	b.initial.mutex.Lock()
+
+	if b.initial.uids != nil {
+		return
+	}
+
+	uids := map[types.UID]bool{}
+	for _, w := range b.watchers {
+		if w.cacheOnly || w.newHandler != nil {
+			continue
+		}
+
+		list, err := newListFor(w.obj, scheme)
+		if err == nil {
+			err = reader.List(ctx, list)
+		}
+		if err != nil {
+			// Resources of the kind are taken as created after the broadcaster started
+			b.logger.WithError(err).Errorf("error listing the initial resources of %T", w.obj)
+			continue
+		}
+
+		_ = meta.EachListItem(list, func(item runtime.Object) error {
+			if accessor, err := meta.Accessor(item); err == nil {
+				uids[accessor.GetUID()] = true
+			}
+			return nil
+		})
+	}
+	b.initial.uids = uids
+}
+
+// resetInitialList forgets the initial resources, so they are listed again once the new manager starts publishing
+func (b *Broadcaster) resetInitialList() {
+	defer b.initial.mutex.Unlock()
+	b.initial.mutex.Lock()
+
+	b.initial.uids = nil
+}
+
+// existed returns true if the resource was on the cache the first time it was synced after the broadcaster started.
+// Resources are taken as existing until they are listed, since the events handled meanwhile are the ones of the
+// resources listed while the cache is syncing.
+func (b *Broadcaster) existed(obj runtime.Object) bool {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+
+	defer b.initial.mutex.RUnlock()
+	b.initial.mutex.RLock()
+
+	return b.initial.uids == nil || b.initial.uids[accessor.GetUID()]
+}
+
+// snapshot publishes a snapshot event for every resource of the type of obj that existed when the broadcaster started,
+// followed by a snapshot.events.completed event
+func (b *Broadcaster) snapshot(ctx context.Context, reader client.Reader, scheme *runtime.Scheme, obj client.Object) error {
+	_, err := b.publishSnapshots(ctx, reader, scheme, obj, b.existed, "")
+	return err
+}
+
+// publishSnapshots publishes a snapshot event for every resource of the type of obj that is included, followed by a
+// snapshot.events.completed event. Events are published only to the brokers named broker, or every broker if empty.
+func (b *Broadcaster) publishSnapshots(ctx context.Context, reader client.Reader, scheme *runtime.Scheme, obj client.Object, include func(runtime.Object) bool, broker string) (*events.Snapshot, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := newListFor(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reader.List(ctx, list); err != nil {
+		return nil, errors.Wrap(err, "error listing resources")
+	}
+
+	snapshot := &events.Snapshot{Group: gvk.Group, Kind: gvk.Kind}
+	err = meta.EachListItem(list, func(item runtime.Object) error {
+		if !include(item) {
+			return nil
+		}
+
+		snapshot.Count++
+		// Events are retried for a short time, e.g. while the sharding members are synced
+		if err := retryPublish(ctx, func() error {
+			return b.publishSnapshot(item, broker)
+		}); err != nil {
+			snapshot.Failed++
+			b.logger.WithError(err).Error("error publishing snapshot event")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b.logger.WithField("kind", gvk.Kind).Infof("snapshot of %d resources completed", snapshot.Count)
+
+	return snapshot, b.dispatch(&delivery{
+		event: events.SnapshotCompleted(&events.SnapshotMessage{
+			Snapshot: snapshot,
+			Meta:     b.metadataFor(nil, false),
+		}),
+		brokers: brokerNames(broker),
+	})
+}
+
+// publishSnapshot publishes the snapshot event of the resource to the brokers named broker, or every broker if empty
+func (b *Broadcaster) publishSnapshot(resource runtime.Object, broker string) error {
+	message := &events.AddedMessage{
+		Obj:  resource,
+		Meta: b.metadataFor(resource, false),
+	}
+
+	return b.dispatch(&delivery{event: events.OnSnapshot(message), project: b.projectEvent, brokers: brokerNames(broker)})
+}
+
+// brokerNames returns the brokers named broker, or every broker if empty
+func brokerNames(broker string) []string {
+	if broker == "" {
+		return nil
+	}
+
+	return []string{broker}
+}
+
+// newListFor returns an empty list of the type of obj
+func newListFor(obj client.Object, scheme *runtime.Scheme) (client.ObjectList, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+	gvk.Kind += "List"
+
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk)
+		return list, nil
+	}
+
+	list, err := scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	objList, ok := list.(client.ObjectList)
+	if !ok {
+		return nil, errors.Errorf("%s is not a list", gvk)
+	}
+
+	return objList, nil
+}
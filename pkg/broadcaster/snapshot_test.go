@@ -0,0 +1,146 @@
+package broadcaster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+func newGameServerCreatedAt(name string, createdAt time.Time) *v1.GameServer {
+	return &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			UID:               types.UID("uid-" + name),
+			CreationTimestamp: metav1.NewTime(createdAt),
+		},
+	}
+}
+
+// withInitialList records objs as the resources on the cache when the broadcaster started
+func withInitialList(b *Broadcaster, objs ...client.Object) {
+	defer b.initial.mutex.Unlock()
+	b.initial.mutex.Lock()
+
+	b.initial.uids = map[types.UID]bool{}
+	for _, obj := range objs {
+		b.initial.uids[obj.GetUID()] = true
+	}
+}
+
+func Test_Broadcaster_OnAdd_StartupMode(t *testing.T) {
+	// Resources are classified by the initial list, not by their creation timestamp
+	existing := newGameServerCreatedAt("existing", time.Now().Add(time.Hour))
+	created := newGameServerCreatedAt("created", time.Now().Add(-time.Hour))
+
+	testCases := []struct {
+		desc string
+		mode StartupMode
+		want []string
+	}{
+		{
+			desc: "it should publish added events for existing resources by default",
+			want: []string{"existing", "created"},
+		},
+		{
+			desc: "it should only publish added events for resources created after the broadcaster started on snapshot mode",
+			mode: STARTUP_MODE_SNAPSHOT,
+			want: []string{"created"},
+		},
+		{
+			desc: "it should only publish added events for resources created after the broadcaster started on skip mode",
+			mode: STARTUP_MODE_SKIP,
+			want: []string{"created"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			broker := &recorder{}
+			b := New(nil, broker, &Config{StartupMode: tc.mode})
+			require.NoError(t, b.error)
+			withInitialList(b, existing)
+
+			require.NoError(t, b.OnAdd(existing))
+			require.NoError(t, b.OnAdd(created))
+
+			var got []string
+			for _, event := range broker.events {
+				require.Equal(t, events.EventSourceOnAdd, event.EventSource())
+				gs, ok := events.ObjectAs[*v1.GameServer](event.(events.Message))
+				require.True(t, ok)
+				got = append(got, gs.Name)
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("it should fail on unknown modes", func(t *testing.T) {
+		b := New(nil, &recorder{}, &Config{StartupMode: "replay"})
+		require.Error(t, b.error)
+	})
+}
+
+func Test_Broadcaster_existed(t *testing.T) {
+	existing := newGameServerCreatedAt("existing", time.Now().Add(time.Hour))
+	created := newGameServerCreatedAt("created", time.Now().Add(-time.Hour))
+
+	b := New(nil, &recorder{}, &Config{StartupMode: STARTUP_MODE_SNAPSHOT}).WithWatcherFor(&v1.GameServer{})
+	require.NoError(t, b.error)
+	require.True(t, b.existed(created), "resources should exist until the initial list is recorded, e.g. while the cache is syncing")
+
+	reader := fake.NewClientBuilder().WithScheme(manager.Scheme).WithObjects(existing).Build()
+	b.recordInitialList(context.Background(), reader, manager.Scheme)
+	require.True(t, b.existed(existing), "it should not compare the creation timestamp")
+	require.False(t, b.existed(created))
+
+	reader = fake.NewClientBuilder().WithScheme(manager.Scheme).WithObjects(existing, created).Build()
+	b.recordInitialList(context.Background(), reader, manager.Scheme)
+	require.False(t, b.existed(created), "it should keep the resources of the first list")
+
+	b.resetInitialList()
+	require.True(t, b.existed(created))
+	b.recordInitialList(context.Background(), reader, manager.Scheme)
+	require.True(t, b.existed(created), "it should list the resources again once a new manager is built")
+}
+
+func Test_Broadcaster_snapshot(t *testing.T) {
+	existing1 := newGameServerCreatedAt("existing-1", time.Now().Add(-time.Hour))
+	existing2 := newGameServerCreatedAt("existing-2", time.Now().Add(-time.Minute))
+	reader := fake.NewClientBuilder().WithScheme(manager.Scheme).WithObjects(
+		existing1,
+		existing2,
+		newGameServerCreatedAt("created", time.Now().Add(-time.Hour)),
+	).Build()
+
+	broker := &recorder{}
+	b := New(nil, broker, &Config{StartupMode: STARTUP_MODE_SNAPSHOT})
+	require.NoError(t, b.error)
+	withInitialList(b, existing1, existing2)
+
+	require.NoError(t, b.snapshot(context.Background(), reader, manager.Scheme, &v1.GameServer{}))
+	require.Len(t, broker.events, 3)
+
+	t.Run("it should publish a snapshot event for resources that existed when the broadcaster started", func(t *testing.T) {
+		for _, event := range broker.events[:2] {
+			require.Equal(t, events.EventType(events.GameServerEventSnapshot), event.EventType())
+			require.Equal(t, events.EventSourceOnSnapshot, event.EventSource())
+		}
+	})
+
+	t.Run("it should publish the snapshot completed event", func(t *testing.T) {
+		completed := broker.events[2]
+		require.Equal(t, events.EventType(events.SnapshotEventCompleted), completed.EventType())
+		require.Equal(t, &events.Snapshot{Group: "agones.dev", Kind: "GameServer", Count: 2}, completed.(events.Message).Content())
+	})
+}
@@ -0,0 +1,115 @@
+package broadcaster
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/stream"
+)
+
+// addStream adds the broker streaming events to the clients of the server. It is the last broker,
+// so events are streamed once every other broker published them.
+func (b *Broadcaster) addStream() {
+	if b.config.Stream == nil || b.stream != nil {
+		return
+	}
+
+	b.stream = stream.New(b.config.Stream, manager.Scheme, b.streamSnapshot)
+	b.WithBroker(b.stream, "")
+}
+
+// streamSnapshot returns a snapshot event for every resource on the caches of the clusters.
+// Events pass the global filter and are projected and transformed, but not enriched.
+func (b *Broadcaster) streamSnapshot(ctx context.Context) ([]events.Event, error) {
+	clusters := b.children
+	if len(clusters) == 0 {
+		clusters = []*Broadcaster{b}
+	}
+
+	var snapshot []events.Event
+	for _, cluster := range clusters {
+		reader := cluster.status.source(cluster.clusterName).Reader
+		if reader == nil {
+			continue
+		}
+
+		for _, w := range b.watchers {
+			if w.cacheOnly || w.newHandler != nil {
+				continue
+			}
+
+			list, err := newListFor(w.obj, manager.Scheme)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := reader.List(ctx, list); err != nil {
+				return nil, errors.Wrap(err, "error listing resources")
+			}
+
+			err = meta.EachListItem(list, func(item runtime.Object) error {
+				event, err := cluster.snapshotEvent(item)
+				if event != nil {
+					snapshot = append(snapshot, event)
+				}
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// snapshotEvent returns the snapshot event of the resource. It is nil if the event doesn't pass the global filter
+// or the resource is not assigned to the broadcaster instance.
+func (b *Broadcaster) snapshotEvent(resource runtime.Object) (events.Event, error) {
+	metadata := &events.Metadata{
+		ID:          string(uuid.NewUUID()),
+		ObservedAt:  time.Now().UTC(),
+		ClusterName: b.clusterName,
+		Instance:    b.instance,
+	}
+	if accessor, err := meta.Accessor(resource); err == nil {
+		// Snapshots are not part of the sequence of events of the resource
+		metadata.ResourceUID = string(accessor.GetUID())
+		metadata.ResourceVersion = accessor.GetResourceVersion()
+	}
+
+	message := &events.AddedMessage{Obj: resource, Meta: metadata}
+	event := events.OnSnapshot(message)
+	if event == nil {
+		return nil, nil
+	}
+
+	if owned, err := b.owns(event); err != nil || !owned {
+		return nil, nil
+	}
+
+	if ok, err := b.filter.Match(event); err != nil || !ok {
+		return nil, nil
+	}
+
+	projected, err := b.project(resource)
+	if err != nil {
+		return nil, err
+	}
+	message.Obj = projected
+
+	if b.transformer != nil {
+		if event, err = b.transformer.Transform(event); err != nil {
+			return nil, errors.Wrap(err, "error transforming event")
+		}
+	}
+
+	return event, nil
+}
@@ -0,0 +1,60 @@
+package broadcaster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+	"github.com/Octops/agones-event-broadcaster/pkg/stream"
+)
+
+func Test_Broadcaster_streamSnapshot(t *testing.T) {
+	ready := newGameServerCreatedAt("ready", time.Now())
+	ready.Status.State = v1.GameServerStateReady
+	ready.Annotations = map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"}
+	allocated := newGameServerCreatedAt("allocated", time.Now())
+	allocated.Status.State = v1.GameServerStateAllocated
+
+	b := New(nil, &recorder{}, &Config{
+		ClusterName: "us-central1",
+		Filter:      `object.status.state == "Ready"`,
+		Stream:      &stream.Config{},
+	}).WithWatcherFor(&v1.GameServer{})
+	require.NoError(t, b.error)
+	b.addStream()
+
+	t.Run("it should add the stream as the last broker", func(t *testing.T) {
+		require.Len(t, b.routes, 2)
+		require.Equal(t, b.stream, b.routes[1].broker)
+	})
+
+	t.Run("it should be empty while the caches are not synced", func(t *testing.T) {
+		snapshot, err := b.streamSnapshot(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, snapshot)
+	})
+
+	t.Run("it should return the projected snapshot events matching the filter", func(t *testing.T) {
+		b.status.reader = fake.NewClientBuilder().WithScheme(manager.Scheme).WithObjects(ready, allocated).Build()
+
+		snapshot, err := b.streamSnapshot(context.Background())
+		require.NoError(t, err)
+		require.Len(t, snapshot, 1)
+		require.Equal(t, events.EventSourceOnSnapshot, snapshot[0].EventSource())
+
+		message := snapshot[0].(events.Message)
+		require.Equal(t, "us-central1", events.MetadataOf(message).ClusterName)
+		require.Zero(t, events.MetadataOf(message).Sequence)
+
+		gs, ok := events.ObjectAs[*v1.GameServer](message)
+		require.True(t, ok)
+		require.Equal(t, "ready", gs.Name)
+		require.Empty(t, gs.Annotations)
+	})
+}
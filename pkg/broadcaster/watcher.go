@@ -0,0 +1,133 @@
+package broadcaster
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+// WatcherOption restricts the resources watched by a controller added using WithWatcherFor.
+// Restrictions are applied to the manager cache so resources that don't match are never listed from the Kubernetes API.
+type WatcherOption func(w *watcher) error
+
+// watcher holds the resource type watched by a controller and its cache restrictions
+type watcher struct {
+	obj        client.Object
+	namespaces []string
+	label      labels.Selector
+	field      fields.Selector
+	// newHandler returns the handler of the events of the controller. Defaults to the broadcaster of the cluster.
+	newHandler func(b *Broadcaster, mgr *manager.Manager) (handlers.EventHandler, error)
+	// cacheOnly watchers only restrict the cache for resources read by the broadcaster. No controller is created.
+	cacheOnly bool
+}
+
+// InNamespaces restricts the watcher to resources deployed on the namespaces.
+// It overrides the namespaces set on the broadcaster Config for this resource type.
+func InNamespaces(namespaces ...string) WatcherOption {
+	return func(w *watcher) error {
+		w.namespaces = namespaces
+		return nil
+	}
+}
+
+// WithLabelSelector restricts the watcher to resources matching the selector. For example: mode=ranked,region in (eu,us)
+func WithLabelSelector(selector string) WatcherOption {
+	return func(w *watcher) error {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return errors.Wrapf(err, "invalid label selector %q", selector)
+		}
+
+		w.label = parsed
+		return nil
+	}
+}
+
+// WithFieldSelector restricts the watcher to resources matching the selector. For example: metadata.name=simple-udp
+// Only fields supported by the Kubernetes API for the resource type can be used.
+func WithFieldSelector(selector string) WatcherOption {
+	return func(w *watcher) error {
+		parsed, err := fields.ParseSelector(selector)
+		if err != nil {
+			return errors.Wrapf(err, "invalid field selector %q", selector)
+		}
+
+		w.field = parsed
+		return nil
+	}
+}
+
+// WithWatcherForKind adds a controller for resources of the kind that are watched as unstructured objects.
+// Event types are derived from the kind, e.g. matchticket.events.added, so custom resources are published without
+// a typed object or event factory.
+func (b *Broadcaster) WithWatcherForKind(gvk schema.GroupVersionKind, options ...WatcherOption) *Broadcaster {
+	if b.error != nil {
+		return b
+	}
+
+	if gvk.Kind == "" || gvk.Version == "" {
+		b.error = errors.Errorf("invalid kind %q, version and kind are required", gvk.String())
+		return b
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+
+	if _, ok := events.EventFactoryRegistry[events.ResourceMessageKind(obj)]; !ok {
+		events.RegisterUnstructuredEventFactory(gvk)
+	}
+
+	return b.WithWatcherFor(obj, options...)
+}
+
+func newWatcher(obj client.Object, options ...WatcherOption) (*watcher, error) {
+	w := &watcher{obj: obj}
+	for _, option := range options {
+		if err := option(w); err != nil {
+			return nil, errors.Wrapf(err, "error creating watcher for %s", events.ResourceMessageKind(obj))
+		}
+	}
+
+	return w, nil
+}
+
+// byObject returns the cache restrictions of the watchers
+func byObject(watchers []*watcher) map[client.Object]cache.ByObject {
+	restrictions := map[client.Object]cache.ByObject{}
+	for _, w := range watchers {
+		if len(w.namespaces) == 0 && w.label == nil && w.field == nil {
+			continue
+		}
+
+		restrictions[w.obj] = cache.ByObject{
+			Namespaces: namespaceConfig(w.namespaces),
+			Label:      w.label,
+			Field:      w.field,
+		}
+	}
+
+	return restrictions
+}
+
+// namespaceConfig returns the cache config for the namespaces. It returns nil for all namespaces.
+func namespaceConfig(namespaces []string) map[string]cache.Config {
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	config := make(map[string]cache.Config, len(namespaces))
+	for _, ns := range namespaces {
+		config[ns] = cache.Config{}
+	}
+
+	return config
+}
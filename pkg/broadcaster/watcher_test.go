@@ -0,0 +1,49 @@
+package broadcaster
+
+import (
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_byObject(t *testing.T) {
+	gs := &v1.GameServer{}
+	fleet := &v1.Fleet{}
+
+	gsWatcher, err := newWatcher(gs, InNamespaces("default", "ranked"), WithLabelSelector("mode=ranked"), WithFieldSelector("metadata.name=simple-udp"))
+	require.NoError(t, err)
+
+	fleetWatcher, err := newWatcher(fleet)
+	require.NoError(t, err)
+
+	got := byObject([]*watcher{gsWatcher, fleetWatcher})
+	require.Len(t, got, 1, "watchers without restrictions should use the cache defaults")
+	require.Contains(t, got[gs].Namespaces, "default")
+	require.Contains(t, got[gs].Namespaces, "ranked")
+	require.Equal(t, "mode=ranked", got[gs].Label.String())
+	require.Equal(t, "metadata.name=simple-udp", got[gs].Field.String())
+}
+
+func Test_newWatcher_InvalidSelectors(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		options []WatcherOption
+	}{
+		{
+			desc:    "it should fail on invalid label selectors",
+			options: []WatcherOption{WithLabelSelector("mode==(ranked")},
+		},
+		{
+			desc:    "it should fail on invalid field selectors",
+			options: []WatcherOption{WithFieldSelector("metadata.name")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := newWatcher(&v1.GameServer{}, tc.options...)
+			require.Error(t, err)
+		})
+	}
+}
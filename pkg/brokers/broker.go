@@ -1,9 +1,19 @@
 package brokers
 
-import "github.com/Octops/agones-event-broadcaster/pkg/events"
+import (
+	"context"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
 
 // Broker is the service used by the Broadcaster for publishing events
 type Broker interface {
 	BuildEnvelope(event events.Event) (*events.Envelope, error)
 	SendMessage(envelope *events.Envelope) error
 }
+
+// HealthChecker is implemented by brokers that can report if they are able to publish messages.
+// The broadcaster is not ready while the check of any of its brokers fails. Brokers that don't implement it are always healthy.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
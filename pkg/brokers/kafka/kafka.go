@@ -1,12 +1,21 @@
 package kafka
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/sirupsen/logrus"
 
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
 	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/cloudevents"
+)
+
+var (
+	_ brokers.Broker        = (*KafkaBroker)(nil)
+	_ brokers.HealthChecker = (*KafkaBroker)(nil)
 )
 
 type KafkaBroker struct {
@@ -23,12 +32,16 @@ type Config struct {
 	APIKey           string
 	APISecret        string
 	BootstrapServers string
+	// CloudEvents is optional. When set, events are published as CloudEvents using the Kafka protocol binding
+	CloudEvents *cloudevents.Encoder
 }
 
 const (
 	TOPIC_ID_HEADER_KEY   = "kafka_topic_id"
 	EVENT_TYPE_HEADER_KEY = "kafka_event_type"
 	DEFAULT_TOPIC_ID      = "gameserver.events"
+	// HEALTH_CHECK_TIMEOUT is the maximum amount of time waited for the metadata of the cluster when the context has no deadline
+	HEALTH_CHECK_TIMEOUT = 5 * time.Second
 )
 
 func NewKafkaBroker(config *Config) (*KafkaBroker, error) {
@@ -55,6 +68,16 @@ func (k *KafkaBroker) BuildEnvelope(event events.Event) (*events.Envelope, error
 
 	k.SetEnvelopeHeader(event, envelope)
 
+	if k.CloudEvents != nil {
+		ce, err := k.CloudEvents.Encode(event)
+		if err != nil {
+			return nil, fmt.Errorf("error building cloudevent: %v", err)
+		}
+		envelope.Message = ce
+
+		return envelope, nil
+	}
+
 	envelope.Message = event.(events.Message).Content()
 
 	return envelope, nil
@@ -76,6 +99,7 @@ func (k *KafkaBroker) SetEnvelopeHeader(event events.Event, envelope *events.Env
 
 	envelope.AddHeader(TOPIC_ID_HEADER_KEY, topicID)
 	envelope.AddHeader(EVENT_TYPE_HEADER_KEY, event.EventType().String())
+	envelope.AddMetadataHeaders(events.MetadataOf(event.(events.Message)))
 }
 
 func (k *KafkaBroker) SendMessage(envelope *events.Envelope) error {
@@ -96,9 +120,23 @@ func (k *KafkaBroker) SendMessage(envelope *events.Envelope) error {
 
 }
 
+// HealthCheck checks that the brokers of the cluster are reachable by requesting the metadata of the generic topic
+func (k *KafkaBroker) HealthCheck(ctx context.Context) error {
+	timeout := HEALTH_CHECK_TIMEOUT
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	if _, err := k.Producer.GetMetadata(&k.GenericTopicID, false, int(timeout.Milliseconds())); err != nil {
+		return fmt.Errorf("error getting metadata of topic %s: %v", k.GenericTopicID, err)
+	}
+
+	return nil
+}
+
 // publish publishes the encoded version of the envelope as a message to the kafka topic
 func (k *KafkaBroker) publish(envelope *events.Envelope, topicID string) (string, error) {
-	msg, err := envelope.Encode()
+	msg, headers, err := k.encode(envelope)
 	if err != nil {
 		return "", fmt.Errorf("error encoding envelope: %v", err)
 	}
@@ -106,7 +144,8 @@ func (k *KafkaBroker) publish(envelope *events.Envelope, topicID string) (string
 	k.Producer.Produce(&kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &topicID,
 			Partition: (int32)(kafka.PartitionAny)},
-		Value: []byte(msg)}, nil)
+		Value:   []byte(msg),
+		Headers: headers}, nil)
 
 	// Wait for delivery report
 	e := <-k.Producer.Events()
@@ -118,6 +157,47 @@ func (k *KafkaBroker) publish(envelope *events.Envelope, topicID string) (string
 	return string(message.Key), nil
 }
 
+// encode returns the message value and headers for the envelope.
+// CloudEvents are encoded following the Kafka protocol binding, any other message is encoded as the envelope itself.
+// The trace context of the envelope is added to the headers of every message.
+func (k *KafkaBroker) encode(envelope *events.Envelope) ([]byte, []kafka.Header, error) {
+	msg, attrs, err := k.encodeMessage(envelope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	traceHeaders := envelope.TraceHeaders()
+	if len(attrs)+len(traceHeaders) == 0 {
+		return msg, nil, nil
+	}
+
+	headers := make([]kafka.Header, 0, len(attrs)+len(traceHeaders))
+	for key, value := range attrs {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	for key, value := range traceHeaders {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+
+	return msg, headers, nil
+}
+
+// encodeMessage returns the message value and the attributes of CloudEvents encoded using the binary content mode
+func (k *KafkaBroker) encodeMessage(envelope *events.Envelope) ([]byte, map[string]string, error) {
+	ce, ok := envelope.Message.(*cloudevents.CloudEvent)
+	if !ok {
+		msg, err := envelope.Encode()
+		return msg, nil, err
+	}
+
+	mode := cloudevents.ContentModeStructured
+	if k.CloudEvents != nil {
+		mode = k.CloudEvents.Mode
+	}
+
+	return cloudevents.KafkaBinding.Encode(ce, mode)
+}
+
 func GetTopicIDFromHeader(envelope *events.Envelope) (string, bool) {
 	if topicID, ok := envelope.Header.Headers[TOPIC_ID_HEADER_KEY]; ok {
 		return topicID, true
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/cloudevents"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func newEvent() events.Event {
+	return events.GameServerAdded(&events.EventMessage{Body: &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "simple-udp-agones", Namespace: "default"},
+	}})
+}
+
+// headersOf returns the kafka headers indexed by key
+func headersOf(headers []kafka.Header) map[string]string {
+	indexed := map[string]string{}
+	for _, header := range headers {
+		indexed[header.Key] = string(header.Value)
+	}
+
+	return indexed
+}
+
+func Test_KafkaBroker_encode_TraceContext(t *testing.T) {
+	encoder, err := cloudevents.NewEncoder("us-central1", cloudevents.ContentModeBinary, manager.Scheme)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc        string
+		config      *Config
+		trace       map[string]string
+		wantHeaders map[string]string
+	}{
+		{
+			desc:        "it should add the traceparent header",
+			config:      &Config{},
+			trace:       map[string]string{events.TRACEPARENT_HEADER_KEY: traceparent},
+			wantHeaders: map[string]string{events.TRACEPARENT_HEADER_KEY: traceparent},
+		},
+		{
+			desc:   "it should add the traceparent and tracestate headers",
+			config: &Config{},
+			trace: map[string]string{
+				events.TRACEPARENT_HEADER_KEY: traceparent,
+				events.TRACESTATE_HEADER_KEY:  "vendor=value",
+			},
+			wantHeaders: map[string]string{
+				events.TRACEPARENT_HEADER_KEY: traceparent,
+				events.TRACESTATE_HEADER_KEY:  "vendor=value",
+			},
+		},
+		{
+			desc:   "it should add the traceparent header along the cloudevents attributes",
+			config: &Config{CloudEvents: encoder},
+			trace:  map[string]string{events.TRACEPARENT_HEADER_KEY: traceparent},
+			wantHeaders: map[string]string{
+				events.TRACEPARENT_HEADER_KEY: traceparent,
+				"ce_type":                     events.GameServerEventAdded.String(),
+				"content-type":                cloudevents.DataContentType,
+			},
+		},
+		{
+			desc:   "it should not add headers when tracing is disabled",
+			config: &Config{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tc.config.ApplyDefaults()
+			broker := &KafkaBroker{Config: tc.config}
+
+			envelope, err := broker.BuildEnvelope(newEvent())
+			require.NoError(t, err)
+			for key, value := range tc.trace {
+				envelope.AddHeader(key, value)
+			}
+
+			_, headers, err := broker.encode(envelope)
+			require.NoError(t, err)
+
+			got := headersOf(headers)
+			if len(tc.wantHeaders) == 0 {
+				require.Empty(t, got)
+			}
+			for key, value := range tc.wantHeaders {
+				require.Equal(t, value, got[key], key)
+			}
+			if _, ok := tc.trace[events.TRACESTATE_HEADER_KEY]; !ok {
+				require.NotContains(t, got, events.TRACESTATE_HEADER_KEY)
+			}
+		})
+	}
+}
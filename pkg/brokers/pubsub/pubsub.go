@@ -10,6 +10,7 @@ import (
 
 	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
 	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/cloudevents"
 )
 
 const (
@@ -19,17 +20,22 @@ const (
 	DEFAULT_TOPIC_ID      = "gameserver.events"
 )
 
-var _ brokers.Broker = (*PubSubBroker)(nil)
+var (
+	_ brokers.Broker        = (*PubSubBroker)(nil)
+	_ brokers.HealthChecker = (*PubSubBroker)(nil)
+)
 
 // Config is the data structure that holds the configuration passed to the Google Pub/Sub Broker.
 // GenericTopicID is used when specific events topics are not present and all the events
 // should be published to a single topic. Defaults to "gameserver.events"
+// CloudEvents is optional. When set, events are published as CloudEvents using the Pub/Sub protocol binding.
 type Config struct {
 	ProjectID       string
 	GenericTopicID  string
 	OnAddTopicID    string
 	OnUpdateTopicID string
 	OnDeleteTopicID string
+	CloudEvents     *cloudevents.Encoder
 }
 
 // PubSubBroker is a implementation of the Broker interface that uses Google Cloud PubSub for publishing messages
@@ -60,6 +66,16 @@ func (b *PubSubBroker) BuildEnvelope(event events.Event) (*events.Envelope, erro
 
 	b.SetEnvelopeHeader(event, envelope)
 
+	if b.CloudEvents != nil {
+		ce, err := b.CloudEvents.Encode(event)
+		if err != nil {
+			return nil, fmt.Errorf("error building cloudevent: %v", err)
+		}
+		envelope.Message = ce
+
+		return envelope, nil
+	}
+
 	envelope.Message = event.(events.Message).Content()
 
 	return envelope, nil
@@ -84,6 +100,7 @@ func (b *PubSubBroker) SetEnvelopeHeader(event events.Event, envelope *events.En
 	envelope.AddHeader(TOPIC_ID_HEADER_KEY, topicID)
 	envelope.AddHeader(EVENT_TYPE_HEADER_KEY, event.EventType().String())
 	envelope.AddHeader(PROJECTID_HEADER_KEY, b.ProjectID)
+	envelope.AddMetadataHeaders(events.MetadataOf(event.(events.Message)))
 }
 
 // SendMessage publishes a particular envelope to a Google Pub/Sub topic.
@@ -106,6 +123,23 @@ func (b *PubSubBroker) SendMessage(envelope *events.Envelope) error {
 	return nil
 }
 
+// HealthCheck checks that the topics of every event source exist
+func (b *PubSubBroker) HealthCheck(ctx context.Context) error {
+	checked := map[string]bool{}
+	for _, topicID := range []string{b.GenericTopicID, b.OnAddTopicID, b.OnUpdateTopicID, b.OnDeleteTopicID} {
+		if checked[topicID] {
+			continue
+		}
+		checked[topicID] = true
+
+		if _, err := b.TopicFor(ctx, topicID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // TopicFor returns a topic if it already exists on Google Pub/Sub
 func (b *PubSubBroker) TopicFor(ctx context.Context, topicID string) (*pubsub.Topic, error) {
 	topic := b.Client.Topic(topicID)
@@ -126,7 +160,7 @@ func (b *PubSubBroker) TopicFor(ctx context.Context, topicID string) (*pubsub.To
 
 // publish publishes the encoded version of the envelope as a message to the Google Pub/Sub topic
 func (b *PubSubBroker) publish(ctx context.Context, envelope *events.Envelope, topicID string) (string, error) {
-	msg, err := envelope.Encode()
+	msg, attrs, err := b.encode(envelope)
 	if err != nil {
 		return "", fmt.Errorf("error encoding envelope: %v", err)
 	}
@@ -138,7 +172,8 @@ func (b *PubSubBroker) publish(ctx context.Context, envelope *events.Envelope, t
 
 	// TODO: Implement Publish in batches
 	result := topic.Publish(ctx, &pubsub.Message{
-		Data: msg,
+		Data:       msg,
+		Attributes: attrs,
 	})
 
 	// Block until the result is returned and a server-generated
@@ -151,6 +186,41 @@ func (b *PubSubBroker) publish(ctx context.Context, envelope *events.Envelope, t
 	return id, nil
 }
 
+// encode returns the message data and attributes for the envelope.
+// CloudEvents are encoded following the Pub/Sub protocol binding, any other message is encoded as the envelope itself.
+// The trace context of the envelope is added to the attributes of every message.
+func (b *PubSubBroker) encode(envelope *events.Envelope) ([]byte, map[string]string, error) {
+	msg, attrs, err := b.encodeMessage(envelope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for key, value := range envelope.TraceHeaders() {
+		if attrs == nil {
+			attrs = map[string]string{}
+		}
+		attrs[key] = value
+	}
+
+	return msg, attrs, nil
+}
+
+// encodeMessage returns the message data and the attributes of CloudEvents encoded using the binary content mode
+func (b *PubSubBroker) encodeMessage(envelope *events.Envelope) ([]byte, map[string]string, error) {
+	ce, ok := envelope.Message.(*cloudevents.CloudEvent)
+	if !ok {
+		msg, err := envelope.Encode()
+		return msg, nil, err
+	}
+
+	mode := cloudevents.ContentModeStructured
+	if b.CloudEvents != nil {
+		mode = b.CloudEvents.Mode
+	}
+
+	return cloudevents.PubSubBinding.Encode(ce, mode)
+}
+
 // ApplyDefaults sets default values for the Config used by the PubSubBroker
 func (c *Config) ApplyDefaults() {
 	c.GenericTopicID = CheckEmpty(c.GenericTopicID, DEFAULT_TOPIC_ID)
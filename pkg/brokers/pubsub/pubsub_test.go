@@ -248,3 +248,43 @@ func setup(t *testing.T, projectID, topicID string) *pubsub.Client {
 
 	return client
 }
+
+func Test_PubSubBroker_encode_TraceHeaders(t *testing.T) {
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	testCases := []struct {
+		desc    string
+		headers map[string]string
+		want    map[string]string
+	}{
+		{
+			desc: "it should add the trace context to the attributes",
+			headers: map[string]string{
+				TOPIC_ID_HEADER_KEY:           "gameserver.events",
+				events.TRACEPARENT_HEADER_KEY: traceparent,
+			},
+			want: map[string]string{events.TRACEPARENT_HEADER_KEY: traceparent},
+		},
+		{
+			desc: "it should not add attributes when tracing is disabled",
+			headers: map[string]string{
+				TOPIC_ID_HEADER_KEY: "gameserver.events",
+			},
+			want: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			broker := &PubSubBroker{Config: &Config{}}
+			envelope := &events.Envelope{
+				Header:  &events.Header{Headers: tc.headers},
+				Message: "fakeBody",
+			}
+
+			_, attrs, err := broker.encode(envelope)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, attrs)
+		})
+	}
+}
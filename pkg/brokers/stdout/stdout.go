@@ -16,6 +16,7 @@ type StdoutBroker struct {
 func (s *StdoutBroker) BuildEnvelope(event events.Event) (*events.Envelope, error) {
 	envelope := &events.Envelope{}
 	envelope.AddHeader("event_type", event.EventType().String())
+	envelope.AddMetadataHeaders(events.MetadataOf(event.(events.Message)))
 	envelope.Message = event.(events.Message).Content()
 
 	return envelope, nil
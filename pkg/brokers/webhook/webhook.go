@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/cloudevents"
+)
+
+const (
+	EVENT_TYPE_HEADER_KEY = "webhook_event_type"
+	DEFAULT_TIMEOUT       = 10 * time.Second
+)
+
+var _ brokers.Broker = (*WebhookBroker)(nil)
+
+// Config is the data structure that holds the configuration passed to the Webhook Broker.
+// Headers are added to every request sent to the URL. Timeout defaults to 10 seconds.
+// CloudEvents is optional. When set, events are sent as CloudEvents using the HTTP protocol binding.
+type Config struct {
+	URL         string
+	Headers     map[string]string
+	Timeout     time.Duration
+	CloudEvents *cloudevents.Encoder
+}
+
+// WebhookBroker is an implementation of the Broker interface that POSTs messages to an HTTP endpoint
+type WebhookBroker struct {
+	*Config
+	client *http.Client
+}
+
+func NewWebhookBroker(config *Config) (*WebhookBroker, error) {
+	config.ApplyDefaults()
+
+	if _, err := url.ParseRequestURI(config.URL); err != nil {
+		return nil, fmt.Errorf("invalid webhook url %q: %v", config.URL, err)
+	}
+
+	return &WebhookBroker{
+		Config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// BuildEnvelope builds the envelope for a particular event.
+// It will set the enveloper header and message content
+func (w *WebhookBroker) BuildEnvelope(event events.Event) (*events.Envelope, error) {
+	envelope := &events.Envelope{}
+	envelope.AddHeader(EVENT_TYPE_HEADER_KEY, event.EventType().String())
+	envelope.AddMetadataHeaders(events.MetadataOf(event.(events.Message)))
+
+	if w.CloudEvents != nil {
+		ce, err := w.CloudEvents.Encode(event)
+		if err != nil {
+			return nil, fmt.Errorf("error building cloudevent: %v", err)
+		}
+		envelope.Message = ce
+
+		return envelope, nil
+	}
+
+	envelope.Message = event.(events.Message).Content()
+
+	return envelope, nil
+}
+
+// SendMessage POSTs the envelope to the configured URL. Any response status other than 2xx is considered an error.
+func (w *WebhookBroker) SendMessage(envelope *events.Envelope) error {
+	body, headers, err := w.encode(envelope)
+	if err != nil {
+		return fmt.Errorf("error encoding envelope: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	// Receivers continue the trace of the event from the traceparent header
+	for key, value := range envelope.TraceHeaders() {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		logrus.WithError(err).Errorf("error sending message to %s", w.URL)
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s responded with status %d", w.URL, resp.StatusCode)
+	}
+
+	logrus.WithField("broker", "webhook").Infof("message sent to url:\"%s\" status:\"%d\"", w.URL, resp.StatusCode)
+
+	return nil
+}
+
+// encode returns the request body and headers for the envelope.
+// CloudEvents are encoded following the HTTP protocol binding, any other message is encoded as the envelope itself.
+func (w *WebhookBroker) encode(envelope *events.Envelope) ([]byte, map[string]string, error) {
+	ce, ok := envelope.Message.(*cloudevents.CloudEvent)
+	if !ok {
+		msg, err := envelope.Encode()
+		return msg, map[string]string{"Content-Type": "application/json"}, err
+	}
+
+	mode := cloudevents.ContentModeStructured
+	if w.CloudEvents != nil {
+		mode = w.CloudEvents.Mode
+	}
+
+	return cloudevents.HTTPBinding.Encode(ce, mode)
+}
+
+// ApplyDefaults sets default values for the Config used by the WebhookBroker
+func (c *Config) ApplyDefaults() {
+	if c.Timeout == 0 {
+		c.Timeout = DEFAULT_TIMEOUT
+	}
+}
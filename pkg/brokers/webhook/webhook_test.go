@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/events/cloudevents"
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+// request is the request received by the test server
+type request struct {
+	method string
+	header http.Header
+	body   []byte
+}
+
+// newServer returns a server that responds with status and records the requests it receives
+func newServer(t *testing.T, status int) (*httptest.Server, chan *request) {
+	requests := make(chan *request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests <- &request{method: r.Method, header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+func newEvent() events.Event {
+	return events.GameServerAdded(&events.EventMessage{Body: &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "simple-udp-agones", Namespace: "default"},
+	}})
+}
+
+func Test_NewWebhookBroker(t *testing.T) {
+	t.Run("it should fail on invalid urls", func(t *testing.T) {
+		_, err := NewWebhookBroker(&Config{URL: "not a url"})
+		require.Error(t, err)
+	})
+
+	t.Run("it should set the default timeout", func(t *testing.T) {
+		broker, err := NewWebhookBroker(&Config{URL: "http://localhost:8080/events"})
+		require.NoError(t, err)
+		require.Equal(t, DEFAULT_TIMEOUT, broker.Timeout)
+	})
+}
+
+func Test_WebhookBroker_SendMessage(t *testing.T) {
+	t.Run("it should POST the envelope with the configured headers", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusOK)
+		broker, err := NewWebhookBroker(&Config{
+			URL:     server.URL,
+			Headers: map[string]string{"Authorization": "Bearer token"},
+		})
+		require.NoError(t, err)
+
+		envelope, err := broker.BuildEnvelope(newEvent())
+		require.NoError(t, err)
+		require.NoError(t, broker.SendMessage(envelope))
+
+		req := <-requests
+		require.Equal(t, http.MethodPost, req.method)
+		require.Equal(t, "Bearer token", req.header.Get("Authorization"))
+		require.Equal(t, "application/json", req.header.Get("Content-Type"))
+
+		var got map[string]interface{}
+		require.NoError(t, json.Unmarshal(req.body, &got))
+		require.Equal(t, events.GameServerEventAdded.String(), got["header"].(map[string]interface{})["headers"].(map[string]interface{})[EVENT_TYPE_HEADER_KEY])
+		require.Equal(t, "simple-udp-agones", got["message"].(map[string]interface{})["metadata"].(map[string]interface{})["name"])
+	})
+
+	t.Run("it should propagate the trace context of the envelope", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusOK)
+		broker, err := NewWebhookBroker(&Config{URL: server.URL})
+		require.NoError(t, err)
+
+		envelope, err := broker.BuildEnvelope(newEvent())
+		require.NoError(t, err)
+		envelope.AddHeader(events.TRACEPARENT_HEADER_KEY, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		envelope.AddHeader(events.TRACESTATE_HEADER_KEY, "vendor=value")
+		require.NoError(t, broker.SendMessage(envelope))
+
+		req := <-requests
+		require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", req.header.Get("traceparent"))
+		require.Equal(t, "vendor=value", req.header.Get("tracestate"))
+	})
+
+	t.Run("it should not send the trace context when tracing is disabled", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusOK)
+		broker, err := NewWebhookBroker(&Config{URL: server.URL})
+		require.NoError(t, err)
+
+		envelope, err := broker.BuildEnvelope(newEvent())
+		require.NoError(t, err)
+		require.NoError(t, broker.SendMessage(envelope))
+
+		req := <-requests
+		require.Empty(t, req.header.Values("traceparent"))
+		require.Empty(t, req.header.Values("tracestate"))
+	})
+
+	t.Run("it should not let configured headers override the content type", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusOK)
+		broker, err := NewWebhookBroker(&Config{
+			URL:     server.URL,
+			Headers: map[string]string{"Content-Type": "text/plain"},
+		})
+		require.NoError(t, err)
+
+		envelope, err := broker.BuildEnvelope(newEvent())
+		require.NoError(t, err)
+		require.NoError(t, broker.SendMessage(envelope))
+		require.Equal(t, "application/json", (<-requests).header.Get("Content-Type"))
+	})
+
+	testCases := []struct {
+		desc    string
+		status  int
+		wantErr bool
+	}{
+		{desc: "it should succeed on 200", status: http.StatusOK},
+		{desc: "it should succeed on 202", status: http.StatusAccepted},
+		{desc: "it should succeed on 204", status: http.StatusNoContent},
+		{desc: "it should fail on 3xx", status: http.StatusNotModified, wantErr: true},
+		{desc: "it should fail on 4xx", status: http.StatusNotFound, wantErr: true},
+		{desc: "it should fail on 5xx", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server, _ := newServer(t, tc.status)
+			broker, err := NewWebhookBroker(&Config{URL: server.URL})
+			require.NoError(t, err)
+
+			envelope, err := broker.BuildEnvelope(newEvent())
+			require.NoError(t, err)
+
+			err = broker.SendMessage(envelope)
+			if tc.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("it should fail when the endpoint is not reachable", func(t *testing.T) {
+		server, _ := newServer(t, http.StatusOK)
+		server.Close()
+
+		broker, err := NewWebhookBroker(&Config{URL: server.URL})
+		require.NoError(t, err)
+
+		envelope, err := broker.BuildEnvelope(newEvent())
+		require.NoError(t, err)
+		require.Error(t, broker.SendMessage(envelope))
+	})
+}
+
+func Test_WebhookBroker_SendMessage_CloudEvents(t *testing.T) {
+	t.Run("it should send the whole CloudEvent in structured mode", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusOK)
+		encoder, err := cloudevents.NewEncoder("us-central1", cloudevents.ContentModeStructured, manager.Scheme)
+		require.NoError(t, err)
+		broker, err := NewWebhookBroker(&Config{URL: server.URL, CloudEvents: encoder})
+		require.NoError(t, err)
+
+		envelope, err := broker.BuildEnvelope(newEvent())
+		require.NoError(t, err)
+		require.NoError(t, broker.SendMessage(envelope))
+
+		req := <-requests
+		require.Equal(t, cloudevents.StructuredContentType, req.header.Get("Content-Type"))
+		require.Empty(t, req.header.Get("ce-type"))
+
+		var got map[string]interface{}
+		require.NoError(t, json.Unmarshal(req.body, &got))
+		require.Equal(t, cloudevents.SpecVersion, got["specversion"])
+		require.Equal(t, events.GameServerEventAdded.String(), got["type"])
+		require.Equal(t, "/clusters/us-central1/namespaces/default/gameservers/simple-udp-agones", got["source"])
+	})
+
+	t.Run("it should send the attributes as headers in binary mode", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusOK)
+		encoder, err := cloudevents.NewEncoder("us-central1", cloudevents.ContentModeBinary, manager.Scheme)
+		require.NoError(t, err)
+		broker, err := NewWebhookBroker(&Config{URL: server.URL, CloudEvents: encoder})
+		require.NoError(t, err)
+
+		envelope, err := broker.BuildEnvelope(newEvent())
+		require.NoError(t, err)
+		require.NoError(t, broker.SendMessage(envelope))
+
+		req := <-requests
+		require.Equal(t, cloudevents.DataContentType, req.header.Get("Content-Type"))
+		require.Equal(t, cloudevents.SpecVersion, req.header.Get("ce-specversion"))
+		require.Equal(t, events.GameServerEventAdded.String(), req.header.Get("ce-type"))
+		require.Equal(t, "/clusters/us-central1/namespaces/default/gameservers/simple-udp-agones", req.header.Get("ce-source"))
+		require.Equal(t, "default/simple-udp-agones", req.header.Get("ce-subject"))
+		require.NotEmpty(t, req.header.Get("ce-id"))
+
+		var got map[string]interface{}
+		require.NoError(t, json.Unmarshal(req.body, &got))
+		require.Equal(t, "simple-udp-agones", got["metadata"].(map[string]interface{})["name"])
+	})
+}
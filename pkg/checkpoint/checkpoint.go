@@ -0,0 +1,282 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/runtime/log"
+)
+
+// DEFAULT_INTERVAL is the default time between saves of the checkpoint
+const DEFAULT_INTERVAL = 10 * time.Second
+
+// Config of the checkpoint. Either Dir or ConfigMap must be set.
+// Dir is the directory of the file holding the checkpoint. ConfigMap is the name of the ConfigMap holding the checkpoint
+// on Namespace, which defaults to the namespace the broadcaster is running on.
+// Interval is the time between saves. Defaults to DEFAULT_INTERVAL.
+type Config struct {
+	Dir       string
+	ConfigMap string
+	Namespace string
+	Interval  time.Duration
+}
+
+// Entry is the last version of a resource published by the broadcaster.
+// Hash identifies the state of the resource regardless of its resourceVersion.
+type Entry struct {
+	Kind            string `json:"kind"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	UID             string `json:"uid"`
+	ResourceVersion string `json:"resourceVersion"`
+	Hash            string `json:"hash"`
+}
+
+// State holds the entries of the resources published by the broadcaster indexed by Key
+type State map[string]Entry
+
+// Store persists the state of the resources published by the broadcaster
+type Store interface {
+	Load(ctx context.Context) (State, error)
+	Save(ctx context.Context, state State) error
+}
+
+// Tracker keeps the state of the resources published by the broadcaster.
+// Resources recorded or forgotten since the broadcaster started are live and take precedence over the restored state.
+// The scheme is used to key resources by their group and kind.
+type Tracker struct {
+	mutex  sync.Mutex
+	scheme *runtime.Scheme
+	state  State
+	live   map[string]bool
+	dirty  bool
+}
+
+func NewTracker(scheme *runtime.Scheme) *Tracker {
+	return &Tracker{
+		scheme: scheme,
+		state:  State{},
+		live:   map[string]bool{},
+	}
+}
+
+// Record stores the version of the resource published by the broadcaster
+func (t *Tracker) Record(obj runtime.Object) {
+	entry, err := NewEntry(obj, t.scheme)
+	if err != nil {
+		return
+	}
+
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+
+	key := entry.Key()
+	t.state[key] = entry
+	t.live[key] = true
+	t.dirty = true
+}
+
+// Forget removes the resource, which delete event has been published
+func (t *Tracker) Forget(obj runtime.Object) {
+	entry, err := NewEntry(obj, t.scheme)
+	if err != nil {
+		return
+	}
+
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+
+	key := entry.Key()
+	delete(t.state, key)
+	t.live[key] = true
+	t.dirty = true
+}
+
+// Restore adds the entries of the state for resources that have not been recorded or forgotten since the broadcaster started
+func (t *Tracker) Restore(state State) {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+
+	for key, entry := range state {
+		if !t.live[key] {
+			t.state[key] = entry
+		}
+	}
+}
+
+// Get returns the entry of the key. live is true when the resource was recorded or forgotten since the broadcaster started.
+func (t *Tracker) Get(key string) (entry Entry, ok bool, live bool) {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+
+	entry, ok = t.state[key]
+	return entry, ok, t.live[key]
+}
+
+// Restored returns the entries of the kind that have not been recorded or forgotten since the broadcaster started
+func (t *Tracker) Restored(kind string) []Entry {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+
+	var entries []Entry
+	for key, entry := range t.state {
+		if entry.Kind == kind && !t.live[key] {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// changes returns a copy of the state if it changed since the last call
+func (t *Tracker) changes() (State, bool) {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+
+	if !t.dirty {
+		return nil, false
+	}
+	t.dirty = false
+
+	state := make(State, len(t.state))
+	for key, entry := range t.state {
+		state[key] = entry
+	}
+
+	return state, true
+}
+
+func (t *Tracker) markDirty() {
+	defer t.mutex.Unlock()
+	t.mutex.Lock()
+
+	t.dirty = true
+}
+
+// Saver saves the state of the tracker on the store periodically and when the broadcaster stops
+type Saver struct {
+	logger   *logrus.Entry
+	tracker  *Tracker
+	store    Store
+	interval time.Duration
+}
+
+func NewSaver(tracker *Tracker, store Store, interval time.Duration) *Saver {
+	if interval <= 0 {
+		interval = DEFAULT_INTERVAL
+	}
+
+	return &Saver{
+		logger:   log.NewLoggerWithField("source", "checkpoint"),
+		tracker:  tracker,
+		store:    store,
+		interval: interval,
+	}
+}
+
+// Start saves the state until ctx is cancelled
+func (s *Saver) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The manager context is cancelled already, the last save uses its own timeout
+			saveCtx, cancel := context.WithTimeout(context.Background(), s.interval)
+			s.save(saveCtx)
+			cancel()
+			return nil
+		case <-ticker.C:
+			s.save(ctx)
+		}
+	}
+}
+
+func (s *Saver) save(ctx context.Context) {
+	state, changed := s.tracker.changes()
+	if !changed {
+		return
+	}
+
+	if err := s.store.Save(ctx, state); err != nil {
+		s.logger.WithError(err).Error("error saving checkpoint")
+		// Changes are saved on the next tick
+		s.tracker.markDirty()
+	}
+}
+
+// NewEntry returns the entry of the current version of the resource. The scheme is used to set the kind of the entry.
+func NewEntry(obj runtime.Object, scheme *runtime.Scheme) (Entry, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	hash, err := Hash(obj)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return Entry{
+		Kind:            Kind(obj, scheme),
+		Namespace:       accessor.GetNamespace(),
+		Name:            accessor.GetName(),
+		UID:             string(accessor.GetUID()),
+		ResourceVersion: accessor.GetResourceVersion(),
+		Hash:            hash,
+	}, nil
+}
+
+// Key returns the key of the entry on the State
+func (e Entry) Key() string {
+	return Key(e.Kind, e.Namespace, e.Name)
+}
+
+// Key returns the key of a resource on the State
+func Key(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// Kind returns the group and kind of the resource on the scheme. For example: GameServer.agones.dev
+func Kind(obj runtime.Object, scheme *runtime.Scheme) string {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return events.ResourceMessageKind(obj)
+	}
+
+	return gvk.GroupKind().String()
+}
+
+// Hash returns the hash of the JSON representation of the resource. The resourceVersion and managed fields are ignored
+// so resources that were written without changes have the same hash.
+func Hash(obj runtime.Object) (string, error) {
+	copied := obj.DeepCopyObject()
+	accessor, err := meta.Accessor(copied)
+	if err != nil {
+		return "", err
+	}
+	accessor.SetResourceVersion("")
+	accessor.SetManagedFields(nil)
+
+	data, err := json.Marshal(copied)
+	if err != nil {
+		return "", fmt.Errorf("error encoding resource: %v", err)
+	}
+
+	h := fnv.New64a()
+	h.Write(data)
+
+	return strconv.FormatUint(h.Sum64(), 16), nil
+}
@@ -0,0 +1,143 @@
+package checkpoint
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// scheme knows the Agones types, the same way the scheme of the manager does
+var scheme = func() *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := v1.AddToScheme(s); err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+func newGameServer(name, resourceVersion string, state v1.GameServerState) *v1.GameServer {
+	return &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			UID:             types.UID("uid-" + name),
+			ResourceVersion: resourceVersion,
+		},
+		Status: v1.GameServerStatus{State: state},
+	}
+}
+
+func Test_Hash(t *testing.T) {
+	ready, err := Hash(newGameServer("gs", "1", v1.GameServerStateReady))
+	require.NoError(t, err)
+
+	t.Run("it should ignore the resource version", func(t *testing.T) {
+		got, err := Hash(newGameServer("gs", "2", v1.GameServerStateReady))
+		require.NoError(t, err)
+		require.Equal(t, ready, got)
+	})
+
+	t.Run("it should change when the resource changes", func(t *testing.T) {
+		got, err := Hash(newGameServer("gs", "2", v1.GameServerStateAllocated))
+		require.NoError(t, err)
+		require.NotEqual(t, ready, got)
+	})
+}
+
+func Test_Tracker(t *testing.T) {
+	recorded := newGameServer("recorded", "2", v1.GameServerStateAllocated)
+	forgotten := newGameServer("forgotten", "2", v1.GameServerStateReady)
+	restored := newGameServer("restored", "1", v1.GameServerStateReady)
+
+	state := State{}
+	for _, gs := range []*v1.GameServer{newGameServer("recorded", "1", v1.GameServerStateReady), forgotten, restored} {
+		entry, err := NewEntry(gs, scheme)
+		require.NoError(t, err)
+		state[entry.Key()] = entry
+	}
+
+	tracker := NewTracker(scheme)
+	tracker.Record(recorded)
+	tracker.Forget(forgotten)
+	tracker.Restore(state)
+
+	kind := Kind(&v1.GameServer{}, scheme)
+	require.Equal(t, "GameServer.agones.dev", kind)
+
+	t.Run("it should keep the versions recorded since the broadcaster started", func(t *testing.T) {
+		entry, ok, live := tracker.Get(Key(kind, "default", "recorded"))
+		require.True(t, ok)
+		require.True(t, live)
+		require.Equal(t, "2", entry.ResourceVersion)
+	})
+
+	t.Run("it should not restore resources forgotten since the broadcaster started", func(t *testing.T) {
+		_, ok, live := tracker.Get(Key(kind, "default", "forgotten"))
+		require.False(t, ok)
+		require.True(t, live)
+	})
+
+	t.Run("it should return the restored entries", func(t *testing.T) {
+		entries := tracker.Restored(kind)
+		require.Len(t, entries, 1)
+		require.Equal(t, "restored", entries[0].Name)
+	})
+
+	t.Run("it should return the state only when it changed", func(t *testing.T) {
+		got, changed := tracker.changes()
+		require.True(t, changed)
+		require.Len(t, got, 2)
+
+		_, changed = tracker.changes()
+		require.False(t, changed)
+	})
+}
+
+func Test_Stores(t *testing.T) {
+	entry, err := NewEntry(newGameServer("gs", "1", v1.GameServerStateReady), scheme)
+	require.NoError(t, err)
+	state := State{entry.Key(): entry}
+
+	configMapClient := fake.NewClientBuilder().Build()
+	configMapStore, err := NewConfigMapStore(configMapClient, configMapClient, "default", "checkpoint")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc  string
+		store Store
+	}{
+		{
+			desc:  "it should save and load the state from a file",
+			store: NewFileStore(filepath.Join(t.TempDir(), "checkpoint", "default.json")),
+		},
+		{
+			desc:  "it should save and load the state from a configmap",
+			store: configMapStore,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+
+			empty, err := tc.store.Load(ctx)
+			require.NoError(t, err)
+			require.Empty(t, empty)
+
+			// Saving twice updates the existing checkpoint
+			require.NoError(t, tc.store.Save(ctx, State{}))
+			require.NoError(t, tc.store.Save(ctx, state))
+
+			got, err := tc.store.Load(ctx)
+			require.NoError(t, err)
+			require.Equal(t, state, got)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package checkpoint
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CONFIGMAP_KEY is the key of the binary data of the ConfigMap holding the compressed state
+const CONFIGMAP_KEY = "state.json.gz"
+
+// serviceAccountNamespace holds the namespace of pods running in the cluster
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// FileStore persists the state as a JSON file
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load reads the state from the file. The state is empty if the file doesn't exist.
+func (s *FileStore) Load(ctx context.Context) (State, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint %s: %v", s.path, err)
+	}
+
+	state := State{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error decoding checkpoint %s: %v", s.path, err)
+	}
+
+	return state, nil
+}
+
+// Save writes the state to a temporary file that replaces the file, so a crash doesn't leave a partial checkpoint
+func (s *FileStore) Save(ctx context.Context, state State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("error creating checkpoint directory: %v", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing checkpoint %s: %v", tmp, err)
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// ConfigMapStore persists the state as compressed JSON on a ConfigMap.
+// ConfigMaps are limited to 1MiB, which holds the state of tens of thousands of resources.
+type ConfigMapStore struct {
+	client client.Client
+	reader client.Reader
+	key    client.ObjectKey
+}
+
+// NewConfigMapStore returns a store for the ConfigMap. The ConfigMap is written using c and read using reader,
+// which should not be backed by a cache. namespace defaults to the namespace the broadcaster is running on.
+func NewConfigMapStore(c client.Client, reader client.Reader, namespace, name string) (*ConfigMapStore, error) {
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccountNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("checkpoint namespace is required when running out of the cluster: %v", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+
+	return &ConfigMapStore{
+		client: c,
+		reader: reader,
+		key:    client.ObjectKey{Namespace: namespace, Name: name},
+	}, nil
+}
+
+// Load reads the state from the ConfigMap. The state is empty if the ConfigMap doesn't exist.
+func (s *ConfigMapStore) Load(ctx context.Context) (State, error) {
+	cm := &corev1.ConfigMap{}
+	err := s.reader.Get(ctx, s.key, cm)
+	if apierrors.IsNotFound(err) {
+		return State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint %s: %v", s.key, err)
+	}
+
+	data, ok := cm.BinaryData[CONFIGMAP_KEY]
+	if !ok {
+		return State{}, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing checkpoint %s: %v", s.key, err)
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing checkpoint %s: %v", s.key, err)
+	}
+
+	state := State{}
+	if err := json.Unmarshal(decompressed, &state); err != nil {
+		return nil, fmt.Errorf("error decoding checkpoint %s: %v", s.key, err)
+	}
+
+	return state, nil
+}
+
+// Save writes the state to the ConfigMap, creating it if it doesn't exist
+func (s *ConfigMapStore) Save(ctx context.Context, state State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %v", err)
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("error compressing checkpoint: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error compressing checkpoint: %v", err)
+	}
+
+	cm := &corev1.ConfigMap{}
+	err = s.reader.Get(ctx, s.key, cm)
+	if apierrors.IsNotFound(err) {
+		return s.client.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: s.key.Name, Namespace: s.key.Namespace},
+			BinaryData: map[string][]byte{CONFIGMAP_KEY: compressed.Bytes()},
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("error reading checkpoint %s: %v", s.key, err)
+	}
+
+	cm.BinaryData = map[string][]byte{CONFIGMAP_KEY: compressed.Bytes()}
+	return s.client.Update(ctx, cm)
+}
@@ -2,15 +2,19 @@ package controller
 
 import (
 	"context"
-	"reflect"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	ctrl_options "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -18,15 +22,52 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
 	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
 	"github.com/Octops/agones-event-broadcaster/pkg/runtime/log"
 )
 
+// Options of the AgonesController.
+// MaxRetries is the number of times a failed event is retried before it is dropped. Defaults to DEFAULT_MAX_RETRIES.
+// RetryTimeout is how long a failed event is retried before it is dropped. Defaults to DEFAULT_RETRY_TIMEOUT.
+// Events are dropped as soon as either of them is exceeded.
+// MaxPending is the maximum number of events waiting to be retried. Events failing once it is reached are dropped.
+// Defaults to DEFAULT_MAX_PENDING.
+// OnPermanentFailure is called, if set, when an event is dropped. err is ErrPendingStoreFull if the event was dropped
+// because MaxPending was reached, or the error of its last retry wrapped by ErrMaxRetries or ErrRetryTimeout.
+// Cluster is the name of the cluster the resources are watched from, used to label the metrics.
 type Options struct {
-	For  client.Object
-	Owns client.Object
+	Cluster            string
+	For                client.Object
+	Owns               client.Object
+	MaxRetries         int
+	RetryTimeout       time.Duration
+	MaxPending         int
+	OnPermanentFailure func(request reconcile.Request, pending *PendingEvent, err error)
 }
 
+const (
+	DEFAULT_MAX_RETRIES   = 10
+	DEFAULT_RETRY_TIMEOUT = 10 * time.Minute
+	DEFAULT_MAX_PENDING   = 10000
+
+	// RETRY_BASE_DELAY and RETRY_MAX_DELAY bound the exponential delay between the retries of a resource, so events
+	// are retried several times before RetryTimeout.
+	RETRY_BASE_DELAY = 100 * time.Millisecond
+	RETRY_MAX_DELAY  = 30 * time.Second
+)
+
+// ErrPendingStoreFull is reported when an event is dropped because too many events are waiting to be retried
+var ErrPendingStoreFull = errors.New("too many events waiting to be retried")
+
+// ErrMaxRetries and ErrRetryTimeout are reported when an event is dropped because it failed MaxRetries retries,
+// or was still failing RetryTimeout after its first failure
+var (
+	ErrMaxRetries   = errors.New("max retries exceeded")
+	ErrRetryTimeout = errors.New("retry timeout exceeded")
+)
+
 // AgonesController watches for events associated to a particular resource type like GameServers or Fleets.
 // It uses the passed EventHandler argument to send back the current state of the world.
 type AgonesController struct {
@@ -34,33 +75,74 @@ type AgonesController struct {
 	manager.Manager
 }
 
-// Reconciler handles events when resources are reconciled. The interval is configured on the Manager's level.
+// Reconciler retries the events that failed to be handled. The interval is configured on the Manager's level.
 type Reconciler struct {
 	logger *logrus.Entry
 	obj    runtime.Object
 	client.Client
-	scheme *runtime.Scheme
+	scheme             *runtime.Scheme
+	pending            *PendingStore
+	pendingGauge       prometheus.Gauge
+	maxRetries         int
+	retryTimeout       time.Duration
+	onPermanentFailure func(request reconcile.Request, pending *PendingEvent, err error)
 }
 
 func NewAgonesController(mgr manager.Manager, eventHandler handlers.EventHandler, options Options) (*AgonesController, error) {
-	optFor := reflect.TypeOf(options.For).Elem().String()
+	optFor := events.ResourceMessageKind(options.For)
 	logger := log.Logger().WithFields(logrus.Fields{
 		"source":          "controller",
 		"controller_type": optFor,
 	})
 
+	maxRetries := options.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = DEFAULT_MAX_RETRIES
+	}
+
+	retryTimeout := options.RetryTimeout
+	if retryTimeout <= 0 {
+		retryTimeout = DEFAULT_RETRY_TIMEOUT
+	}
+
+	maxPending := options.MaxPending
+	if maxPending <= 0 {
+		maxPending = DEFAULT_MAX_PENDING
+	}
+
+	// Resources share the kind label with the metrics of the broadcaster
+	kind := optFor
+	if gvk, err := apiutil.GVKForObject(options.For, mgr.GetScheme()); err == nil {
+		kind = gvk.Kind
+	}
+
+	reconciler := &Reconciler{
+		logger:             logger,
+		obj:                options.For,
+		Client:             mgr.GetClient(),
+		scheme:             mgr.GetScheme(),
+		pending:            NewPendingStore(maxPending),
+		pendingGauge:       metrics.PendingEvents.WithLabelValues(options.Cluster, kind),
+		maxRetries:         maxRetries,
+		retryTimeout:       retryTimeout,
+		onPermanentFailure: options.OnPermanentFailure,
+	}
+
 	recoverPanic := true
 	err := ctrl.NewControllerManagedBy(mgr).
 		For(options.For).
 		WithOptions(ctrl_options.Options{
 			CacheSyncTimeout: time.Minute * 5,
 			RecoverPanic:     &recoverPanic,
+			RateLimiter: workqueue.NewMaxOfRateLimiter(
+				workqueue.NewItemExponentialFailureRateLimiter(RETRY_BASE_DELAY, RETRY_MAX_DELAY),
+				&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+			),
 		}).
 		//Owns(options.Owns). //TODO: Assigning Owns duplicates the number of reconcile calls.
 		WithEventFilter(predicate.Funcs{
 			CreateFunc: func(event event.CreateEvent) bool {
-				// Implement some logic here and if returns true if you think that
-				// this event should be sent to the reconciler or false otherwise
+				// Events are filtered by the broadcaster using CEL expressions, see the filter package
 				return true
 			},
 			DeleteFunc: func(deleteEvent event.DeleteEvent) bool {
@@ -75,66 +157,24 @@ func NewAgonesController(mgr manager.Manager, eventHandler handlers.EventHandler
 		}).
 		Watches(options.For, &handler.Funcs{
 			CreateFunc: func(ctx context.Context, createEvent event.CreateEvent, limitingInterface workqueue.RateLimitingInterface) {
-				// OnAdd is triggered only when the controller is syncing its cache.
-				// It does not map ot the resource creation event triggered by Kubernetes
-				request := reconcile.Request{
-					NamespacedName: types.NamespacedName{
-						Namespace: createEvent.Object.GetNamespace(),
-						Name:      createEvent.Object.GetName(),
-					},
-				}
-
-				if err := eventHandler.OnAdd(createEvent.Object); err != nil {
-					logger.WithError(err).Errorf("failed to handle onAdd %s/%s, putting back on the queue", createEvent.Object.GetNamespace(), createEvent.Object.GetName())
-					limitingInterface.AddRateLimited(request)
-					return
-				}
-
-				limitingInterface.Forget(request)
-				limitingInterface.Done(request)
+				// OnAdd is triggered for every resource listed when the controller is syncing its cache
+				// and for resources created afterwards. See broadcaster.StartupMode
+				reconciler.handle(limitingInterface, createEvent.Object, "onAdd", func() error {
+					return eventHandler.OnAdd(createEvent.Object)
+				})
 			},
 			UpdateFunc: func(ctx context.Context, updateEvent event.UpdateEvent, limitingInterface workqueue.RateLimitingInterface) {
-				request := reconcile.Request{
-					NamespacedName: types.NamespacedName{
-						Namespace: updateEvent.ObjectNew.GetNamespace(),
-						Name:      updateEvent.ObjectNew.GetName(),
-					},
-				}
-
-				if err := eventHandler.OnUpdate(updateEvent.ObjectOld, updateEvent.ObjectNew); err != nil {
-					logger.WithError(err).Errorf("failed to handle onUpdate %s/%s, putting back on the queue", updateEvent.ObjectNew.GetNamespace(), updateEvent.ObjectNew.GetName())
-					limitingInterface.AddRateLimited(request)
-					return
-				}
-
-				limitingInterface.Forget(request)
-				limitingInterface.Done(request)
+				reconciler.handle(limitingInterface, updateEvent.ObjectNew, "onUpdate", func() error {
+					return eventHandler.OnUpdate(updateEvent.ObjectOld, updateEvent.ObjectNew)
+				})
 			},
 			DeleteFunc: func(ctx context.Context, deleteEvent event.DeleteEvent, limitingInterface workqueue.RateLimitingInterface) {
-
-				request := reconcile.Request{
-					NamespacedName: types.NamespacedName{
-						Namespace: deleteEvent.Object.GetNamespace(),
-						Name:      deleteEvent.Object.GetName(),
-					},
-				}
-
-				if err := eventHandler.OnDelete(deleteEvent.Object); err != nil {
-					logger.WithError(err).Errorf("failed to handle onDelete %s/%s, putting back on the queue", deleteEvent.Object.GetNamespace(), deleteEvent.Object.GetName())
-					limitingInterface.AddRateLimited(request)
-					return
-				}
-
-				limitingInterface.Forget(request)
-				limitingInterface.Done(request)
+				reconciler.handle(limitingInterface, deleteEvent.Object, "onDelete", func() error {
+					return handlers.HandleDelete(eventHandler, deleteEvent.Object, deleteEvent.DeleteStateUnknown)
+				})
 			},
 		}).
-		Complete(&Reconciler{
-			logger: logger,
-			obj:    options.For,
-			Client: mgr.GetClient(),
-			scheme: mgr.GetScheme(),
-		})
+		Complete(reconciler)
 
 	if err != nil {
 		return nil, err
@@ -149,29 +189,88 @@ func NewAgonesController(mgr manager.Manager, eventHandler handlers.EventHandler
 	return controller, nil
 }
 
-// Warning: This method is possible not meant to be used. It has a particular use case but the broadcaster uses a shorter
-// Sync period that triggers OnUpdate events. Right now this Reconcile function is useless for the broadcaster.
-// It should be explored in the future.
+// handle calls the event handler and stores the event for retries if it fails. Events of resources with pending events
+// are stored without being handled so events of the same resource are published in the order they were observed.
+// Events are dropped if the store of pending events is full.
+func (r *Reconciler) handle(queue workqueue.RateLimitingInterface, obj client.Object, source string, fn func() error) {
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		},
+	}
+
+	pending := &PendingEvent{Source: source, Handle: fn}
+	if r.pending.Has(request) {
+		r.store(request, pending)
+		queue.Add(request)
+		return
+	}
+
+	if err := pending.Retry(); err != nil {
+		if errors.Is(err, handlers.ErrDropped) {
+			r.logger.Debugf("%s %s dropped", source, request)
+			return
+		}
+
+		r.logger.WithError(err).Errorf("failed to handle %s %s, putting back on the queue", source, request)
+		pending.FailedAt = time.Now()
+		if r.store(request, pending) {
+			queue.AddRateLimited(request)
+		}
+	}
+}
+
+// store adds the event to the pending events of the request. It returns false if the event was dropped because the store is full.
+func (r *Reconciler) store(request reconcile.Request, pending *PendingEvent) bool {
+	if !r.pending.Add(request, pending) {
+		r.logger.Errorf("dropping %s %s, %d events are waiting to be retried", pending.Source, request, r.pending.Len())
+		r.drop(request, pending, ErrPendingStoreFull)
+		return false
+	}
+
+	r.pendingGauge.Inc()
+	return true
+}
+
+func (r *Reconciler) drop(request reconcile.Request, pending *PendingEvent, err error) {
+	if r.onPermanentFailure != nil {
+		r.onPermanentFailure(request, pending, err)
+	}
+}
 
-// TODO: Evaluate is Reconcile should be made an argument for the Controller. Reconcile can be used for general uses cases
-// where control over very specific events matter. Right now it is just a STDOUT output.
-// Reconcile is called on every reconcile event. It does not differ between add, update, delete.
-// Its function is purely informative and events are handled back to the broadcaster specific event handlers.
+// Reconcile retries the pending events of the resource in the order they were observed.
+// Returning an error puts the request back on the queue using the rate limiting of the workqueue.
+// Events that failed MaxRetries retries, or are still failing RetryTimeout after their first failure, are dropped and
+// reported as permanent failures.
 func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
-	//ctx := context.Background()
-	//obj := r.obj.DeepCopyObject()
-	//if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
-	//	if apierrors.IsNotFound(err) {
-	//		r.logger.WithField("type", reflect.TypeOf(obj).String()).Debugf("resource \"%s\" not found", req.NamespacedName)
-	//		return ctrl.Result{}, nil
-	//	}
-	//
-	//	r.logger.WithError(err).Error()
-	//
-	//	return reconcile.Result{}, err
-	//}
-
-	//r.logger.Debugf("OnReconcile: %s (%s)", req.NamespacedName, reflect.TypeOf(obj).String())
-
-	return reconcile.Result{}, nil
+	for {
+		pending, ok := r.pending.Peek(req)
+		if !ok {
+			return reconcile.Result{}, nil
+		}
+
+		if err := pending.Retry(); err != nil && !errors.Is(err, handlers.ErrDropped) {
+			if pending.FailedAt.IsZero() {
+				pending.FailedAt = time.Now()
+			}
+
+			pending.Retries++
+			switch {
+			case pending.Retries >= r.maxRetries:
+				err = fmt.Errorf("%w: %w", ErrMaxRetries, err)
+			case time.Since(pending.FailedAt) >= r.retryTimeout:
+				err = fmt.Errorf("%w: %w", ErrRetryTimeout, err)
+			default:
+				r.logger.WithError(err).Warnf("retry %d of %s %s failed, putting back on the queue", pending.Retries, pending.Source, req)
+				return reconcile.Result{}, err
+			}
+
+			r.logger.WithError(err).Errorf("dropping %s %s after %d retries", pending.Source, req, pending.Retries)
+			r.drop(req, pending, err)
+		}
+
+		r.pending.Pop(req)
+		r.pendingGauge.Dec()
+	}
 }
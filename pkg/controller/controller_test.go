@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+	"github.com/Octops/agones-event-broadcaster/pkg/runtime/log"
+)
+
+func newReconciler(maxRetries int, retryTimeout time.Duration, maxPending int, onPermanentFailure func(reconcile.Request, *PendingEvent, error)) *Reconciler {
+	return &Reconciler{
+		logger:             log.NewLoggerWithField("source", "test"),
+		pending:            NewPendingStore(maxPending),
+		pendingGauge:       prometheus.NewGauge(prometheus.GaugeOpts{Name: "pending_events"}),
+		maxRetries:         maxRetries,
+		retryTimeout:       retryTimeout,
+		onPermanentFailure: onPermanentFailure,
+	}
+}
+
+func Test_Reconciler_Retries(t *testing.T) {
+	obj := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default"}}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "gs"}}
+
+	t.Run("it should retry failed events in the order they were observed", func(t *testing.T) {
+		r := newReconciler(DEFAULT_MAX_RETRIES, time.Minute, 0, nil)
+		queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		defer queue.ShutDown()
+
+		var published []string
+		fail := true
+		r.handle(queue, obj, "onAdd", func() error {
+			if fail {
+				return errors.New("broker unavailable")
+			}
+			published = append(published, "onAdd")
+			return nil
+		})
+		r.handle(queue, obj, "onUpdate", func() error {
+			published = append(published, "onUpdate")
+			return nil
+		})
+		require.Empty(t, published, "events should wait for the pending events of the same resource")
+		require.Equal(t, 2, r.pending.Len())
+		require.Equal(t, float64(2), testutil.ToFloat64(r.pendingGauge))
+
+		_, err := r.Reconcile(context.Background(), request)
+		require.Error(t, err)
+
+		fail = false
+		_, err = r.Reconcile(context.Background(), request)
+		require.NoError(t, err)
+		require.Equal(t, []string{"onAdd", "onUpdate"}, published)
+		require.Equal(t, 0, r.pending.Len())
+		require.Equal(t, float64(0), testutil.ToFloat64(r.pendingGauge))
+	})
+
+	t.Run("it should drop events still failing after the retry timeout", func(t *testing.T) {
+		var failures []string
+		r := newReconciler(DEFAULT_MAX_RETRIES, 50*time.Millisecond, 0, func(req reconcile.Request, pending *PendingEvent, err error) {
+			require.ErrorIs(t, err, ErrRetryTimeout)
+			failures = append(failures, pending.Source)
+		})
+		queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		defer queue.ShutDown()
+
+		r.handle(queue, obj, "onDelete", func() error {
+			return errors.New("broker unavailable")
+		})
+
+		_, err := r.Reconcile(context.Background(), request)
+		require.Error(t, err)
+
+		time.Sleep(60 * time.Millisecond)
+		_, err = r.Reconcile(context.Background(), request)
+		require.NoError(t, err)
+		require.Equal(t, []string{"onDelete"}, failures)
+		require.Equal(t, 0, r.pending.Len())
+	})
+
+	t.Run("it should drop events after the max retries", func(t *testing.T) {
+		var failures []error
+		r := newReconciler(3, time.Minute, 0, func(req reconcile.Request, pending *PendingEvent, err error) {
+			require.Equal(t, "onUpdate", pending.Source)
+			require.Equal(t, 3, pending.Retries)
+			failures = append(failures, err)
+		})
+		queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		defer queue.ShutDown()
+
+		var handled int
+		r.handle(queue, obj, "onUpdate", func() error {
+			handled++
+			return errors.New("broker unavailable")
+		})
+
+		for i := 0; i < 2; i++ {
+			_, err := r.Reconcile(context.Background(), request)
+			require.Error(t, err)
+			require.Empty(t, failures)
+		}
+
+		_, err := r.Reconcile(context.Background(), request)
+		require.NoError(t, err)
+		require.Equal(t, 4, handled, "it should handle the event once and retry it 3 times")
+		require.Len(t, failures, 1)
+		require.ErrorIs(t, failures[0], ErrMaxRetries)
+		require.ErrorContains(t, failures[0], "broker unavailable")
+		require.Equal(t, 0, r.pending.Len())
+		require.Equal(t, float64(0), testutil.ToFloat64(r.pendingGauge))
+	})
+
+	t.Run("it should retry the events built only on the brokers that failed", func(t *testing.T) {
+		r := newReconciler(DEFAULT_MAX_RETRIES, time.Minute, 0, nil)
+		queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		defer queue.ShutDown()
+
+		var handled int
+		var retried [][]string
+		r.handle(queue, obj, "onUpdate", func() error {
+			handled++
+			return &handlers.PublishError{Events: []*handlers.FailedEvent{{
+				Brokers: []string{"webhook"},
+				Err:     errors.New("broker unavailable"),
+				Publish: func(brokers []string) error {
+					retried = append(retried, brokers)
+					return nil
+				},
+			}}}
+		})
+
+		_, err := r.Reconcile(context.Background(), request)
+		require.NoError(t, err)
+		require.Equal(t, 1, handled, "it should not handle the event again")
+		require.Equal(t, [][]string{{"webhook"}}, retried)
+		require.Equal(t, 0, r.pending.Len())
+	})
+
+	t.Run("it should drop events once the pending store is full", func(t *testing.T) {
+		var failures []error
+		r := newReconciler(DEFAULT_MAX_RETRIES, time.Minute, 1, func(req reconcile.Request, pending *PendingEvent, err error) {
+			failures = append(failures, err)
+		})
+		queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		defer queue.ShutDown()
+
+		fail := func() error { return errors.New("broker unavailable") }
+		r.handle(queue, obj, "onAdd", fail)
+		r.handle(queue, obj, "onUpdate", fail)
+		require.Equal(t, 1, r.pending.Len())
+		require.Equal(t, []error{ErrPendingStoreFull}, failures)
+	})
+
+	t.Run("it should not store events that are handled", func(t *testing.T) {
+		r := newReconciler(DEFAULT_MAX_RETRIES, time.Minute, 0, nil)
+		queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		defer queue.ShutDown()
+
+		r.handle(queue, obj, "onAdd", func() error { return nil })
+		require.Equal(t, 0, r.pending.Len())
+		require.Equal(t, 0, queue.Len())
+	})
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events/handlers"
+)
+
+// PendingEvent is an event that failed to be handled and is retried by the Reconciler.
+// Handle keeps the original objects of the event so retries publish exactly what was observed.
+// Once the event handler built the events and some of its brokers failed to publish them, Failed holds those events
+// so retries publish them, with the same metadata, only to the brokers that failed.
+// FailedAt is when the event failed for the first time.
+type PendingEvent struct {
+	Source   string
+	Handle   func() error
+	Failed   []*handlers.FailedEvent
+	Retries  int
+	FailedAt time.Time
+}
+
+// Retry handles the event, or publishes the events that failed again. It returns the error of the events that failed.
+func (p *PendingEvent) Retry() error {
+	var err error
+	if len(p.Failed) > 0 {
+		err = handlers.Republish(p.Failed)
+	} else {
+		err = p.Handle()
+	}
+
+	if failed, ok := handlers.FailedEvents(err); ok {
+		p.Failed = failed
+	}
+
+	return err
+}
+
+// PendingStore holds the events that failed to be handled, keyed by the request of the resource.
+// Events of the same resource are kept in the order they were observed. It holds max events at most.
+type PendingStore struct {
+	mutex  sync.Mutex
+	events map[reconcile.Request][]*PendingEvent
+	count  int
+	max    int
+}
+
+func NewPendingStore(max int) *PendingStore {
+	return &PendingStore{
+		events: map[reconcile.Request][]*PendingEvent{},
+		max:    max,
+	}
+}
+
+// Add appends the event to the pending events of the request. It returns false if the store is full.
+func (s *PendingStore) Add(request reconcile.Request, event *PendingEvent) bool {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	if s.max > 0 && s.count >= s.max {
+		return false
+	}
+
+	s.events[request] = append(s.events[request], event)
+	s.count++
+
+	return true
+}
+
+// Has returns true if the request has pending events
+func (s *PendingStore) Has(request reconcile.Request) bool {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	return len(s.events[request]) > 0
+}
+
+// Peek returns the oldest pending event of the request
+func (s *PendingStore) Peek(request reconcile.Request) (*PendingEvent, bool) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	pending := s.events[request]
+	if len(pending) == 0 {
+		return nil, false
+	}
+
+	return pending[0], true
+}
+
+// Pop removes the oldest pending event of the request
+func (s *PendingStore) Pop(request reconcile.Request) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	pending := s.events[request]
+	if len(pending) == 0 {
+		return
+	}
+
+	s.count--
+	if len(pending) == 1 {
+		delete(s.events, request)
+		return
+	}
+
+	s.events[request] = pending[1:]
+}
+
+// Len returns the number of pending events of all requests
+func (s *PendingStore) Len() int {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	return s.count
+}
@@ -0,0 +1,110 @@
+package diagnostics
+
+import (
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+const (
+	OOMKilledReason = "OOMKilled"
+	EvictedReason   = "Evicted"
+)
+
+// GameServerName returns the name of the GameServer backed by the pod. It returns false for pods not owned by a GameServer.
+func GameServerName(pod *corev1.Pod) (string, bool) {
+	name, ok := pod.Labels[v1.GameServerPodLabel]
+	return name, ok && name != ""
+}
+
+// PodDiagnostic compares two versions of a GameServer pod and returns the diagnostic for what happened to it.
+// It returns false if nothing worth reporting happened. Evictions are reported before OOM kills and restarts.
+func PodDiagnostic(oldPod, newPod *corev1.Pod) (events.DiagnosticEventType, *events.Diagnostic, bool) {
+	gsName, ok := GameServerName(newPod)
+	if !ok {
+		return "", nil, false
+	}
+
+	diagnostic := &events.Diagnostic{
+		GameServer:        gsName,
+		Namespace:         newPod.Namespace,
+		Pod:               newPod.Name,
+		Node:              newPod.Spec.NodeName,
+		ContainerStatuses: newPod.Status.ContainerStatuses,
+	}
+
+	if newPod.Status.Reason == EvictedReason && oldPod.Status.Reason != EvictedReason {
+		diagnostic.Reason = newPod.Status.Reason
+		diagnostic.Message = newPod.Status.Message
+		return events.GameServerPodEvicted, diagnostic, true
+	}
+
+	oldStatuses := map[string]corev1.ContainerStatus{}
+	for _, status := range oldPod.Status.ContainerStatuses {
+		oldStatuses[status.Name] = status
+	}
+
+	var eventType events.DiagnosticEventType
+	for _, status := range newPod.Status.ContainerStatuses {
+		oldStatus := oldStatuses[status.Name]
+
+		if oomKilled(status.State) && !oomKilled(oldStatus.State) ||
+			status.RestartCount > oldStatus.RestartCount && oomKilled(status.LastTerminationState) {
+			diagnostic.Reason = OOMKilledReason
+			diagnostic.Message = "container " + status.Name + " was killed for exceeding its memory limit"
+			return events.GameServerPodOOMKilled, diagnostic, true
+		}
+
+		if status.RestartCount > oldStatus.RestartCount && eventType == "" {
+			eventType = events.GameServerPodRestarted
+			diagnostic.Message = "container " + status.Name + " restarted"
+			if terminated := status.LastTerminationState.Terminated; terminated != nil {
+				diagnostic.Reason = terminated.Reason
+			}
+		}
+	}
+
+	return eventType, diagnostic, eventType != ""
+}
+
+// NodeNotReady compares two versions of a node and returns true when the node stopped being ready
+func NodeNotReady(oldNode, newNode *corev1.Node) bool {
+	return nodeReady(oldNode) && !nodeReady(newNode)
+}
+
+// NodeDiagnostic returns the diagnostic of a GameServer which node is not ready
+func NodeDiagnostic(node *corev1.Node, gs *v1.GameServer) *events.Diagnostic {
+	diagnostic := &events.Diagnostic{
+		GameServer:     gs.Name,
+		Namespace:      gs.Namespace,
+		Node:           node.Name,
+		NodeConditions: node.Status.Conditions,
+	}
+
+	if condition := readyCondition(node); condition != nil {
+		diagnostic.Reason = condition.Reason
+		diagnostic.Message = condition.Message
+	}
+
+	return diagnostic
+}
+
+func oomKilled(state corev1.ContainerState) bool {
+	return state.Terminated != nil && state.Terminated.Reason == OOMKilledReason
+}
+
+func nodeReady(node *corev1.Node) bool {
+	condition := readyCondition(node)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
+
+func readyCondition(node *corev1.Node) *corev1.NodeCondition {
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == corev1.NodeReady {
+			return &node.Status.Conditions[i]
+		}
+	}
+
+	return nil
+}
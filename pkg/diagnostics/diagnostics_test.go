@@ -0,0 +1,134 @@
+package diagnostics
+
+import (
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+func newPod(restarts int32, state, lastState corev1.ContainerState) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple-udp-agones",
+			Namespace: "default",
+			Labels:    map[string]string{v1.GameServerPodLabel: "simple-udp-agones"},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "simple-udp", RestartCount: restarts, State: state, LastTerminationState: lastState},
+			},
+		},
+	}
+}
+
+func terminated(reason string) corev1.ContainerState {
+	return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: reason}}
+}
+
+func Test_PodDiagnostic(t *testing.T) {
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	evicted := newPod(0, running, corev1.ContainerState{})
+	evicted.Status.Reason = EvictedReason
+	evicted.Status.Message = "The node was low on resource: memory."
+
+	testCases := []struct {
+		desc       string
+		oldPod     *corev1.Pod
+		newPod     *corev1.Pod
+		want       events.DiagnosticEventType
+		wantReason string
+		wantOk     bool
+	}{
+		{
+			desc:       "it should report containers restarted after being OOM killed",
+			oldPod:     newPod(0, running, corev1.ContainerState{}),
+			newPod:     newPod(1, running, terminated(OOMKilledReason)),
+			want:       events.GameServerPodOOMKilled,
+			wantReason: OOMKilledReason,
+			wantOk:     true,
+		},
+		{
+			desc:       "it should report containers that are OOM killed and not restarted",
+			oldPod:     newPod(0, running, corev1.ContainerState{}),
+			newPod:     newPod(0, terminated(OOMKilledReason), corev1.ContainerState{}),
+			want:       events.GameServerPodOOMKilled,
+			wantReason: OOMKilledReason,
+			wantOk:     true,
+		},
+		{
+			desc:       "it should report containers restarted for other reasons",
+			oldPod:     newPod(1, running, terminated(OOMKilledReason)),
+			newPod:     newPod(2, running, terminated("Error")),
+			want:       events.GameServerPodRestarted,
+			wantReason: "Error",
+			wantOk:     true,
+		},
+		{
+			desc:       "it should report evicted pods",
+			oldPod:     newPod(0, running, corev1.ContainerState{}),
+			newPod:     evicted,
+			want:       events.GameServerPodEvicted,
+			wantReason: EvictedReason,
+			wantOk:     true,
+		},
+		{
+			desc:   "it should not report pods that did not change",
+			oldPod: newPod(1, running, terminated(OOMKilledReason)),
+			newPod: newPod(1, running, terminated(OOMKilledReason)),
+			wantOk: false,
+		},
+		{
+			desc:   "it should not report pods that are not owned by a gameserver",
+			oldPod: &corev1.Pod{},
+			newPod: &corev1.Pod{Status: corev1.PodStatus{Reason: EvictedReason}},
+			wantOk: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, diagnostic, ok := PodDiagnostic(tc.oldPod, tc.newPod)
+			require.Equal(t, tc.wantOk, ok)
+			if !tc.wantOk {
+				return
+			}
+
+			require.Equal(t, tc.want, got)
+			require.Equal(t, tc.wantReason, diagnostic.Reason)
+			require.Equal(t, "simple-udp-agones", diagnostic.GameServer)
+			require.Equal(t, "node-1", diagnostic.Node)
+			require.Len(t, diagnostic.ContainerStatuses, 1)
+		})
+	}
+}
+
+func Test_NodeNotReady(t *testing.T) {
+	newNode := func(status corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: status, Reason: "KubeletNotReady"},
+				},
+			},
+		}
+	}
+
+	require.True(t, NodeNotReady(newNode(corev1.ConditionTrue), newNode(corev1.ConditionUnknown)))
+	require.True(t, NodeNotReady(newNode(corev1.ConditionTrue), newNode(corev1.ConditionFalse)))
+	require.False(t, NodeNotReady(newNode(corev1.ConditionFalse), newNode(corev1.ConditionFalse)))
+	require.False(t, NodeNotReady(newNode(corev1.ConditionFalse), newNode(corev1.ConditionTrue)))
+
+	gs := &v1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "simple-udp-agones", Namespace: "default"}}
+	diagnostic := NodeDiagnostic(newNode(corev1.ConditionUnknown), gs)
+	require.Equal(t, "simple-udp-agones", diagnostic.GameServer)
+	require.Equal(t, "node-1", diagnostic.Node)
+	require.Equal(t, "KubeletNotReady", diagnostic.Reason)
+	require.Len(t, diagnostic.NodeConditions, 1)
+}
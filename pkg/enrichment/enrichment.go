@@ -0,0 +1,312 @@
+package enrichment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+// DEFAULT_TIMEOUT is the default maximum amount of time spent reading the node and pod of a GameServer.
+// Reads are served by the cache, so they only take longer when the cache is not synced, e.g. when permissions on nodes are missing.
+const DEFAULT_TIMEOUT = time.Second
+
+// Config defines the information attached to GameServer events.
+// Label and annotation keys are allowlists that support glob patterns like topology.kubernetes.io/*.
+// NodeAddressTypes defines the types of the node addresses attached, e.g. ExternalIP.
+// Timeout is the maximum amount of time spent reading the node and pod of a GameServer. Defaults to DEFAULT_TIMEOUT.
+type Config struct {
+	NodeLabels       []string
+	NodeAddressTypes []string
+	PodLabels        []string
+	PodAnnotations   []string
+	Timeout          time.Duration
+}
+
+// DefaultConfig attaches the zone, region and instance type of the node and its external addresses
+func DefaultConfig() *Config {
+	return &Config{
+		Timeout: DEFAULT_TIMEOUT,
+		NodeLabels: []string{
+			corev1.LabelTopologyZone,
+			corev1.LabelTopologyRegion,
+			corev1.LabelInstanceTypeStable,
+		},
+		NodeAddressTypes: []string{
+			string(corev1.NodeExternalIP),
+			string(corev1.NodeExternalDNS),
+		},
+	}
+}
+
+// Enrichment is the information about the node and pod of a GameServer attached to its events
+type Enrichment struct {
+	Node *Node `json:"node,omitempty"`
+	Pod  *Pod  `json:"pod,omitempty"`
+}
+
+// Node holds the allowed labels and the addresses of the node running the GameServer
+type Node struct {
+	Name      string               `json:"name"`
+	Labels    map[string]string    `json:"labels,omitempty"`
+	Addresses []corev1.NodeAddress `json:"addresses,omitempty"`
+}
+
+// Pod holds the metadata of the pod backing the GameServer
+type Pod struct {
+	Name        string            `json:"name"`
+	UID         types.UID         `json:"uid"`
+	IP          string            `json:"ip,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Enricher attaches the Enrichment of GameServers to their events using the manager cache
+type Enricher struct {
+	config *Config
+	reader client.Reader
+}
+
+// New returns an Enricher that reads nodes and pods using the reader. It returns an error if key patterns are malformed.
+func New(config *Config, reader client.Reader) (*Enricher, error) {
+	for _, patterns := range [][]string{config.NodeLabels, config.PodLabels, config.PodAnnotations} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid key pattern %q: %v", pattern, err)
+			}
+		}
+	}
+
+	conf := *config
+	if conf.Timeout <= 0 {
+		conf.Timeout = DEFAULT_TIMEOUT
+	}
+
+	return &Enricher{
+		config: &conf,
+		reader: reader,
+	}, nil
+}
+
+// Enrich returns an event which content has the enrichment section. Events that are not about a GameServer,
+// or GameServers not scheduled to a node yet, are returned unchanged.
+func (e *Enricher) Enrich(ctx context.Context, event events.Event) (events.Event, error) {
+	message, ok := event.(events.Message)
+	if !ok {
+		return event, nil
+	}
+
+	obj, ok := events.ResourceObject(message)
+	if !ok {
+		return event, nil
+	}
+
+	enrichment, err := e.Lookup(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return Attach(event, enrichment), nil
+}
+
+// Lookup returns the enrichment of a GameServer, typed or unstructured. It returns nil for other resources and
+// GameServers not scheduled to a node yet.
+func (e *Enricher) Lookup(ctx context.Context, obj runtime.Object) (*Enrichment, error) {
+	namespace, name, nodeName, ok := gameServer(obj)
+	if !ok || nodeName == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+
+	enrichment := &Enrichment{}
+
+	node := &corev1.Node{}
+	if err := e.reader.Get(ctx, types.NamespacedName{Name: nodeName}, node); err == nil {
+		enrichment.Node = e.node(node)
+	} else if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error getting node %s: %v", nodeName, err)
+	}
+
+	// Agones names the pod of a GameServer after the GameServer
+	pod := &corev1.Pod{}
+	if err := e.reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pod); err == nil {
+		enrichment.Pod = e.pod(pod)
+	} else if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error getting pod %s/%s: %v", namespace, name, err)
+	}
+
+	if enrichment.Node == nil && enrichment.Pod == nil {
+		return nil, nil
+	}
+
+	return enrichment, nil
+}
+
+// Attach returns an event which content has the enrichment section. Events without content, or a nil enrichment,
+// are returned unchanged.
+func Attach(event events.Event, enrichment *Enrichment) events.Event {
+	message, ok := event.(events.Message)
+	if !ok || enrichment == nil {
+		return event
+	}
+
+	return &EnrichedEvent{
+		Event:   event,
+		message: message,
+		content: &Content{
+			Content:    message.Content(),
+			Enrichment: enrichment,
+		},
+	}
+}
+
+// gameServer returns the namespace, name and node name of obj if it is a GameServer
+func gameServer(obj runtime.Object) (string, string, string, bool) {
+	switch o := obj.(type) {
+	case *v1.GameServer:
+		return o.Namespace, o.Name, o.Status.NodeName, true
+	case *unstructured.Unstructured:
+		gvk := o.GroupVersionKind()
+		if gvk.Group != v1.SchemeGroupVersion.Group || gvk.Kind != "GameServer" {
+			return "", "", "", false
+		}
+
+		nodeName, _, _ := unstructured.NestedString(o.Object, "status", "nodeName")
+		return o.GetNamespace(), o.GetName(), nodeName, true
+	}
+
+	return "", "", "", false
+}
+
+func (e *Enricher) node(node *corev1.Node) *Node {
+	enriched := &Node{
+		Name:   node.Name,
+		Labels: allowed(node.Labels, e.config.NodeLabels),
+	}
+
+	for _, address := range node.Status.Addresses {
+		if matchAny(e.config.NodeAddressTypes, string(address.Type)) {
+			enriched.Addresses = append(enriched.Addresses, address)
+		}
+	}
+
+	return enriched
+}
+
+func (e *Enricher) pod(pod *corev1.Pod) *Pod {
+	return &Pod{
+		Name:        pod.Name,
+		UID:         pod.UID,
+		IP:          pod.Status.PodIP,
+		Labels:      allowed(pod.Labels, e.config.PodLabels),
+		Annotations: allowed(pod.Annotations, e.config.PodAnnotations),
+	}
+}
+
+// allowed returns the values which keys match the patterns
+func allowed(values map[string]string, patterns []string) map[string]string {
+	if len(values) == 0 || len(patterns) == 0 {
+		return nil
+	}
+
+	filtered := map[string]string{}
+	for k, v := range values {
+		if matchAny(patterns, k) {
+			filtered[k] = v
+		}
+	}
+
+	return filtered
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Content is the content of an event with the enrichment section.
+// It is encoded as the original content with an additional enrichment field.
+type Content struct {
+	Content    interface{}
+	Enrichment *Enrichment
+}
+
+// MarshalJSON adds the enrichment field to the JSON object of the original content
+func (c *Content) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(c.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	enrichment, err := json.Marshal(c.Enrichment)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '{' {
+		return json.Marshal(map[string]json.RawMessage{
+			"content":    data,
+			"enrichment": enrichment,
+		})
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	if len(bytes.TrimSpace(data[1:len(data)-1])) > 0 {
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"enrichment":`)
+	buf.Write(enrichment)
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// EnrichedEvent is an event which content has the enrichment section.
+// It keeps the source and type of the original event.
+type EnrichedEvent struct {
+	events.Event
+	message events.Message
+	content *Content
+}
+
+// Content returns the original content with the enrichment section
+func (e *EnrichedEvent) Content() interface{} {
+	return e.content
+}
+
+// Metadata returns the metadata of the original event
+func (e *EnrichedEvent) Metadata() *events.Metadata {
+	return events.MetadataOf(e.message)
+}
+
+// Object returns the resource of the original event
+func (e *EnrichedEvent) Object() runtime.Object {
+	obj, _ := events.ResourceObject(e.message)
+	return obj
+}
+
+// Enrichment returns the enrichment section
+func (e *EnrichedEvent) Enrichment() *Enrichment {
+	return e.content.Enrichment
+}
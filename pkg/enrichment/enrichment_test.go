@@ -0,0 +1,135 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+func Test_Enricher_Enrich(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+			Labels: map[string]string{
+				corev1.LabelTopologyZone:        "us-central1-a",
+				corev1.LabelTopologyRegion:      "us-central1",
+				"cloud.google.com/gke-nodepool": "game-servers",
+			},
+		},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: corev1.NodeExternalIP, Address: "35.1.2.3"},
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "simple-udp-agones",
+			Namespace:   "default",
+			UID:         "pod-uid",
+			Labels:      map[string]string{v1.GameServerPodLabel: "simple-udp-agones", "version": "1"},
+			Annotations: map[string]string{"agones.dev/sdk-version": "1.33.0"},
+		},
+		Status: corev1.PodStatus{PodIP: "10.4.0.12"},
+	}
+	reader := fake.NewClientBuilder().WithObjects(node, pod).Build()
+
+	enricher, err := New(&Config{
+		NodeLabels:       []string{"topology.kubernetes.io/*"},
+		NodeAddressTypes: []string{string(corev1.NodeExternalIP)},
+		PodLabels:        []string{v1.GameServerPodLabel},
+		PodAnnotations:   []string{"agones.dev/*"},
+	}, reader)
+	require.NoError(t, err)
+
+	gs := &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "simple-udp-agones", Namespace: "default"},
+		Status:     v1.GameServerStatus{State: v1.GameServerStateReady, NodeName: "node-1"},
+	}
+
+	t.Run("it should attach the node and pod of the gameserver", func(t *testing.T) {
+		event := events.GameServerUpdated(&events.UpdatedMessage{Old: gs, New: gs})
+
+		got, err := enricher.Enrich(context.Background(), event)
+		require.NoError(t, err)
+		require.Equal(t, event.EventType(), got.EventType())
+
+		enriched, ok := got.(*EnrichedEvent)
+		require.True(t, ok)
+		require.Equal(t, &Node{
+			Name: "node-1",
+			Labels: map[string]string{
+				corev1.LabelTopologyZone:   "us-central1-a",
+				corev1.LabelTopologyRegion: "us-central1",
+			},
+			Addresses: []corev1.NodeAddress{{Type: corev1.NodeExternalIP, Address: "35.1.2.3"}},
+		}, enriched.Enrichment().Node)
+		require.Equal(t, &Pod{
+			Name:        "simple-udp-agones",
+			UID:         "pod-uid",
+			IP:          "10.4.0.12",
+			Labels:      map[string]string{v1.GameServerPodLabel: "simple-udp-agones"},
+			Annotations: map[string]string{"agones.dev/sdk-version": "1.33.0"},
+		}, enriched.Enrichment().Pod)
+
+		data, err := json.Marshal(enriched.Content())
+		require.NoError(t, err)
+
+		content := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(data, &content))
+		require.Contains(t, content, "old_obj")
+		require.Contains(t, content, "new_obj")
+		require.Contains(t, content, "enrichment")
+	})
+
+	t.Run("it should attach the node and pod of unstructured gameservers", func(t *testing.T) {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "agones.dev/v1",
+			"kind":       "GameServer",
+			"metadata":   map[string]interface{}{"name": "simple-udp-agones", "namespace": "default"},
+			"status":     map[string]interface{}{"nodeName": "node-1"},
+		}}
+
+		enrichment, err := enricher.Lookup(context.Background(), u)
+		require.NoError(t, err)
+		require.Equal(t, "node-1", enrichment.Node.Name)
+		require.Equal(t, "simple-udp-agones", enrichment.Pod.Name)
+	})
+
+	t.Run("it should not change events of gameservers without a node", func(t *testing.T) {
+		event := events.GameServerAdded(&events.AddedMessage{Obj: &v1.GameServer{}})
+
+		got, err := enricher.Enrich(context.Background(), event)
+		require.NoError(t, err)
+		require.Equal(t, event, got)
+	})
+
+	t.Run("it should not change events of other resources", func(t *testing.T) {
+		event := events.FleetAdded(&events.AddedMessage{Obj: &v1.Fleet{}})
+
+		got, err := enricher.Enrich(context.Background(), event)
+		require.NoError(t, err)
+		require.Equal(t, event, got)
+	})
+}
+
+func Test_Content_MarshalJSON(t *testing.T) {
+	content := &Content{
+		Content:    json.RawMessage(`{"id": "simple-udp-agones"}`),
+		Enrichment: &Enrichment{Node: &Node{Name: "node-1"}},
+	}
+
+	data, err := json.Marshal(content)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": "simple-udp-agones", "enrichment": {"node": {"name": "node-1"}}}`, string(data))
+}
@@ -0,0 +1,220 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+const (
+	SpecVersion = "1.0"
+
+	// StructuredContentType is the media type used when the whole CloudEvent is encoded as the message payload
+	StructuredContentType = "application/cloudevents+json"
+	// DataContentType is the media type of the data carried by the CloudEvent
+	DataContentType = "application/json"
+)
+
+type ContentMode string
+
+var (
+	// ContentModeStructured encodes the attributes and data of the event in the message payload
+	ContentModeStructured ContentMode = "structured"
+	// ContentModeBinary encodes the data in the message payload and the attributes as protocol headers
+	ContentModeBinary ContentMode = "binary"
+)
+
+// CloudEvent is the CloudEvents 1.0 representation of an event published by the broadcaster.
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype,omitempty"`
+	Data            interface{} `json:"data,omitempty"`
+}
+
+// Encoder builds CloudEvents out of broadcaster events.
+// ClusterName is used as part of the event source. Mode defines how protocol bindings carry the event on the wire.
+type Encoder struct {
+	ClusterName string
+	Mode        ContentMode
+	scheme      *runtime.Scheme
+}
+
+// NewEncoder returns a CloudEvents encoder for the given cluster and content mode. Defaults to the structured mode.
+// The scheme resolves the kind of typed resources used as part of the event source.
+func NewEncoder(clusterName string, mode ContentMode, scheme *runtime.Scheme) (*Encoder, error) {
+	if scheme == nil {
+		return nil, fmt.Errorf("a scheme is required")
+	}
+
+	switch mode {
+	case "":
+		mode = ContentModeStructured
+	case ContentModeStructured, ContentModeBinary:
+	default:
+		return nil, fmt.Errorf("invalid cloudevents content mode %q, valid modes are %q and %q", mode, ContentModeStructured, ContentModeBinary)
+	}
+
+	return &Encoder{
+		ClusterName: clusterName,
+		Mode:        mode,
+		scheme:      scheme,
+	}, nil
+}
+
+// Encode builds the CloudEvent for a particular event
+func (e *Encoder) Encode(event events.Event) (*CloudEvent, error) {
+	message, ok := event.(events.Message)
+	if !ok {
+		return nil, fmt.Errorf("event %s does not carry a message", event.EventType())
+	}
+
+	id, observedAt := string(uuid.NewUUID()), time.Now().UTC()
+	// Events carrying metadata keep the same identity on every broker
+	if metadata := events.MetadataOf(message); metadata != nil {
+		id, observedAt = metadata.ID, metadata.ObservedAt
+	}
+
+	ce := &CloudEvent{
+		SpecVersion:     SpecVersion,
+		ID:              id,
+		Source:          e.source(message),
+		Type:            event.EventType().String(),
+		Subject:         subject(message),
+		Time:            observedAt,
+		DataContentType: DataContentType,
+		Data:            message.Content(),
+	}
+
+	return ce, nil
+}
+
+// source returns the URI reference of the resource that originated the event.
+// For example: /clusters/us-central1/namespaces/default/gameservers/simple-udp-agones
+// The cluster name of the event metadata, set when broadcasting from several clusters, takes precedence over ClusterName.
+func (e *Encoder) source(message events.Message) string {
+	clusterName := e.ClusterName
+	if metadata := events.MetadataOf(message); metadata != nil && metadata.ClusterName != "" {
+		clusterName = metadata.ClusterName
+	}
+
+	var elems []string
+	if clusterName != "" {
+		elems = append(elems, "clusters", clusterName)
+	}
+
+	if obj, ok := events.ResourceObject(message); ok {
+		if accessor, err := meta.Accessor(obj); err == nil {
+			if accessor.GetNamespace() != "" {
+				elems = append(elems, "namespaces", accessor.GetNamespace())
+			}
+
+			if resource := resourceOf(obj, e.scheme); resource != "" {
+				elems = append(elems, resource, accessor.GetName())
+			}
+		}
+	}
+
+	return "/" + strings.Join(elems, "/")
+}
+
+// resourceOf returns the plural resource name of the kind of obj, e.g. gameservers. The kind is read from the object,
+// which is set on unstructured objects, or from the scheme. It is empty if the kind is unknown.
+func resourceOf(obj runtime.Object, scheme *runtime.Scheme) string {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return ""
+	}
+
+	plural, _ := meta.UnsafeGuessKindToResource(gvk)
+	return plural.Resource
+}
+
+// subject returns the namespaced name of the resource that originated the event
+func subject(message events.Message) string {
+	obj, ok := events.ResourceObject(message)
+	if !ok {
+		return ""
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+
+	if accessor.GetNamespace() == "" {
+		return accessor.GetName()
+	}
+
+	return accessor.GetNamespace() + "/" + accessor.GetName()
+}
+
+// Attributes returns the context attributes of the CloudEvent indexed by attribute name
+func (c *CloudEvent) Attributes() map[string]string {
+	attrs := map[string]string{
+		"specversion": c.SpecVersion,
+		"id":          c.ID,
+		"source":      c.Source,
+		"type":        c.Type,
+		"time":        c.Time.Format(time.RFC3339Nano),
+	}
+
+	if c.Subject != "" {
+		attrs["subject"] = c.Subject
+	}
+
+	return attrs
+}
+
+// Binding describes how a protocol carries CloudEvents attributes when using the binary content mode.
+type Binding struct {
+	AttributePrefix string
+	ContentTypeKey  string
+}
+
+var (
+	// HTTPBinding follows the CloudEvents HTTP protocol binding
+	HTTPBinding = Binding{AttributePrefix: "ce-", ContentTypeKey: "Content-Type"}
+	// KafkaBinding follows the CloudEvents Kafka protocol binding
+	KafkaBinding = Binding{AttributePrefix: "ce_", ContentTypeKey: "content-type"}
+	// PubSubBinding follows the CloudEvents Google Cloud Pub/Sub protocol binding
+	PubSubBinding = Binding{AttributePrefix: "ce-", ContentTypeKey: "content-type"}
+)
+
+// Encode returns the payload and the protocol headers used to transport the CloudEvent using a particular content mode
+func (b Binding) Encode(ce *CloudEvent, mode ContentMode) ([]byte, map[string]string, error) {
+	if mode == ContentModeBinary {
+		data, err := json.Marshal(ce.Data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error encoding cloudevent data: %v", err)
+		}
+
+		headers := map[string]string{
+			b.ContentTypeKey: ce.DataContentType,
+		}
+		for k, v := range ce.Attributes() {
+			headers[b.AttributePrefix+k] = v
+		}
+
+		return data, headers, nil
+	}
+
+	data, err := json.Marshal(ce)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error encoding cloudevent: %v", err)
+	}
+
+	return data, map[string]string{b.ContentTypeKey: StructuredContentType}, nil
+}
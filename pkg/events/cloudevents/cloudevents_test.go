@@ -0,0 +1,167 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+// scheme knows the Agones types, the same way the scheme of the manager does
+var scheme = func() *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := v1.AddToScheme(s); err != nil {
+		panic(err)
+	}
+	if err := autoscalingv1.AddToScheme(s); err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+func Test_Encoder_Encode(t *testing.T) {
+	gs := &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple-udp-agones",
+			Namespace: "default",
+		},
+	}
+
+	testCases := []struct {
+		desc        string
+		clusterName string
+		event       events.Event
+		wantType    string
+		wantSource  string
+		wantSubject string
+	}{
+		{
+			desc:        "it should encode a GameServerAdded event",
+			clusterName: "us-central1",
+			event:       events.GameServerAdded(&events.EventMessage{Body: gs}),
+			wantType:    events.GameServerEventAdded.String(),
+			wantSource:  "/clusters/us-central1/namespaces/default/gameservers/simple-udp-agones",
+			wantSubject: "default/simple-udp-agones",
+		},
+		{
+			desc:        "it should encode a FleetDeleted event without cluster name",
+			clusterName: "",
+			event: events.FleetDeleted(&events.EventMessage{Body: &v1.Fleet{
+				ObjectMeta: metav1.ObjectMeta{Name: "fleet", Namespace: "games"},
+			}}),
+			wantType:    events.FleetEventDeleted.String(),
+			wantSource:  "/namespaces/games/fleets/fleet",
+			wantSubject: "games/fleet",
+		},
+		{
+			desc:        "it should use the cluster name of the event metadata",
+			clusterName: "us-central1",
+			event: events.GameServerAdded(&events.EventMessage{
+				Body: gs,
+				Meta: &events.Metadata{ID: "id", ObservedAt: time.Now(), ClusterName: "europe-west1"},
+			}),
+			wantType:    events.GameServerEventAdded.String(),
+			wantSource:  "/clusters/europe-west1/namespaces/default/gameservers/simple-udp-agones",
+			wantSubject: "default/simple-udp-agones",
+		},
+		{
+			desc:        "it should use the resource of the kind",
+			clusterName: "us-central1",
+			event: events.FleetAutoscalerAdded(&events.EventMessage{Body: &autoscalingv1.FleetAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "autoscaler", Namespace: "default"},
+			}}),
+			wantType:    events.FleetAutoscalerEventAdded.String(),
+			wantSource:  "/clusters/us-central1/namespaces/default/fleetautoscalers/autoscaler",
+			wantSubject: "default/autoscaler",
+		},
+		{
+			desc:        "it should use the resource of the kind of unstructured objects",
+			clusterName: "us-central1",
+			event: events.GameServerAdded(&events.EventMessage{Body: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "agones.dev/v1",
+				"kind":       "GameServer",
+				"metadata":   map[string]interface{}{"name": "simple-udp-agones", "namespace": "default"},
+			}}}),
+			wantType:    events.GameServerEventAdded.String(),
+			wantSource:  "/clusters/us-central1/namespaces/default/gameservers/simple-udp-agones",
+			wantSubject: "default/simple-udp-agones",
+		},
+		{
+			desc:        "it should encode an event that does not carry a resource",
+			clusterName: "us-central1",
+			event:       events.GameServerUpdated(&events.EventMessage{Body: "fakeBody"}),
+			wantType:    events.GameServerEventUpdated.String(),
+			wantSource:  "/clusters/us-central1",
+			wantSubject: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			encoder, err := NewEncoder(tc.clusterName, ContentModeStructured, scheme)
+			require.NoError(t, err)
+
+			got, err := encoder.Encode(tc.event)
+			require.NoError(t, err)
+			require.Equal(t, SpecVersion, got.SpecVersion)
+			require.NotEmpty(t, got.ID)
+			require.False(t, got.Time.IsZero())
+			require.Equal(t, DataContentType, got.DataContentType)
+			require.Equal(t, tc.wantType, got.Type)
+			require.Equal(t, tc.wantSource, got.Source)
+			require.Equal(t, tc.wantSubject, got.Subject)
+		})
+	}
+}
+
+func Test_NewEncoder_InvalidMode(t *testing.T) {
+	_, err := NewEncoder("", "unknown", scheme)
+	require.Error(t, err)
+}
+
+func Test_Binding_Encode(t *testing.T) {
+	encoder, err := NewEncoder("us-central1", ContentModeStructured, scheme)
+	require.NoError(t, err)
+
+	ce, err := encoder.Encode(events.GameServerAdded(&events.EventMessage{Body: &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "simple-udp-agones", Namespace: "default"},
+	}}))
+	require.NoError(t, err)
+
+	t.Run("it should encode the whole event on structured mode", func(t *testing.T) {
+		data, headers, err := KafkaBinding.Encode(ce, ContentModeStructured)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"content-type": StructuredContentType}, headers)
+
+		got := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(data, &got))
+		require.Equal(t, SpecVersion, got["specversion"])
+		require.Equal(t, ce.ID, got["id"])
+		require.Equal(t, ce.Type, got["type"])
+		require.Contains(t, got, "data")
+	})
+
+	t.Run("it should encode attributes as headers on binary mode", func(t *testing.T) {
+		data, headers, err := HTTPBinding.Encode(ce, ContentModeBinary)
+		require.NoError(t, err)
+		require.Equal(t, DataContentType, headers["Content-Type"])
+		require.Equal(t, SpecVersion, headers["ce-specversion"])
+		require.Equal(t, ce.ID, headers["ce-id"])
+		require.Equal(t, ce.Source, headers["ce-source"])
+		require.Equal(t, ce.Type, headers["ce-type"])
+		require.Equal(t, "default/simple-udp-agones", headers["ce-subject"])
+
+		got := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(data, &got))
+		require.NotContains(t, got, "specversion")
+		require.Contains(t, got, "metadata")
+	})
+}
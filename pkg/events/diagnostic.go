@@ -0,0 +1,88 @@
+package events
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var (
+	GameServerPodOOMKilled DiagnosticEventType = "gameserver.pod.oomkilled"
+	GameServerPodEvicted   DiagnosticEventType = "gameserver.pod.evicted"
+	GameServerPodRestarted DiagnosticEventType = "gameserver.pod.restarted"
+	GameServerNodeNotReady DiagnosticEventType = "gameserver.node.notready"
+)
+
+type DiagnosticEventType string
+
+// DiagnosticEvent is the data structure for events detected on the Pods and Nodes backing GameServers.
+// Diagnostic events are always originated by updates of the Pod or Node.
+type DiagnosticEvent struct {
+	Source  EventSource         `json:"source"`
+	Type    DiagnosticEventType `json:"type"`
+	Message `json:"message"`
+}
+
+// Diagnostic describes what happened to the Pod or Node backing a GameServer
+type Diagnostic struct {
+	GameServer        string                   `json:"gameserver"`
+	Namespace         string                   `json:"namespace"`
+	Pod               string                   `json:"pod,omitempty"`
+	Node              string                   `json:"node,omitempty"`
+	Reason            string                   `json:"reason,omitempty"`
+	Message           string                   `json:"message,omitempty"`
+	ContainerStatuses []corev1.ContainerStatus `json:"containerStatuses,omitempty"`
+	NodeConditions    []corev1.NodeCondition   `json:"nodeConditions,omitempty"`
+}
+
+// DiagnosticMessage is the message for diagnostic events. Its content is the Diagnostic.
+// Obj is the GameServer affected, so filters and templates can use it as the resource of the event.
+type DiagnosticMessage struct {
+	Diagnostic *Diagnostic
+	Obj        runtime.Object
+	Meta       *Metadata
+}
+
+// Content returns the diagnostic
+func (m *DiagnosticMessage) Content() interface{} {
+	return m.Diagnostic
+}
+
+// Metadata returns the information that identifies the event that resulted in the DiagnosticMessage
+func (m *DiagnosticMessage) Metadata() *Metadata {
+	return m.Meta
+}
+
+// Object returns the GameServer affected
+func (m *DiagnosticMessage) Object() runtime.Object {
+	return m.Obj
+}
+
+// NewDiagnosticEvent returns a diagnostic event of the type
+func NewDiagnosticEvent(eventType DiagnosticEventType, message *DiagnosticMessage) Event {
+	return &DiagnosticEvent{
+		Source:  EventSourceOnUpdate,
+		Type:    eventType,
+		Message: message,
+	}
+}
+
+// EventType returns the type of the diagnostic event.
+// For example: gameserver.pod.oomkilled
+func (t *DiagnosticEvent) EventType() EventType {
+	return EventType(t.Type)
+}
+
+// EventSource return the event source that generated the event
+func (t *DiagnosticEvent) EventSource() EventSource {
+	return t.Source
+}
+
+// Metadata returns the metadata of the message of the event, if any
+func (t *DiagnosticEvent) Metadata() *Metadata {
+	return MetadataOf(t.Message)
+}
+
+// String is a helper method that returns the string version of a DiagnosticEventType
+func (t DiagnosticEventType) String() string {
+	return string(t)
+}
@@ -1,6 +1,25 @@
 package events
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+const (
+	EVENT_ID_HEADER_KEY            = "event_id"
+	OBSERVED_AT_HEADER_KEY         = "event_observed_at"
+	CLUSTER_NAME_HEADER_KEY        = "cluster_name"
+	INSTANCE_HEADER_KEY            = "broadcaster_instance"
+	RESOURCE_UID_HEADER_KEY        = "resource_uid"
+	RESOURCE_VERSION_HEADER_KEY    = "resource_version"
+	SEQUENCE_HEADER_KEY            = "sequence"
+	DELETION_TIMESTAMP_HEADER_KEY  = "deletion_timestamp"
+	FINAL_STATE_UNKNOWN_HEADER_KEY = "final_state_unknown"
+	// TRACEPARENT_HEADER_KEY and TRACESTATE_HEADER_KEY are the W3C trace context headers set when tracing is enabled
+	TRACEPARENT_HEADER_KEY = "traceparent"
+	TRACESTATE_HEADER_KEY  = "tracestate"
+)
 
 // Header is the data structure for headers used when building Envelopes.
 // It is a flexible way of storing information to be used in any part of the publishing process
@@ -24,6 +43,54 @@ func (e *Envelope) AddHeader(key, value string) {
 	e.Header.Headers[key] = value
 }
 
+// AddMetadataHeaders adds the event metadata as headers of the Envelope. Nothing is added if metadata is nil.
+func (e *Envelope) AddMetadataHeaders(metadata *Metadata) {
+	if metadata == nil {
+		return
+	}
+
+	e.AddHeader(EVENT_ID_HEADER_KEY, metadata.ID)
+	e.AddHeader(OBSERVED_AT_HEADER_KEY, metadata.ObservedAt.Format(time.RFC3339Nano))
+	e.AddHeader(SEQUENCE_HEADER_KEY, strconv.FormatUint(metadata.Sequence, 10))
+
+	if metadata.ClusterName != "" {
+		e.AddHeader(CLUSTER_NAME_HEADER_KEY, metadata.ClusterName)
+	}
+	if metadata.Instance != "" {
+		e.AddHeader(INSTANCE_HEADER_KEY, metadata.Instance)
+	}
+	if metadata.ResourceUID != "" {
+		e.AddHeader(RESOURCE_UID_HEADER_KEY, metadata.ResourceUID)
+	}
+	if metadata.ResourceVersion != "" {
+		e.AddHeader(RESOURCE_VERSION_HEADER_KEY, metadata.ResourceVersion)
+	}
+	if metadata.DeletionTimestamp != nil {
+		e.AddHeader(DELETION_TIMESTAMP_HEADER_KEY, metadata.DeletionTimestamp.Format(time.RFC3339Nano))
+	}
+	if metadata.FinalStateUnknown {
+		e.AddHeader(FINAL_STATE_UNKNOWN_HEADER_KEY, strconv.FormatBool(metadata.FinalStateUnknown))
+	}
+}
+
+// TraceHeaders returns the trace context headers of the Envelope.
+// Brokers add them to the messages they publish, e.g. as Kafka headers, so consumers continue the trace.
+// It is empty when tracing is disabled.
+func (e *Envelope) TraceHeaders() map[string]string {
+	headers := map[string]string{}
+	if e.Header == nil {
+		return headers
+	}
+
+	for _, key := range []string{TRACEPARENT_HEADER_KEY, TRACESTATE_HEADER_KEY} {
+		if value, ok := e.Header.Headers[key]; ok && value != "" {
+			headers[key] = value
+		}
+	}
+
+	return headers
+}
+
 // Encode returns the encoded version of the Envelope.
 // This is useful when sending non structure data on the wire
 func (e *Envelope) Encode() ([]byte, error) {
@@ -3,6 +3,7 @@ package events
 import (
 	"reflect"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -10,17 +11,22 @@ var (
 	EventFactoryRegistry = map[string]*EventFactory{}
 )
 
+// EventFactory builds the events of a resource type.
+// OnDeleting is optional and builds the events of resources which deletion has been requested.
+// OnSnapshot is optional and builds the events of resources that existed when the broadcaster started.
 type EventFactory struct {
-	OnAdded   EventBuilder
-	OnUpdated EventBuilder
-	OnDeleted EventBuilder
+	OnAdded    EventBuilder
+	OnUpdated  EventBuilder
+	OnDeleted  EventBuilder
+	OnDeleting EventBuilder
+	OnSnapshot EventBuilder
 }
 
 type EventBuilder func(message Message) Event
 
 // RegisterEventFactory register events builders for a particular resource type.
 func RegisterEventFactory(obj runtime.Object, onAdded EventBuilder, onUpdated EventBuilder, onDeleted EventBuilder) {
-	kind := reflect.TypeOf(obj).Elem().String()
+	kind := ResourceMessageKind(obj)
 	EventFactoryRegistry[kind] = &EventFactory{
 		OnAdded:   onAdded,
 		OnUpdated: onUpdated,
@@ -28,12 +34,23 @@ func RegisterEventFactory(obj runtime.Object, onAdded EventBuilder, onUpdated Ev
 	}
 }
 
+// RegisterDeletingEventFactory register the builder of deleting events for a resource type with a registered factory
+func RegisterDeletingEventFactory(obj runtime.Object, onDeleting EventBuilder) {
+	if factory, ok := EventFactoryRegistry[ResourceMessageKind(obj)]; ok {
+		factory.OnDeleting = onDeleting
+	}
+}
+
+// RegisterSnapshotEventFactory register the builder of snapshot events for a resource type with a registered factory
+func RegisterSnapshotEventFactory(obj runtime.Object, onSnapshot EventBuilder) {
+	if factory, ok := EventFactoryRegistry[ResourceMessageKind(obj)]; ok {
+		factory.OnSnapshot = onSnapshot
+	}
+}
+
 // OnAdded builds an event of type OnAdded for a particular message content type
 func OnAdded(message Message) Event {
-	c := message.Content()
-	kind := ResourceMessageKind(c.(runtime.Object))
-
-	fn, ok := EventFactoryRegistry[kind]
+	fn, ok := factoryFor(message)
 	if !ok {
 		return nil
 	}
@@ -43,12 +60,7 @@ func OnAdded(message Message) Event {
 
 // OnUpdated builds an event of type OnUpdated for a particular message content type
 func OnUpdated(message Message) Event {
-	c := message.Content()
-	m := reflect.ValueOf(c)
-	obj := m.Field(1).Interface()
-
-	kind := ResourceMessageKind(obj.(runtime.Object))
-	fn, ok := EventFactoryRegistry[kind]
+	fn, ok := factoryFor(message)
 	if !ok {
 		return nil
 	}
@@ -58,10 +70,7 @@ func OnUpdated(message Message) Event {
 
 // OnDeleted builds an event of type OnDeleted for a particular message content type
 func OnDeleted(message Message) Event {
-	c := message.Content()
-	kind := ResourceMessageKind(c.(runtime.Object))
-
-	fn, ok := EventFactoryRegistry[kind]
+	fn, ok := factoryFor(message)
 	if !ok {
 		return nil
 	}
@@ -69,7 +78,77 @@ func OnDeleted(message Message) Event {
 	return fn.OnDeleted(message)
 }
 
-// ResourceMessageKind returns the type of the object that is the content of the message
+// OnDeleting builds an event for an update message which new resource has a DeletionTimestamp for the first time.
+// It returns nil for resource types without a deleting event.
+func OnDeleting(message Message) Event {
+	fn, ok := factoryFor(message)
+	if !ok || fn.OnDeleting == nil {
+		return nil
+	}
+
+	return fn.OnDeleting(message)
+}
+
+// OnSnapshot builds an event for an added message of a resource that existed when the broadcaster started.
+// It returns nil for resource types without a snapshot event.
+func OnSnapshot(message Message) Event {
+	fn, ok := factoryFor(message)
+	if !ok || fn.OnSnapshot == nil {
+		return nil
+	}
+
+	return fn.OnSnapshot(message)
+}
+
+// EventFor builds the event for a typed message using the factory registered for the resource type
+func EventFor(message Message) Event {
+	switch message.(type) {
+	case *AddedMessage:
+		return OnAdded(message)
+	case *UpdatedMessage:
+		return OnUpdated(message)
+	case *DeletedMessage:
+		return OnDeleted(message)
+	}
+
+	return nil
+}
+
+// factoryFor returns the factory registered for the type of resource carried by the message
+func factoryFor(message Message) (*EventFactory, bool) {
+	obj, ok := ResourceObject(message)
+	if !ok {
+		return nil, false
+	}
+
+	fn, ok := EventFactoryRegistry[ResourceMessageKind(obj)]
+	return fn, ok
+}
+
+// ResourceObject returns the resource that is the content of the message.
+// For update messages, the new version of the resource is returned.
+func ResourceObject(message Message) (runtime.Object, bool) {
+	if m, ok := message.(ObjectMessage); ok {
+		obj := m.Object()
+		return obj, obj != nil
+	}
+
+	switch c := message.Content().(type) {
+	case *UpdatedMessage:
+		return c.New, c.New != nil
+	case runtime.Object:
+		return c, true
+	}
+
+	return nil, false
+}
+
+// ResourceMessageKind returns the type of the object that is the content of the message.
+// For unstructured objects it returns the GroupVersionKind of the resource.
 func ResourceMessageKind(obj runtime.Object) string {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.GroupVersionKind().String()
+	}
+
 	return reflect.TypeOf(obj).Elem().String()
 }
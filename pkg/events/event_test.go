@@ -0,0 +1,234 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_EventFor(t *testing.T) {
+	gs := &v1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default"}}
+	fleet := &v1.Fleet{ObjectMeta: metav1.ObjectMeta{Name: "fleet", Namespace: "default"}}
+	gsSet := &v1.GameServerSet{ObjectMeta: metav1.ObjectMeta{Name: "fleet-abcde", Namespace: "default"}}
+	fas := &autoscalingv1.FleetAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: "fleet-autoscaler", Namespace: "default"}}
+	scaled := &autoscalingv1.FleetAutoscaler{
+		ObjectMeta: fas.ObjectMeta,
+		Status:     autoscalingv1.FleetAutoscalerStatus{DesiredReplicas: 5},
+	}
+	gsa := &allocationv1.GameServerAllocation{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}}
+
+	testCases := []struct {
+		desc    string
+		message Message
+		want    EventType
+	}{
+		{
+			desc:    "it should build a GameServerAdded event",
+			message: &AddedMessage{Obj: gs},
+			want:    EventType(GameServerEventAdded),
+		},
+		{
+			desc:    "it should build a GameServerUpdated event",
+			message: &UpdatedMessage{Old: gs, New: gs},
+			want:    EventType(GameServerEventUpdated),
+		},
+		{
+			desc:    "it should build a FleetDeleted event",
+			message: &DeletedMessage{Obj: fleet},
+			want:    EventType(FleetEventDeleted),
+		},
+		{
+			desc:    "it should build a GameServerSetAdded event",
+			message: &AddedMessage{Obj: gsSet},
+			want:    EventType(GameServerSetEventAdded),
+		},
+		{
+			desc:    "it should build a FleetAutoscalerUpdated event when desired replicas don't change",
+			message: &UpdatedMessage{Old: fas, New: fas},
+			want:    EventType(FleetAutoscalerEventUpdated),
+		},
+		{
+			desc:    "it should build a FleetAutoscalerScaled event when desired replicas change",
+			message: &UpdatedMessage{Old: fas, New: scaled},
+			want:    EventType(FleetAutoscalerEventScaled),
+		},
+		{
+			desc:    "it should build a GameServerAllocationAdded event",
+			message: &AddedMessage{Obj: gsa},
+			want:    EventType(GameServerAllocationEventAdded),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := EventFor(tc.message)
+			require.NotNil(t, got)
+			require.Equal(t, tc.want, got.EventType())
+		})
+	}
+
+	t.Run("it should not build events for resources without a factory", func(t *testing.T) {
+		require.Nil(t, EventFor(&AddedMessage{Obj: &corev1.Pod{}}))
+	})
+}
+
+func Test_UpdatedMessage_Accessors(t *testing.T) {
+	oldGS := &v1.GameServer{Status: v1.GameServerStatus{State: v1.GameServerStateReady}}
+	newGS := &v1.GameServer{Status: v1.GameServerStatus{State: v1.GameServerStateAllocated}}
+	event := GameServerUpdated(&UpdatedMessage{Old: oldGS, New: newGS})
+
+	gotOld, gotNew, ok := UpdatedAs[*v1.GameServer](event.(Message))
+	require.True(t, ok)
+	require.Equal(t, oldGS, gotOld)
+	require.Equal(t, newGS, gotNew)
+
+	gs, ok := ObjectAs[*v1.GameServer](event.(Message))
+	require.True(t, ok)
+	require.Equal(t, newGS, gs)
+
+	_, ok = ObjectAs[*v1.Fleet](event.(Message))
+	require.False(t, ok)
+
+	_, _, ok = UpdatedAs[*v1.GameServer](&AddedMessage{Obj: newGS})
+	require.False(t, ok)
+}
+
+func Test_UpdatedMessage_Encoding(t *testing.T) {
+	gs := &v1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gs"}}
+	message := &UpdatedMessage{Old: gs, New: gs, Meta: &Metadata{ID: "id"}}
+
+	data, err := json.Marshal(message.Content())
+	require.NoError(t, err)
+
+	got := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Len(t, got, 2)
+	require.Contains(t, got, "old_obj")
+	require.Contains(t, got, "new_obj")
+}
+
+func Test_RegisterUnstructuredEventFactory(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "games.example.com", Version: "v1", Kind: "MatchTicket"}
+	RegisterUnstructuredEventFactory(gvk)
+
+	ticket := &unstructured.Unstructured{}
+	ticket.SetGroupVersionKind(gvk)
+	ticket.SetName("ticket")
+
+	testCases := []struct {
+		desc       string
+		message    Message
+		wantType   EventType
+		wantSource EventSource
+	}{
+		{
+			desc:       "it should derive the added event type from the kind",
+			message:    &AddedMessage{Obj: ticket},
+			wantType:   "matchticket.events.added",
+			wantSource: EventSourceOnAdd,
+		},
+		{
+			desc:       "it should derive the updated event type from the kind",
+			message:    &UpdatedMessage{Old: ticket, New: ticket},
+			wantType:   "matchticket.events.updated",
+			wantSource: EventSourceOnUpdate,
+		},
+		{
+			desc:       "it should derive the deleted event type from the kind",
+			message:    &DeletedMessage{Obj: ticket},
+			wantType:   "matchticket.events.deleted",
+			wantSource: EventSourceOnDelete,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := EventFor(tc.message)
+			require.NotNil(t, got)
+			require.Equal(t, tc.wantType, got.EventType())
+			require.Equal(t, tc.wantSource, got.EventSource())
+		})
+	}
+
+	t.Run("it should not build events for kinds that are not registered", func(t *testing.T) {
+		lobby := &unstructured.Unstructured{}
+		lobby.SetGroupVersionKind(schema.GroupVersionKind{Group: "games.example.com", Version: "v1", Kind: "Lobby"})
+		require.Nil(t, EventFor(&AddedMessage{Obj: lobby}))
+	})
+}
+
+func Test_OnDeleting(t *testing.T) {
+	gs := &v1.GameServer{ObjectMeta: metav1.ObjectMeta{Name: "gs", Namespace: "default"}}
+
+	got := OnDeleting(&UpdatedMessage{Old: gs, New: gs})
+	require.NotNil(t, got)
+	require.Equal(t, EventType(GameServerEventDeleting), got.EventType())
+	require.Equal(t, EventSourceOnUpdate, got.EventSource())
+
+	t.Run("it should not build events for resources without a deleting event", func(t *testing.T) {
+		gsa := &allocationv1.GameServerAllocation{}
+		require.Nil(t, OnDeleting(&UpdatedMessage{Old: gsa, New: gsa}))
+	})
+}
+
+func Test_OnSnapshot(t *testing.T) {
+	fleet := &v1.Fleet{ObjectMeta: metav1.ObjectMeta{Name: "fleet", Namespace: "default"}}
+
+	got := OnSnapshot(&AddedMessage{Obj: fleet})
+	require.NotNil(t, got)
+	require.Equal(t, EventType(FleetEventSnapshot), got.EventType())
+	require.Equal(t, EventSourceOnSnapshot, got.EventSource())
+
+	t.Run("it should build snapshot events for unstructured resources", func(t *testing.T) {
+		gvk := schema.GroupVersionKind{Group: "games.example.com", Version: "v1", Kind: "MatchTicket"}
+		RegisterUnstructuredEventFactory(gvk)
+		ticket := &unstructured.Unstructured{}
+		ticket.SetGroupVersionKind(gvk)
+
+		require.Equal(t, EventType("matchticket.events.snapshot"), OnSnapshot(&AddedMessage{Obj: ticket}).EventType())
+	})
+
+	t.Run("it should not build events for resources without a snapshot event", func(t *testing.T) {
+		gsa := &allocationv1.GameServerAllocation{}
+		require.Nil(t, OnSnapshot(&AddedMessage{Obj: gsa}))
+	})
+}
+
+func Test_Envelope_AddMetadataHeaders_Deleted(t *testing.T) {
+	deletionTimestamp := time.Date(2020, 5, 11, 12, 58, 47, 0, time.UTC)
+	envelope := &Envelope{}
+	envelope.AddMetadataHeaders(&Metadata{
+		ID:                "id",
+		DeletionTimestamp: &deletionTimestamp,
+		FinalStateUnknown: true,
+	})
+
+	require.Equal(t, "2020-05-11T12:58:47Z", envelope.Header.Headers[DELETION_TIMESTAMP_HEADER_KEY])
+	require.Equal(t, "true", envelope.Header.Headers[FINAL_STATE_UNKNOWN_HEADER_KEY])
+}
+
+// contentMessage is a custom message that doesn't carry metadata
+type contentMessage struct{}
+
+func (m *contentMessage) Content() interface{} {
+	return "content"
+}
+
+func Test_MetadataOf(t *testing.T) {
+	metadata := &Metadata{ID: "event-id"}
+
+	require.Equal(t, metadata, MetadataOf(&AddedMessage{Meta: metadata}))
+	require.Nil(t, MetadataOf(&AddedMessage{}))
+	require.Nil(t, MetadataOf(&contentMessage{}), "it should support messages without metadata")
+	require.Equal(t, metadata, MetadataOf(GameServerAdded(&EventMessage{Meta: metadata}).(Message)), "it should return the metadata of the message of events")
+	require.Nil(t, MetadataOf(GameServerAdded(&contentMessage{}).(Message)))
+}
@@ -5,9 +5,11 @@ import (
 )
 
 var (
-	FleetEventAdded   FleetEventType = "fleet.events.added"
-	FleetEventUpdated FleetEventType = "fleet.events.updated"
-	FleetEventDeleted FleetEventType = "fleet.events.deleted"
+	FleetEventAdded    FleetEventType = "fleet.events.added"
+	FleetEventUpdated  FleetEventType = "fleet.events.updated"
+	FleetEventDeleted  FleetEventType = "fleet.events.deleted"
+	FleetEventDeleting FleetEventType = "fleet.events.deleting"
+	FleetEventSnapshot FleetEventType = "fleet.events.snapshot"
 )
 
 type FleetEventType string
@@ -22,6 +24,8 @@ type FleetEvent struct {
 
 func init() {
 	RegisterEventFactory(&v1.Fleet{}, FleetAdded, FleetUpdated, FleetDeleted)
+	RegisterDeletingEventFactory(&v1.Fleet{}, FleetDeleting)
+	RegisterSnapshotEventFactory(&v1.Fleet{}, FleetSnapshot)
 }
 
 // FleetAdded is the data structure for reconcile events of type Add
@@ -51,6 +55,24 @@ func FleetDeleted(message Message) Event {
 	}
 }
 
+// FleetDeleting is the data structure for reconcile events of type Update when the deletion of the resource has been requested
+func FleetDeleting(message Message) Event {
+	return &FleetEvent{
+		Source:  EventSourceOnUpdate,
+		Type:    FleetEventDeleting,
+		Message: message,
+	}
+}
+
+// FleetSnapshot is the data structure for events of resources that existed when the broadcaster started
+func FleetSnapshot(message Message) Event {
+	return &FleetEvent{
+		Source:  EventSourceOnSnapshot,
+		Type:    FleetEventSnapshot,
+		Message: message,
+	}
+}
+
 // EventType returns the type of the reconcile event for a Fleet.
 // For example: Added, Updated, Deleted
 func (t *FleetEvent) EventType() EventType {
@@ -63,6 +85,11 @@ func (t *FleetEvent) EventSource() EventSource {
 	return t.Source
 }
 
+// Metadata returns the metadata of the message of the event, if any
+func (t *FleetEvent) Metadata() *Metadata {
+	return MetadataOf(t.Message)
+}
+
 // String is a helper method that returns the string version of a FleetEventType
 func (t FleetEventType) String() string {
 	return string(t)
@@ -0,0 +1,103 @@
+package events
+
+import (
+	autoscalingv1 "agones.dev/agones/pkg/apis/autoscaling/v1"
+)
+
+var (
+	FleetAutoscalerEventAdded    FleetAutoscalerEventType = "fleetautoscaler.events.added"
+	FleetAutoscalerEventUpdated  FleetAutoscalerEventType = "fleetautoscaler.events.updated"
+	FleetAutoscalerEventScaled   FleetAutoscalerEventType = "fleetautoscaler.events.scaled"
+	FleetAutoscalerEventDeleted  FleetAutoscalerEventType = "fleetautoscaler.events.deleted"
+	FleetAutoscalerEventDeleting FleetAutoscalerEventType = "fleetautoscaler.events.deleting"
+	FleetAutoscalerEventSnapshot FleetAutoscalerEventType = "fleetautoscaler.events.snapshot"
+)
+
+type FleetAutoscalerEventType string
+
+// FleetAutoscalerEvent is the data structure for reconcile events associated with Agones FleetAutoscalers
+// It holds the event source (OnAdd, OnUpdate, OnDelete) and the event type (Added, Updated, Scaled, Deleted).
+type FleetAutoscalerEvent struct {
+	Source  EventSource              `json:"source"`
+	Type    FleetAutoscalerEventType `json:"type"`
+	Message `json:"message"`
+}
+
+func init() {
+	RegisterEventFactory(&autoscalingv1.FleetAutoscaler{}, FleetAutoscalerAdded, FleetAutoscalerUpdated, FleetAutoscalerDeleted)
+	RegisterDeletingEventFactory(&autoscalingv1.FleetAutoscaler{}, FleetAutoscalerDeleting)
+	RegisterSnapshotEventFactory(&autoscalingv1.FleetAutoscaler{}, FleetAutoscalerSnapshot)
+}
+
+// FleetAutoscalerAdded is the data structure for reconcile events of type Add
+func FleetAutoscalerAdded(message Message) Event {
+	return &FleetAutoscalerEvent{
+		Source:  EventSourceOnAdd,
+		Type:    FleetAutoscalerEventAdded,
+		Message: message,
+	}
+}
+
+// FleetAutoscalerUpdated is the data structure for reconcile events of type Update.
+// Updates that change the desired replicas of the autoscaler are Scaled events.
+func FleetAutoscalerUpdated(message Message) Event {
+	eventType := FleetAutoscalerEventUpdated
+	if oldFas, newFas, ok := UpdatedAs[*autoscalingv1.FleetAutoscaler](message); ok && oldFas.Status.DesiredReplicas != newFas.Status.DesiredReplicas {
+		eventType = FleetAutoscalerEventScaled
+	}
+
+	return &FleetAutoscalerEvent{
+		Source:  EventSourceOnUpdate,
+		Type:    eventType,
+		Message: message,
+	}
+}
+
+// FleetAutoscalerDeleted is the data structure for reconcile events of type Delete
+func FleetAutoscalerDeleted(message Message) Event {
+	return &FleetAutoscalerEvent{
+		Source:  EventSourceOnDelete,
+		Type:    FleetAutoscalerEventDeleted,
+		Message: message,
+	}
+}
+
+// FleetAutoscalerDeleting is the data structure for reconcile events of type Update when the deletion of the resource has been requested
+func FleetAutoscalerDeleting(message Message) Event {
+	return &FleetAutoscalerEvent{
+		Source:  EventSourceOnUpdate,
+		Type:    FleetAutoscalerEventDeleting,
+		Message: message,
+	}
+}
+
+// FleetAutoscalerSnapshot is the data structure for events of resources that existed when the broadcaster started
+func FleetAutoscalerSnapshot(message Message) Event {
+	return &FleetAutoscalerEvent{
+		Source:  EventSourceOnSnapshot,
+		Type:    FleetAutoscalerEventSnapshot,
+		Message: message,
+	}
+}
+
+// EventType returns the type of the reconcile event for a FleetAutoscaler.
+// For example: Added, Updated, Scaled, Deleted
+func (t *FleetAutoscalerEvent) EventType() EventType {
+	return EventType(t.Type)
+}
+
+// EventSource return the event source that generated the event.
+// For example: OnAdd, OnUpdate, OnDelete
+func (t *FleetAutoscalerEvent) EventSource() EventSource {
+	return t.Source
+}
+
+// Metadata returns the metadata of the message of the event, if any
+func (t *FleetAutoscalerEvent) Metadata() *Metadata {
+	return MetadataOf(t.Message)
+}
+
+// String is a helper method that returns the string version of a FleetAutoscalerEventType
+func (t FleetAutoscalerEventType) String() string {
+	return string(t)
+}
@@ -3,9 +3,11 @@ package events
 import v1 "agones.dev/agones/pkg/apis/agones/v1"
 
 var (
-	GameServerEventAdded   GameServerEventType = "gameserver.events.added"
-	GameServerEventUpdated GameServerEventType = "gameserver.events.updated"
-	GameServerEventDeleted GameServerEventType = "gameserver.events.deleted"
+	GameServerEventAdded    GameServerEventType = "gameserver.events.added"
+	GameServerEventUpdated  GameServerEventType = "gameserver.events.updated"
+	GameServerEventDeleted  GameServerEventType = "gameserver.events.deleted"
+	GameServerEventDeleting GameServerEventType = "gameserver.events.deleting"
+	GameServerEventSnapshot GameServerEventType = "gameserver.events.snapshot"
 )
 
 type GameServerEventType string
@@ -20,6 +22,8 @@ type GameServerEvent struct {
 
 func init() {
 	RegisterEventFactory(&v1.GameServer{}, GameServerAdded, GameServerUpdated, GameServerDeleted)
+	RegisterDeletingEventFactory(&v1.GameServer{}, GameServerDeleting)
+	RegisterSnapshotEventFactory(&v1.GameServer{}, GameServerSnapshot)
 }
 
 // GameServerAdded is the data structure for reconcile events of type Add
@@ -49,6 +53,24 @@ func GameServerDeleted(message Message) Event {
 	}
 }
 
+// GameServerDeleting is the data structure for reconcile events of type Update when the deletion of the resource has been requested
+func GameServerDeleting(message Message) Event {
+	return &GameServerEvent{
+		Source:  EventSourceOnUpdate,
+		Type:    GameServerEventDeleting,
+		Message: message,
+	}
+}
+
+// GameServerSnapshot is the data structure for events of resources that existed when the broadcaster started
+func GameServerSnapshot(message Message) Event {
+	return &GameServerEvent{
+		Source:  EventSourceOnSnapshot,
+		Type:    GameServerEventSnapshot,
+		Message: message,
+	}
+}
+
 // EventType returns the type of the reconcile event for a GameServer.
 // For example: Added, Updated, Deleted
 func (t *GameServerEvent) EventType() EventType {
@@ -61,6 +83,11 @@ func (t *GameServerEvent) EventSource() EventSource {
 	return t.Source
 }
 
+// Metadata returns the metadata of the message of the event, if any
+func (t *GameServerEvent) Metadata() *Metadata {
+	return MetadataOf(t.Message)
+}
+
 // String is a helper method that returns the string version of a GameServerEventType
 func (t GameServerEventType) String() string {
 	return string(t)
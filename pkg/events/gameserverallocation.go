@@ -0,0 +1,76 @@
+package events
+
+import (
+	allocationv1 "agones.dev/agones/pkg/apis/allocation/v1"
+)
+
+var (
+	GameServerAllocationEventAdded   GameServerAllocationEventType = "gameserverallocation.events.added"
+	GameServerAllocationEventUpdated GameServerAllocationEventType = "gameserverallocation.events.updated"
+	GameServerAllocationEventDeleted GameServerAllocationEventType = "gameserverallocation.events.deleted"
+)
+
+type GameServerAllocationEventType string
+
+// GameServerAllocationEvent is the data structure for reconcile events associated with Agones GameServerAllocations
+// It holds the event source (OnAdd, OnUpdate, OnDelete) and the event type (Added, Updated, Deleted).
+// GameServerAllocations are not stored by Kubernetes and can't be watched. These events are published using Broadcaster.Publish
+// by applications that create allocations, like allocator proxies.
+type GameServerAllocationEvent struct {
+	Source  EventSource                   `json:"source"`
+	Type    GameServerAllocationEventType `json:"type"`
+	Message `json:"message"`
+}
+
+func init() {
+	RegisterEventFactory(&allocationv1.GameServerAllocation{}, GameServerAllocationAdded, GameServerAllocationUpdated, GameServerAllocationDeleted)
+}
+
+// GameServerAllocationAdded is the data structure for reconcile events of type Add
+func GameServerAllocationAdded(message Message) Event {
+	return &GameServerAllocationEvent{
+		Source:  EventSourceOnAdd,
+		Type:    GameServerAllocationEventAdded,
+		Message: message,
+	}
+}
+
+// GameServerAllocationUpdated is the data structure for reconcile events of type Update
+func GameServerAllocationUpdated(message Message) Event {
+	return &GameServerAllocationEvent{
+		Source:  EventSourceOnUpdate,
+		Type:    GameServerAllocationEventUpdated,
+		Message: message,
+	}
+}
+
+// GameServerAllocationDeleted is the data structure for reconcile events of type Delete
+func GameServerAllocationDeleted(message Message) Event {
+	return &GameServerAllocationEvent{
+		Source:  EventSourceOnDelete,
+		Type:    GameServerAllocationEventDeleted,
+		Message: message,
+	}
+}
+
+// EventType returns the type of the reconcile event for a GameServerAllocation.
+// For example: Added, Updated, Deleted
+func (t *GameServerAllocationEvent) EventType() EventType {
+	return EventType(t.Type)
+}
+
+// EventSource return the event source that generated the event.
+// For example: OnAdd, OnUpdate, OnDelete
+func (t *GameServerAllocationEvent) EventSource() EventSource {
+	return t.Source
+}
+
+// Metadata returns the metadata of the message of the event, if any
+func (t *GameServerAllocationEvent) Metadata() *Metadata {
+	return MetadataOf(t.Message)
+}
+
+// String is a helper method that returns the string version of a GameServerAllocationEventType
+func (t GameServerAllocationEventType) String() string {
+	return string(t)
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+)
+
+var (
+	GameServerSetEventAdded    GameServerSetEventType = "gameserverset.events.added"
+	GameServerSetEventUpdated  GameServerSetEventType = "gameserverset.events.updated"
+	GameServerSetEventDeleted  GameServerSetEventType = "gameserverset.events.deleted"
+	GameServerSetEventDeleting GameServerSetEventType = "gameserverset.events.deleting"
+	GameServerSetEventSnapshot GameServerSetEventType = "gameserverset.events.snapshot"
+)
+
+type GameServerSetEventType string
+
+// GameServerSetEvent is the data structure for reconcile events associated with Agones GameServerSets
+// It holds the event source (OnAdd, OnUpdate, OnDelete) and the event type (Added, Updated, Deleted).
+type GameServerSetEvent struct {
+	Source  EventSource            `json:"source"`
+	Type    GameServerSetEventType `json:"type"`
+	Message `json:"message"`
+}
+
+func init() {
+	RegisterEventFactory(&v1.GameServerSet{}, GameServerSetAdded, GameServerSetUpdated, GameServerSetDeleted)
+	RegisterDeletingEventFactory(&v1.GameServerSet{}, GameServerSetDeleting)
+	RegisterSnapshotEventFactory(&v1.GameServerSet{}, GameServerSetSnapshot)
+}
+
+// GameServerSetAdded is the data structure for reconcile events of type Add
+func GameServerSetAdded(message Message) Event {
+	return &GameServerSetEvent{
+		Source:  EventSourceOnAdd,
+		Type:    GameServerSetEventAdded,
+		Message: message,
+	}
+}
+
+// GameServerSetUpdated is the data structure for reconcile events of type Update
+func GameServerSetUpdated(message Message) Event {
+	return &GameServerSetEvent{
+		Source:  EventSourceOnUpdate,
+		Type:    GameServerSetEventUpdated,
+		Message: message,
+	}
+}
+
+// GameServerSetDeleted is the data structure for reconcile events of type Delete
+func GameServerSetDeleted(message Message) Event {
+	return &GameServerSetEvent{
+		Source:  EventSourceOnDelete,
+		Type:    GameServerSetEventDeleted,
+		Message: message,
+	}
+}
+
+// GameServerSetDeleting is the data structure for reconcile events of type Update when the deletion of the resource has been requested
+func GameServerSetDeleting(message Message) Event {
+	return &GameServerSetEvent{
+		Source:  EventSourceOnUpdate,
+		Type:    GameServerSetEventDeleting,
+		Message: message,
+	}
+}
+
+// GameServerSetSnapshot is the data structure for events of resources that existed when the broadcaster started
+func GameServerSetSnapshot(message Message) Event {
+	return &GameServerSetEvent{
+		Source:  EventSourceOnSnapshot,
+		Type:    GameServerSetEventSnapshot,
+		Message: message,
+	}
+}
+
+// EventType returns the type of the reconcile event for a GameServerSet.
+// For example: Added, Updated, Deleted
+func (t *GameServerSetEvent) EventType() EventType {
+	return EventType(t.Type)
+}
+
+// EventSource return the event source that generated the event.
+// For example: OnAdd, OnUpdate, OnDelete
+func (t *GameServerSetEvent) EventSource() EventSource {
+	return t.Source
+}
+
+// Metadata returns the metadata of the message of the event, if any
+func (t *GameServerSetEvent) Metadata() *Metadata {
+	return MetadataOf(t.Message)
+}
+
+// String is a helper method that returns the string version of a GameServerSetEventType
+func (t GameServerSetEventType) String() string {
+	return string(t)
+}
@@ -1,5 +1,13 @@
 package handlers
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
 // EventHandler defines the contract for event handlers used by the GameSever controller
 // when notifying reconcile events
 type EventHandler interface {
@@ -7,3 +15,85 @@ type EventHandler interface {
 	OnUpdate(oldObj interface{}, newObj interface{}) error
 	OnDelete(obj interface{}) error
 }
+
+// DeleteStateHandler is implemented by EventHandlers that need to know if the final state of a deleted resource is unknown.
+// That happens when the deletion was missed, e.g. during a watch disconnection, and obj is the last state known by the cache.
+type DeleteStateHandler interface {
+	OnDeleteWithState(obj interface{}, finalStateUnknown bool) error
+}
+
+// HandleDelete notifies the deletion of obj using OnDeleteWithState if the handler implements DeleteStateHandler
+func HandleDelete(handler EventHandler, obj interface{}, finalStateUnknown bool) error {
+	if h, ok := handler.(DeleteStateHandler); ok {
+		return h.OnDeleteWithState(obj, finalStateUnknown)
+	}
+
+	return handler.OnDelete(obj)
+}
+
+// ErrDropped is returned by event handlers for events dropped on purpose, e.g. while publishing is paused.
+// Dropped events are not retried.
+var ErrDropped = errors.New("event dropped")
+
+// FailedEvent is an event that failed to be published. Event keeps the metadata it was built with.
+// Brokers are the names of the brokers that failed to publish it, every broker matching the event if empty.
+// Publish publishes the event again only to the brokers passed. It returns a *PublishError with the brokers that failed again.
+type FailedEvent struct {
+	Event   events.Event
+	Brokers []string
+	Err     error
+	Publish func(brokers []string) error
+}
+
+// PublishError is returned by event handlers when events failed to be published by some of their brokers.
+// Each event is retried on its own, only by the brokers that failed.
+type PublishError struct {
+	Events []*FailedEvent
+}
+
+func (e *PublishError) Error() string {
+	messages := make([]string, 0, len(e.Events))
+	for _, failed := range e.Events {
+		message := failed.Err.Error()
+		if len(failed.Brokers) > 0 {
+			message = fmt.Sprintf("brokers %s: %s", strings.Join(failed.Brokers, ","), message)
+		}
+		if failed.Event != nil {
+			message = fmt.Sprintf("%s: %s", failed.Event.EventType(), message)
+		}
+		messages = append(messages, message)
+	}
+
+	return "error publishing events: " + strings.Join(messages, "; ")
+}
+
+// FailedEvents returns the events that failed to be published if err is a *PublishError
+func FailedEvents(err error) ([]*FailedEvent, bool) {
+	var publishErr *PublishError
+	if !errors.As(err, &publishErr) {
+		return nil, false
+	}
+
+	return publishErr.Events, true
+}
+
+// Republish publishes the events that failed to be published again, in order and only to the brokers that failed.
+// Events after one that fails again are not published, so they are still published in the order they were observed.
+// It returns a *PublishError with the events that failed again and the ones after them.
+func Republish(failed []*FailedEvent) error {
+	for i, f := range failed {
+		err := f.Publish(f.Brokers)
+		if err == nil {
+			continue
+		}
+
+		again, ok := FailedEvents(err)
+		if !ok {
+			again = []*FailedEvent{{Event: f.Event, Brokers: f.Brokers, Err: err, Publish: f.Publish}}
+		}
+
+		return &PublishError{Events: append(again, failed[i+1:]...)}
+	}
+
+	return nil
+}
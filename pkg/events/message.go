@@ -1,11 +1,112 @@
 package events
 
+import "k8s.io/apimachinery/pkg/runtime"
+
 // EventMessage is the data structure for messages that are resulting of reconcile events.
 type EventMessage struct {
 	Body interface{} `json:"body"`
+	Meta *Metadata   `json:"metadata,omitempty"`
+}
+
+// ObjectMessage is implemented by messages which content is not the resource itself, like messages rendered from templates.
+// Object returns the resource the message was built from.
+type ObjectMessage interface {
+	Object() runtime.Object
+}
+
+// AddedMessage is the message for resources that have been added
+type AddedMessage struct {
+	Obj  runtime.Object
+	Meta *Metadata
+}
+
+// UpdatedMessage is the message for resources that have been updated.
+// It is encoded with the old_obj and new_obj fields.
+type UpdatedMessage struct {
+	Old  runtime.Object `json:"old_obj"`
+	New  runtime.Object `json:"new_obj"`
+	Meta *Metadata      `json:"-"`
+}
+
+// DeletedMessage is the message for resources that have been deleted
+type DeletedMessage struct {
+	Obj  runtime.Object
+	Meta *Metadata
 }
 
 // Content extracts the body of the EventMessage
 func (e *EventMessage) Content() interface{} {
 	return e.Body
 }
+
+// Metadata returns the information that identifies the event that resulted in the EventMessage
+func (e *EventMessage) Metadata() *Metadata {
+	return e.Meta
+}
+
+// Content returns the resource that has been added
+func (m *AddedMessage) Content() interface{} {
+	return m.Obj
+}
+
+// Metadata returns the information that identifies the event that resulted in the AddedMessage
+func (m *AddedMessage) Metadata() *Metadata {
+	return m.Meta
+}
+
+// Content returns both the old and new versions of the resource
+func (m *UpdatedMessage) Content() interface{} {
+	return m
+}
+
+// Metadata returns the information that identifies the event that resulted in the UpdatedMessage
+func (m *UpdatedMessage) Metadata() *Metadata {
+	return m.Meta
+}
+
+// Content returns the resource that has been deleted
+func (m *DeletedMessage) Content() interface{} {
+	return m.Obj
+}
+
+// Metadata returns the information that identifies the event that resulted in the DeletedMessage
+func (m *DeletedMessage) Metadata() *Metadata {
+	return m.Meta
+}
+
+// ObjectAs returns the resource carried by the message or event as T.
+// For update messages, the new version of the resource is returned.
+// For example: gs, ok := ObjectAs[*v1.GameServer](message)
+func ObjectAs[T runtime.Object](message Message) (T, bool) {
+	obj, ok := ResourceObject(message)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	t, ok := obj.(T)
+	return t, ok
+}
+
+// UpdatedAs returns the old and new versions of the resource carried by an update message or event as T.
+// For example: oldGS, newGS, ok := UpdatedAs[*v1.GameServer](message)
+func UpdatedAs[T runtime.Object](message Message) (T, T, bool) {
+	var zero T
+
+	m, ok := message.Content().(*UpdatedMessage)
+	if !ok {
+		return zero, zero, false
+	}
+
+	oldObj, ok := m.Old.(T)
+	if !ok {
+		return zero, zero, false
+	}
+
+	newObj, ok := m.New.(T)
+	if !ok {
+		return zero, zero, false
+	}
+
+	return oldObj, newObj, true
+}
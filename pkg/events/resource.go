@@ -0,0 +1,54 @@
+package events
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ResourceEvent is the data structure for reconcile events associated with resources watched as unstructured objects.
+// Event types are derived from the kind of the resource. For example: matchticket.events.added
+type ResourceEvent struct {
+	Source  EventSource `json:"source"`
+	Type    EventType   `json:"type"`
+	Message `json:"message"`
+}
+
+// RegisterUnstructuredEventFactory register events builders for resources of the kind that are watched as unstructured objects
+func RegisterUnstructuredEventFactory(gvk schema.GroupVersionKind) {
+	prefix := strings.ToLower(gvk.Kind) + ".events."
+	EventFactoryRegistry[gvk.String()] = &EventFactory{
+		OnAdded:    resourceEventBuilder(EventSourceOnAdd, EventType(prefix+"added")),
+		OnUpdated:  resourceEventBuilder(EventSourceOnUpdate, EventType(prefix+"updated")),
+		OnDeleted:  resourceEventBuilder(EventSourceOnDelete, EventType(prefix+"deleted")),
+		OnDeleting: resourceEventBuilder(EventSourceOnUpdate, EventType(prefix+"deleting")),
+		OnSnapshot: resourceEventBuilder(EventSourceOnSnapshot, EventType(prefix+"snapshot")),
+	}
+}
+
+func resourceEventBuilder(source EventSource, eventType EventType) EventBuilder {
+	return func(message Message) Event {
+		return &ResourceEvent{
+			Source:  source,
+			Type:    eventType,
+			Message: message,
+		}
+	}
+}
+
+// EventType returns the type of the reconcile event for the resource.
+// For example: matchticket.events.added
+func (t *ResourceEvent) EventType() EventType {
+	return t.Type
+}
+
+// EventSource return the event source that generated the event.
+// For example: OnAdd, OnUpdate, OnDelete
+func (t *ResourceEvent) EventSource() EventSource {
+	return t.Source
+}
+
+// Metadata returns the metadata of the message of the event, if any
+func (t *ResourceEvent) Metadata() *Metadata {
+	return MetadataOf(t.Message)
+}
@@ -0,0 +1,69 @@
+package events
+
+var (
+	SnapshotEventCompleted SnapshotEventType = "snapshot.events.completed"
+)
+
+type SnapshotEventType string
+
+// SnapshotEvent is the data structure for the event published once the resources of a kind that existed when the
+// broadcaster started have been published as snapshot events
+type SnapshotEvent struct {
+	Source  EventSource       `json:"source"`
+	Type    SnapshotEventType `json:"type"`
+	Message `json:"message"`
+}
+
+// Snapshot describes the snapshot events published for a kind of resource.
+// Count is the number of resources published, Failed the number of resources that could not be published.
+type Snapshot struct {
+	Group  string `json:"group,omitempty"`
+	Kind   string `json:"kind"`
+	Count  int    `json:"count"`
+	Failed int    `json:"failed,omitempty"`
+}
+
+// SnapshotMessage is the message of snapshot completed events. Its content is the Snapshot.
+type SnapshotMessage struct {
+	Snapshot *Snapshot
+	Meta     *Metadata
+}
+
+// Content returns the snapshot
+func (m *SnapshotMessage) Content() interface{} {
+	return m.Snapshot
+}
+
+// Metadata returns the information that identifies the event that resulted in the SnapshotMessage
+func (m *SnapshotMessage) Metadata() *Metadata {
+	return m.Meta
+}
+
+// SnapshotCompleted returns the event published once the snapshot of a kind of resource is completed
+func SnapshotCompleted(message *SnapshotMessage) Event {
+	return &SnapshotEvent{
+		Source:  EventSourceOnSnapshot,
+		Type:    SnapshotEventCompleted,
+		Message: message,
+	}
+}
+
+// EventType returns the type of the snapshot event
+func (t *SnapshotEvent) EventType() EventType {
+	return EventType(t.Type)
+}
+
+// EventSource return the event source that generated the event
+func (t *SnapshotEvent) EventSource() EventSource {
+	return t.Source
+}
+
+// Metadata returns the metadata of the message of the event, if any
+func (t *SnapshotEvent) Metadata() *Metadata {
+	return MetadataOf(t.Message)
+}
+
+// String is a helper method that returns the string version of a SnapshotEventType
+func (t SnapshotEventType) String() string {
+	return string(t)
+}
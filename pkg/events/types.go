@@ -1,9 +1,13 @@
 package events
 
+import "time"
+
 var (
 	EventSourceOnAdd    EventSource = "OnAdd"
 	EventSourceOnUpdate EventSource = "OnUpdate"
 	EventSourceOnDelete EventSource = "OnDelete"
+	// EventSourceOnSnapshot is the source of the events published for resources that existed when the broadcaster started
+	EventSourceOnSnapshot EventSource = "OnSnapshot"
 )
 
 type EventSource string
@@ -20,6 +24,39 @@ type Message interface {
 	Content() interface{}
 }
 
+// MetadataMessage is implemented by messages that carry the Metadata of their event, like the messages built by the
+// broadcaster. Custom messages don't have to implement it.
+type MetadataMessage interface {
+	Metadata() *Metadata
+}
+
+// MetadataOf returns the metadata of the message. It returns nil if the message doesn't carry metadata.
+func MetadataOf(message Message) *Metadata {
+	if m, ok := message.(MetadataMessage); ok {
+		return m.Metadata()
+	}
+
+	return nil
+}
+
+// Metadata holds the information that identifies a particular event.
+// Sequence is a monotonically increasing number per resource that can be used by consumers to order events.
+// Sequences are kept in memory: they are only ordered among the events published by the same Instance since it started,
+// and start over at 1 when the broadcaster restarts, a new leader is elected or the resource is assigned to another replica.
+// For delete events, DeletionTimestamp is the time the deletion was requested while ObservedAt is the time it was observed.
+// FinalStateUnknown is set when the deletion was missed and the resource is the last state known by the broadcaster.
+type Metadata struct {
+	ID                string     `json:"id"`
+	ObservedAt        time.Time  `json:"observed_at"`
+	ClusterName       string     `json:"cluster_name,omitempty"`
+	Instance          string     `json:"instance,omitempty"`
+	ResourceUID       string     `json:"resource_uid,omitempty"`
+	ResourceVersion   string     `json:"resource_version,omitempty"`
+	Sequence          uint64     `json:"sequence"`
+	DeletionTimestamp *time.Time `json:"deletion_timestamp,omitempty"`
+	FinalStateUnknown bool       `json:"final_state_unknown,omitempty"`
+}
+
 // String returns the string representation of a EventType
 func (t EventType) String() string {
 	return string(t)
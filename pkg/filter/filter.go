@@ -0,0 +1,188 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+const (
+	ObjectVariable      = "object"
+	OldObjectVariable   = "oldObject"
+	EventTypeVariable   = "eventType"
+	EventSourceVariable = "eventSource"
+)
+
+// ErrMissingField is reported when an event doesn't match because the expression accesses a field that is not set,
+// e.g. a label the resource doesn't have
+var ErrMissingField = errors.New("field is not set")
+
+// Filter is a CEL expression that decides whether an event is published.
+// Expressions have access to the resource of the event as object and, for update events, to the previous version of the resource as oldObject.
+// For other events oldObject is an empty map. eventType and eventSource hold the type and source of the event.
+// Events that don't carry a resource, like snapshot.events.completed, have an empty map as object.
+// Resources are represented by their JSON encoding. For example: object.status.state == "Allocated" && object.metadata.labels["mode"] == "ranked"
+// Events of resources without the fields accessed by the expression, e.g. without the mode label, don't match.
+type Filter struct {
+	expression string
+	program    cel.Program
+}
+
+// New compiles and type checks the expression. It returns an error if the expression is invalid or does not evaluate to a boolean.
+// An empty expression returns a nil Filter that matches every event.
+func New(expression string) (*Filter, error) {
+	if expression == "" {
+		return nil, nil
+	}
+
+	env, err := cel.NewEnv(
+		cel.Variable(ObjectVariable, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(OldObjectVariable, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(EventTypeVariable, cel.StringType),
+		cel.Variable(EventSourceVariable, cel.StringType),
+		// Numbers decoded from JSON are doubles and must be comparable to integer literals
+		cel.CrossTypeNumericComparisons(true),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating filter environment: %v", err)
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid filter %q: %v", expression, issues.Err())
+	}
+
+	if out := ast.OutputType().String(); out != cel.BoolType.String() && out != cel.DynType.String() {
+		return nil, fmt.Errorf("invalid filter %q: expression must evaluate to bool, got %s", expression, out)
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %v", expression, err)
+	}
+
+	return &Filter{
+		expression: expression,
+		program:    program,
+	}, nil
+}
+
+// String returns the expression of the filter
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+
+	return f.expression
+}
+
+// Match evaluates the expression against the event. A nil Filter matches every event.
+func (f *Filter) Match(event events.Event) (bool, error) {
+	return f.Eval(NewActivation(event))
+}
+
+// Eval evaluates the expression against the variables of the activation. A nil Filter matches every event.
+// Events accessing fields that are not set don't match, the error wraps ErrMissingField.
+func (f *Filter) Eval(activation *Activation) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+
+	vars, err := activation.variables()
+	if err != nil {
+		return false, err
+	}
+
+	out, _, err := f.program.Eval(vars)
+	if err != nil {
+		if isMissingField(err) {
+			return false, fmt.Errorf("%w: %v", ErrMissingField, err)
+		}
+		return false, fmt.Errorf("error evaluating filter %q: %v", f.expression, err)
+	}
+
+	matched, ok := out.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("filter %q evaluated to %s instead of bool", f.expression, out.Type().TypeName())
+	}
+
+	return bool(matched), nil
+}
+
+// Activation holds the variables of an event. Resources are encoded the first time a filter is evaluated and
+// reused by every filter evaluated against the same activation. It is not safe for concurrent use.
+type Activation struct {
+	event events.Event
+	vars  map[string]interface{}
+	err   error
+}
+
+func NewActivation(event events.Event) *Activation {
+	return &Activation{event: event}
+}
+
+func (a *Activation) variables() (map[string]interface{}, error) {
+	if a.vars == nil && a.err == nil {
+		a.vars, a.err = variablesOf(a.event)
+	}
+
+	return a.vars, a.err
+}
+
+func variablesOf(event events.Event) (map[string]interface{}, error) {
+	message, ok := event.(events.Message)
+	if !ok {
+		return nil, fmt.Errorf("event %s does not carry a message", event.EventType())
+	}
+
+	var err error
+	object := map[string]interface{}{}
+	if obj, ok := events.ResourceObject(message); ok {
+		if object, err = toMap(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	oldObject := map[string]interface{}{}
+	if updated, ok := message.Content().(*events.UpdatedMessage); ok && updated.Old != nil {
+		if oldObject, err = toMap(updated.Old); err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]interface{}{
+		ObjectVariable:      object,
+		OldObjectVariable:   oldObject,
+		EventTypeVariable:   event.EventType().String(),
+		EventSourceVariable: event.EventSource().String(),
+	}, nil
+}
+
+// isMissingField returns true if the evaluation failed because a key or attribute is not set.
+// CEL doesn't export the type of these errors, they are identified by their message.
+func isMissingField(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "no such key") || strings.HasPrefix(msg, "no such attribute")
+}
+
+// toMap returns the JSON representation of the resource, the same representation brokers publish
+func toMap(obj runtime.Object) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding resource: %v", err)
+	}
+
+	content := map[string]interface{}{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("error decoding resource: %v", err)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,187 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+func newGameServer(state v1.GameServerState, mode string) *v1.GameServer {
+	return &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple-udp-agones",
+			Namespace: "default",
+			Labels:    map[string]string{"mode": mode},
+		},
+		Status: v1.GameServerStatus{
+			State: state,
+			Ports: []v1.GameServerStatusPort{{Name: "default", Port: 7412}},
+		},
+	}
+}
+
+func Test_Filter_Match(t *testing.T) {
+	ready := newGameServer(v1.GameServerStateReady, "ranked")
+	allocated := newGameServer(v1.GameServerStateAllocated, "ranked")
+	casual := newGameServer(v1.GameServerStateAllocated, "casual")
+
+	testCases := []struct {
+		desc       string
+		expression string
+		event      events.Event
+		want       bool
+	}{
+		{
+			desc:       "it should match every event when the expression is empty",
+			expression: "",
+			event:      events.GameServerAdded(&events.AddedMessage{Obj: ready}),
+			want:       true,
+		},
+		{
+			desc:       "it should match resources by state and label",
+			expression: `object.status.state == "Allocated" && object.metadata.labels["mode"] == "ranked"`,
+			event:      events.GameServerAdded(&events.AddedMessage{Obj: allocated}),
+			want:       true,
+		},
+		{
+			desc:       "it should not match resources with a different label",
+			expression: `object.status.state == "Allocated" && object.metadata.labels["mode"] == "ranked"`,
+			event:      events.GameServerAdded(&events.AddedMessage{Obj: casual}),
+			want:       false,
+		},
+		{
+			desc:       "it should expose the previous version of the resource on updates",
+			expression: `oldObject.status.state == "Ready" && object.status.state == "Allocated"`,
+			event:      events.GameServerUpdated(&events.UpdatedMessage{Old: ready, New: allocated}),
+			want:       true,
+		},
+		{
+			desc:       "it should expose an empty oldObject for other events",
+			expression: `!has(oldObject.status)`,
+			event:      events.GameServerDeleted(&events.DeletedMessage{Obj: ready}),
+			want:       true,
+		},
+		{
+			desc:       "it should expose the event type and source",
+			expression: `eventType == "gameserver.events.deleted" && eventSource == "OnDelete"`,
+			event:      events.GameServerDeleted(&events.DeletedMessage{Obj: ready}),
+			want:       true,
+		},
+		{
+			desc:       "it should expose an empty object for events without a resource",
+			expression: `!has(object.metadata) && eventType == "snapshot.events.completed"`,
+			event:      events.SnapshotCompleted(&events.SnapshotMessage{Snapshot: &events.Snapshot{Kind: "GameServer"}}),
+			want:       true,
+		},
+		{
+			desc:       "it should compare numbers with integer literals",
+			expression: `object.status.ports[0].port == 7412`,
+			event:      events.GameServerAdded(&events.AddedMessage{Obj: ready}),
+			want:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			filter, err := New(tc.expression)
+			require.NoError(t, err)
+
+			got, err := filter.Match(tc.event)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("it should not match when fields are missing", func(t *testing.T) {
+		for _, expression := range []string{
+			`object.metadata.labels["missing"] == "value"`,
+			`object.metadata.labels.missing == "value"`,
+			`object.spec.missing.nested == "value"`,
+		} {
+			filter, err := New(expression)
+			require.NoError(t, err)
+
+			got, err := filter.Match(events.GameServerAdded(&events.AddedMessage{Obj: ready}))
+			require.ErrorIs(t, err, ErrMissingField, expression)
+			require.False(t, got, expression)
+		}
+	})
+
+	t.Run("it should fail on other evaluation errors", func(t *testing.T) {
+		filter, err := New(`object.metadata.name / 2 == 1`)
+		require.NoError(t, err)
+
+		_, err = filter.Match(events.GameServerAdded(&events.AddedMessage{Obj: ready}))
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrMissingField)
+	})
+}
+
+func Test_Filter_Eval(t *testing.T) {
+	event := events.GameServerUpdated(&events.UpdatedMessage{
+		Old: newGameServer(v1.GameServerStateReady, "ranked"),
+		New: newGameServer(v1.GameServerStateAllocated, "ranked"),
+	})
+
+	t.Run("it should not encode the resources for nil filters", func(t *testing.T) {
+		activation := NewActivation(event)
+		var filter *Filter
+
+		got, err := filter.Eval(activation)
+		require.NoError(t, err)
+		require.True(t, got)
+		require.Nil(t, activation.vars)
+	})
+
+	t.Run("it should encode the resources once for every filter", func(t *testing.T) {
+		activation := NewActivation(event)
+
+		allocated, err := New(`object.status.state == "Allocated"`)
+		require.NoError(t, err)
+		got, err := allocated.Eval(activation)
+		require.NoError(t, err)
+		require.True(t, got)
+
+		vars := activation.vars
+		require.NotNil(t, vars)
+
+		ranked, err := New(`oldObject.metadata.labels["mode"] == "ranked"`)
+		require.NoError(t, err)
+		got, err = ranked.Eval(activation)
+		require.NoError(t, err)
+		require.True(t, got)
+		require.Equal(t, fmt.Sprintf("%p", vars), fmt.Sprintf("%p", activation.vars), "it should reuse the variables of the activation")
+	})
+}
+
+func Test_New_InvalidExpressions(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		expression string
+	}{
+		{
+			desc:       "it should fail on expressions that can't be parsed",
+			expression: `object.status.state ==`,
+		},
+		{
+			desc:       "it should fail on undeclared variables",
+			expression: `gameserver.status.state == "Ready"`,
+		},
+		{
+			desc:       "it should fail on expressions that don't evaluate to bool",
+			expression: `eventType + "suffix"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := New(tc.expression)
+			require.Error(t, err)
+		})
+	}
+}
@@ -9,27 +9,51 @@ import (
 	"github.com/pkg/errors"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
 )
 
 type Options struct {
 	SyncPeriod             *time.Duration
-	ServerPort             int
 	MetricsBindAddress     string
 	MaxConcurrentReconcile int
+	// Namespaces restricts the cache to resources deployed on these namespaces. All namespaces are watched if empty.
+	Namespaces map[string]cache.Config
+	// ByObject restricts the cache per resource type using namespaces, label and field selectors
+	ByObject map[client.Object]cache.ByObject
+	// LeaderElection makes only the elected replica run controllers and publish events. Disabled if nil.
+	LeaderElection *LeaderElection
+	// ClusterName labels the metrics of the manager
+	ClusterName string
+}
+
+// LeaderElection configures the election of the replica that publishes events using a Lease.
+// Namespace defaults to the namespace the broadcaster is running on. Durations default to the controller-runtime defaults.
+type LeaderElection struct {
+	ID            string
+	Namespace     string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
 }
 
 type Manager struct {
 	manager.Manager
+	clusterName string
 }
 
 func New(clientConf *rest.Config, options Options) (*Manager, error) {
-	mgr, err := manager.New(clientConf, manager.Options{
+	mgrOptions := manager.Options{
+		Scheme: Scheme,
 		Cache: cache.Options{
-			SyncPeriod: options.SyncPeriod,
+			SyncPeriod:        options.SyncPeriod,
+			DefaultNamespaces: options.Namespaces,
+			ByObject:          options.ByObject,
 		},
 		Metrics: server.Options{
 			BindAddress: options.MetricsBindAddress,
@@ -37,16 +61,48 @@ func New(clientConf *rest.Config, options Options) (*Manager, error) {
 		Controller: config.Controller{
 			MaxConcurrentReconciles: options.MaxConcurrentReconcile,
 		},
-	})
+	}
+
+	if le := options.LeaderElection; le != nil {
+		mgrOptions.LeaderElection = true
+		mgrOptions.LeaderElectionID = le.ID
+		mgrOptions.LeaderElectionNamespace = le.Namespace
+		mgrOptions.LeaderElectionReleaseOnCancel = true
+		mgrOptions.LeaseDuration = durationOrNil(le.LeaseDuration)
+		mgrOptions.RenewDeadline = durationOrNil(le.RenewDeadline)
+		mgrOptions.RetryPeriod = durationOrNil(le.RetryPeriod)
+	}
+
+	mgr, err := manager.New(clientConf, mgrOptions)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "manager could not be created")
 	}
 
-	return &Manager{mgr}, nil
+	return &Manager{Manager: mgr, clusterName: options.ClusterName}, nil
 }
 
 func (m *Manager) Start(ctx context.Context) error {
 	log.SetLogger(zap.New())
+
+	// Instances without leader election are elected as soon as the manager starts
+	leader := metrics.Leader.WithLabelValues(m.clusterName)
+	leader.Set(0)
+	go func() {
+		select {
+		case <-m.Elected():
+			leader.Set(1)
+		case <-ctx.Done():
+		}
+	}()
+
 	return m.Manager.Start(ctx)
 }
+
+func durationOrNil(d time.Duration) *time.Duration {
+	if d == 0 {
+		return nil
+	}
+
+	return &d
+}
@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const NAMESPACE = "agones_event_broadcaster"
+
+// ALL_BROKERS is the broker label of events filtered or failed before being handed to a particular broker
+const ALL_BROKERS = "all"
+
+// Reasons of the events dropped after failing to be published
+const (
+	// DROP_REASON_MAX_RETRIES is the reason of events still failing after their max retries
+	DROP_REASON_MAX_RETRIES = "max_retries"
+	// DROP_REASON_RETRY_TIMEOUT is the reason of events still failing once their retry timeout expired
+	DROP_REASON_RETRY_TIMEOUT = "retry_timeout"
+	// DROP_REASON_PENDING_FULL is the reason of events failing while too many events are waiting to be retried
+	DROP_REASON_PENDING_FULL = "pending_full"
+	// DROP_REASON_PAUSED is the reason of events dropped while publishing is paused, either by policy or because the buffer is full
+	DROP_REASON_PAUSED = "paused"
+	// DROP_REASON_RESUME_FAILED is the reason of buffered events that failed to be published once publishing was resumed
+	DROP_REASON_RESUME_FAILED = "resume_failed"
+)
+
+var (
+	// Leader is 1 when the broadcaster instance is the leader of the cluster, or leader election is disabled, and 0 otherwise
+	Leader = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "leader",
+		Help:      "Whether the broadcaster instance is the leader publishing events",
+	}, []string{"cluster"})
+
+	// ShardMembers is the number of replicas sharing the resources of the cluster when sharding is enabled
+	ShardMembers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "shard_members",
+		Help:      "Number of live replicas the resources are sharded across",
+	}, []string{"cluster"})
+
+	// ClusterUp is 1 when the caches of the cluster are synced and 0 when the broadcaster can't watch the cluster
+	ClusterUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "cluster_up",
+		Help:      "Whether the broadcaster is watching the resources of the cluster",
+	}, []string{"cluster"})
+
+	// EventsReceived counts the events handled by the broadcaster. Retries are not counted.
+	EventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "events_received_total",
+		Help:      "Number of events handled by the broadcaster",
+	}, []string{"cluster", "kind", "event_type"})
+
+	// EventsFiltered counts the events not published because of filters or because the resource is owned by another replica
+	EventsFiltered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "events_filtered_total",
+		Help:      "Number of events not published because of filters or sharding",
+	}, []string{"cluster", "kind", "event_type", "broker"})
+
+	// FilterErrors counts the events not published because their filter failed to be evaluated
+	FilterErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "filter_errors_total",
+		Help:      "Number of events not published because a filter failed to be evaluated",
+	}, []string{"cluster", "kind", "event_type", "broker"})
+
+	// EventsPublished counts the events published by each broker
+	EventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "events_published_total",
+		Help:      "Number of events published",
+	}, []string{"cluster", "kind", "event_type", "broker"})
+
+	// EventsFailed counts the events that failed to be published. Events are counted once, when they fail for the first time.
+	EventsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "events_failed_total",
+		Help:      "Number of events that failed to be published",
+	}, []string{"cluster", "kind", "event_type", "broker"})
+
+	// EventsRetried counts the times failed events were published again by each broker. Retries failing before the
+	// event is handed to the brokers are counted with the all broker.
+	EventsRetried = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "events_retried_total",
+		Help:      "Number of times failed events were published again",
+	}, []string{"cluster", "kind", "event_type", "broker"})
+
+	// EventsDropped counts the events dropped after failing to be published, by reason
+	EventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "events_dropped_total",
+		Help:      "Number of events dropped after failing to be published",
+	}, []string{"cluster", "kind", "event_type", "broker", "reason"})
+
+	// EnvelopeBuildSeconds is the time spent by brokers building envelopes
+	EnvelopeBuildSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "envelope_build_seconds",
+		Help:      "Time spent building envelopes",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+	}, []string{"broker"})
+
+	// SendSeconds is the time spent by brokers sending envelopes
+	SendSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "send_seconds",
+		Help:      "Time spent sending envelopes",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"broker"})
+
+	// PublishLagSeconds is the time between the last change of the resource and the event being published by every broker
+	PublishLagSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "publish_lag_seconds",
+		Help:      "Time between the last change of the resource and the event being published",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"cluster", "kind", "event_type"})
+
+	// PendingEvents is the number of events waiting to be retried
+	PendingEvents = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "pending_events",
+		Help:      "Number of events waiting to be retried",
+	}, []string{"cluster", "kind"})
+
+	// InFlightSends is the number of envelopes being sent by each broker
+	InFlightSends = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "in_flight_sends",
+		Help:      "Number of envelopes being sent",
+	}, []string{"broker"})
+
+	// StreamClients is the number of clients connected to the event stream
+	StreamClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "stream_clients",
+		Help:      "Number of clients connected to the event stream",
+	})
+
+	// StreamClientsDropped is the number of clients disconnected for not keeping up with the event stream
+	StreamClientsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "stream_clients_dropped_total",
+		Help:      "Number of clients disconnected for not keeping up with the event stream",
+	})
+
+	// RPCSubscribers is the number of subscribers connected to the gRPC API
+	RPCSubscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "rpc_subscribers",
+		Help:      "Number of subscribers connected to the gRPC API",
+	})
+
+	// RPCSubscribersDropped is the number of gRPC subscribers disconnected for not keeping up with the stream
+	RPCSubscribersDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "rpc_subscribers_dropped_total",
+		Help:      "Number of gRPC subscribers disconnected for not keeping up with the stream",
+	})
+
+	// Paused is 1 while publishing is paused
+	Paused = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "paused",
+		Help:      "Whether publishing is paused",
+	})
+
+	// PausedEvents is the number of events buffered while publishing is paused
+	PausedEvents = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "paused_events",
+		Help:      "Number of events buffered while publishing is paused",
+	})
+
+	// PauseDroppedEvents is the number of events dropped while publishing is paused, either by policy or because the buffer is full
+	PauseDroppedEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "pause_dropped_events_total",
+		Help:      "Number of events dropped while publishing is paused",
+	})
+)
+
+func init() {
+	// Metrics are served by the manager on the metrics bind address
+	ctrlmetrics.Registry.MustRegister(
+		Leader,
+		ShardMembers,
+		ClusterUp,
+		EventsReceived,
+		EventsFiltered,
+		FilterErrors,
+		EventsPublished,
+		EventsFailed,
+		EventsRetried,
+		EventsDropped,
+		EnvelopeBuildSeconds,
+		SendSeconds,
+		PublishLagSeconds,
+		PendingEvents,
+		InFlightSends,
+		StreamClients,
+		StreamClientsDropped,
+		RPCSubscribers,
+		RPCSubscribersDropped,
+		Paused,
+		PausedEvents,
+		PauseDroppedEvents,
+	)
+}
@@ -0,0 +1,291 @@
+package projection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	LastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+	HashPrefix                  = "sha256:"
+)
+
+// IdentityFields are always kept when Include is set, so consumers can tell which resource was published
+var IdentityFields = []string{"apiVersion", "kind", "metadata.name", "metadata.namespace", "metadata.uid"}
+
+// Config defines how resources are projected and redacted before being published.
+// Include and Exclude are field masks using dot notation. For example: status.ports or spec.template.
+// Masks crossing lists are applied to every item of the list. When any mask is set, resources are published as unstructured objects.
+// IdentityFields are kept even if they are not included or are excluded.
+// Labels and annotations keys can be dropped or have their values hashed. Keys support glob patterns like kubectl.kubernetes.io/*.
+type Config struct {
+	Include           []string
+	Exclude           []string
+	DropManagedFields bool
+	DropLabels        []string
+	HashLabels        []string
+	DropAnnotations   []string
+	HashAnnotations   []string
+}
+
+// DefaultConfig strips managedFields and the last-applied-configuration annotation from resources
+func DefaultConfig() *Config {
+	return &Config{
+		DropManagedFields: true,
+		DropAnnotations:   []string{LastAppliedConfigAnnotation},
+	}
+}
+
+// Projector applies a projection Config to resources
+type Projector struct {
+	config   *Config
+	scheme   *runtime.Scheme
+	include  [][]string
+	exclude  [][]string
+	identity [][]string
+}
+
+// New returns a Projector for the given config. The scheme sets the apiVersion and kind of typed resources, which are
+// empty on resources read from the cache. It returns an error if masks or key patterns are malformed.
+func New(config *Config, scheme *runtime.Scheme) (*Projector, error) {
+	if scheme == nil {
+		return nil, fmt.Errorf("a scheme is required")
+	}
+
+	include, err := parseMasks(config.Include)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := parseMasks(config.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := parseMasks(IdentityFields)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, patterns := range [][]string{config.DropLabels, config.HashLabels, config.DropAnnotations, config.HashAnnotations} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid key pattern %q: %v", pattern, err)
+			}
+		}
+	}
+
+	return &Projector{
+		config:   config,
+		scheme:   scheme,
+		include:  include,
+		exclude:  exclude,
+		identity: identity,
+	}, nil
+}
+
+// Project returns a projected copy of obj. The original object is never modified.
+func (p *Projector) Project(obj runtime.Object) (runtime.Object, error) {
+	if obj == nil {
+		return nil, nil
+	}
+
+	projected := obj.DeepCopyObject()
+	if projected.GetObjectKind().GroupVersionKind().Empty() {
+		// Resources of types missing from the scheme are published without apiVersion and kind
+		if gvk, err := apiutil.GVKForObject(projected, p.scheme); err == nil {
+			projected.GetObjectKind().SetGroupVersionKind(gvk)
+		}
+	}
+
+	accessor, err := meta.Accessor(projected)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing resource metadata: %v", err)
+	}
+
+	if p.config.DropManagedFields {
+		accessor.SetManagedFields(nil)
+	}
+	accessor.SetLabels(redact(accessor.GetLabels(), p.config.DropLabels, p.config.HashLabels))
+	accessor.SetAnnotations(redact(accessor.GetAnnotations(), p.config.DropAnnotations, p.config.HashAnnotations))
+
+	if len(p.include) == 0 && len(p.exclude) == 0 {
+		return projected, nil
+	}
+
+	// The JSON representation is used since it is what brokers publish
+	data, err := json.Marshal(projected)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding resource: %v", err)
+	}
+
+	content := map[string]interface{}{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("error converting resource to unstructured: %v", err)
+	}
+
+	identity := map[string]interface{}{}
+	for _, mask := range p.identity {
+		if v, ok := keep(content, mask); ok {
+			identity = merge(identity, v).(map[string]interface{})
+		}
+	}
+
+	if len(p.include) > 0 {
+		included := map[string]interface{}{}
+		for _, mask := range p.include {
+			if v, ok := keep(content, mask); ok {
+				included = merge(included, v).(map[string]interface{})
+			}
+		}
+		content = included
+	}
+
+	for _, mask := range p.exclude {
+		remove(content, mask)
+	}
+	content = merge(content, identity).(map[string]interface{})
+
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+// redact drops or hashes the values of the keys matching the patterns
+func redact(values map[string]string, drop, hash []string) map[string]string {
+	if len(values) == 0 {
+		return values
+	}
+
+	redacted := make(map[string]string, len(values))
+	for k, v := range values {
+		switch {
+		case matchAny(drop, k):
+			continue
+		case matchAny(hash, k):
+			sum := sha256.Sum256([]byte(v))
+			redacted[k] = HashPrefix + hex.EncodeToString(sum[:])
+		default:
+			redacted[k] = v
+		}
+	}
+
+	return redacted
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseMasks(masks []string) ([][]string, error) {
+	parsed := make([][]string, 0, len(masks))
+	for _, mask := range masks {
+		fields := strings.Split(strings.TrimSpace(mask), ".")
+		for _, field := range fields {
+			if field == "" {
+				return nil, fmt.Errorf("invalid field mask %q", mask)
+			}
+		}
+		parsed = append(parsed, fields)
+	}
+
+	return parsed, nil
+}
+
+// keep returns the subtree of node that contains only the fields on the path
+func keep(node interface{}, fields []string) (interface{}, bool) {
+	if len(fields) == 0 {
+		return node, true
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[fields[0]]
+		if !ok {
+			return nil, false
+		}
+
+		v, ok := keep(child, fields[1:])
+		if !ok {
+			return nil, false
+		}
+
+		return map[string]interface{}{fields[0]: v}, true
+	case []interface{}:
+		// Items that don't have the field are kept empty so indexes are preserved when merging masks
+		items := make([]interface{}, 0, len(n))
+		for _, item := range n {
+			v, ok := keep(item, fields)
+			if !ok {
+				v = map[string]interface{}{}
+			}
+			items = append(items, v)
+		}
+
+		return items, true
+	}
+
+	return nil, false
+}
+
+// merge deep merges src into dst and returns the result
+func merge(dst, src interface{}) interface{} {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		d, ok := dst.(map[string]interface{})
+		if !ok {
+			return s
+		}
+
+		for k, v := range s {
+			d[k] = merge(d[k], v)
+		}
+
+		return d
+	case []interface{}:
+		d, ok := dst.([]interface{})
+		if !ok || len(d) != len(s) {
+			return s
+		}
+
+		for i := range s {
+			d[i] = merge(d[i], s[i])
+		}
+
+		return d
+	}
+
+	return src
+}
+
+// remove deletes the field on the path from node
+func remove(node interface{}, fields []string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(fields) == 1 {
+			delete(n, fields[0])
+			return
+		}
+
+		if child, ok := n[fields[0]]; ok {
+			remove(child, fields[1:])
+		}
+	case []interface{}:
+		for _, item := range n {
+			remove(item, fields)
+		}
+	}
+}
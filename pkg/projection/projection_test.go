@@ -0,0 +1,150 @@
+package projection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// scheme knows the Agones types, the same way the scheme of the manager does
+var scheme = func() *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := v1.AddToScheme(s); err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+func newGameServer() *v1.GameServer {
+	return &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple-udp-agones",
+			Namespace: "default",
+			UID:       "a1b2c3",
+			Labels: map[string]string{
+				"mode":   "ranked",
+				"player": "player-1",
+			},
+			Annotations: map[string]string{
+				LastAppliedConfigAnnotation: "{}",
+				"agones.dev/sdk-version":    "1.5.0",
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "agones"}},
+		},
+		Status: v1.GameServerStatus{
+			State:   v1.GameServerStateReady,
+			Address: "172.17.0.2",
+			Ports: []v1.GameServerStatusPort{
+				{Name: "default", Port: 7412},
+			},
+		},
+	}
+}
+
+func Test_Projector_Project_Defaults(t *testing.T) {
+	gs := newGameServer()
+
+	projector, err := New(DefaultConfig(), scheme)
+	require.NoError(t, err)
+
+	got, err := projector.Project(gs)
+	require.NoError(t, err)
+
+	projected, ok := got.(*v1.GameServer)
+	require.True(t, ok, "resources should keep their type when no field masks are set")
+	require.Equal(t, "GameServer", projected.Kind, "it should set the kind from the scheme")
+	require.Equal(t, "agones.dev/v1", projected.APIVersion)
+	require.Nil(t, projected.ManagedFields)
+	require.NotContains(t, projected.Annotations, LastAppliedConfigAnnotation)
+	require.Equal(t, "1.5.0", projected.Annotations["agones.dev/sdk-version"])
+
+	// The original resource is shared with the controller cache and must not change
+	require.Len(t, gs.ManagedFields, 1)
+	require.Contains(t, gs.Annotations, LastAppliedConfigAnnotation)
+}
+
+func Test_Projector_Project_Redaction(t *testing.T) {
+	projector, err := New(&Config{
+		DropLabels:      []string{"mode"},
+		HashLabels:      []string{"play*"},
+		DropAnnotations: []string{"agones.dev/*"},
+	}, scheme)
+	require.NoError(t, err)
+
+	got, err := projector.Project(newGameServer())
+	require.NoError(t, err)
+
+	projected := got.(*v1.GameServer)
+	require.NotContains(t, projected.Labels, "mode")
+	sum := sha256.Sum256([]byte("player-1"))
+	require.Equal(t, HashPrefix+hex.EncodeToString(sum[:]), projected.Labels["player"])
+	require.Equal(t, map[string]string{LastAppliedConfigAnnotation: "{}"}, projected.Annotations)
+}
+
+func Test_Projector_Project_FieldMasks(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config *Config
+		want   string
+	}{
+		{
+			desc: "it should keep only the included fields",
+			config: &Config{
+				Include: []string{"metadata.name", "status.address", "status.ports.port"},
+			},
+			want: `{"apiVersion":"agones.dev/v1","kind":"GameServer","metadata":{"name":"simple-udp-agones","namespace":"default","uid":"a1b2c3"},"status":{"address":"172.17.0.2","ports":[{"port":7412}]}}`,
+		},
+		{
+			desc: "it should remove the excluded fields",
+			config: &Config{
+				Include:           []string{"metadata", "status.ports"},
+				DropManagedFields: true,
+				Exclude:           []string{"metadata.labels", "metadata.annotations", "metadata.creationTimestamp", "status.ports.name"},
+			},
+			want: `{"apiVersion":"agones.dev/v1","kind":"GameServer","metadata":{"name":"simple-udp-agones","namespace":"default","uid":"a1b2c3"},"status":{"ports":[{"port":7412}]}}`,
+		},
+		{
+			desc: "it should keep the identity fields when they are excluded",
+			config: &Config{
+				Include: []string{"status.state"},
+				Exclude: []string{"kind", "metadata.uid"},
+			},
+			want: `{"apiVersion":"agones.dev/v1","kind":"GameServer","metadata":{"name":"simple-udp-agones","namespace":"default","uid":"a1b2c3"},"status":{"state":"Ready"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			projector, err := New(tc.config, scheme)
+			require.NoError(t, err)
+
+			got, err := projector.Project(newGameServer())
+			require.NoError(t, err)
+
+			u, ok := got.(*unstructured.Unstructured)
+			require.True(t, ok)
+
+			data, err := json.Marshal(u.Object)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.want, string(data))
+		})
+	}
+}
+
+func Test_New_InvalidConfig(t *testing.T) {
+	_, err := New(&Config{Include: []string{"status..ports"}}, scheme)
+	require.Error(t, err)
+
+	_, err = New(&Config{DropLabels: []string{"[invalid"}}, scheme)
+	require.Error(t, err)
+
+	_, err = New(DefaultConfig(), nil)
+	require.Error(t, err, "it should require a scheme")
+}
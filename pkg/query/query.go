@@ -0,0 +1,540 @@
+package query
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/projection"
+	"github.com/Octops/agones-event-broadcaster/pkg/runtime/log"
+)
+
+const (
+	// PREFIX is the path the API is served on
+	PREFIX = "/api/v1/"
+	// DEFAULT_LIMIT is the number of items returned when the limit parameter is not set
+	DEFAULT_LIMIT = 100
+	// MAX_LIMIT is the maximum number of items returned by a single request
+	MAX_LIMIT = 1000
+
+	RESOURCE_GAMESERVERS = "gameservers"
+	RESOURCE_FLEETS      = "fleets"
+)
+
+// Source is the cache of a cluster resources are read from. Reader is nil while the caches of the cluster are not synced.
+type Source struct {
+	Cluster string
+	Reader  client.Reader
+}
+
+// Config of the API. Resources are the resources served, RESOURCE_GAMESERVERS or RESOURCE_FLEETS.
+// Only resources watched by the broadcaster should be served, otherwise the cache starts watching them on the first request.
+// Projector is applied to every resource before any field selection, so resources are redacted as when they are published.
+// Scheme is the scheme of the resources, used to set their apiVersion and kind. Required.
+type Config struct {
+	Resources []string
+	Projector *projection.Projector
+	Scheme    *runtime.Scheme
+}
+
+// Item is a resource returned by the API and the cluster it was read from
+type Item struct {
+	Cluster  string         `json:"cluster,omitempty"`
+	Resource runtime.Object `json:"resource"`
+}
+
+// List is a page of resources. Continue is set when there are more resources to be listed.
+type List struct {
+	Items    []Item `json:"items"`
+	Continue string `json:"continue,omitempty"`
+}
+
+// Error is the body of the responses of failed requests
+type Error struct {
+	Error string `json:"error"`
+}
+
+// API serves the GameServers and Fleets of the controller cache:
+// GET /api/v1/<resource> lists resources. Parameters: namespace, labelSelector, state (GameServers only),
+// fleet (GameServers only), cluster, limit, continue and fields.
+// GET /api/v1/<resource>/<namespace>/<name> returns a resource. Parameters: cluster and fields.
+// fields is a comma-separated list of field masks using dot notation. For example: metadata.name,status.state
+type API struct {
+	logger    *logrus.Entry
+	resources map[string]*resource
+	projector *projection.Projector
+	scheme    *runtime.Scheme
+	sources   func() []Source
+}
+
+// resource describes how a kind of resource is listed and filtered
+type resource struct {
+	newObject func() client.Object
+	newList   func() client.ObjectList
+	matchers  map[string]func(obj runtime.Object, values []string) bool
+	labels    map[string]string
+}
+
+var resources = map[string]*resource{
+	RESOURCE_GAMESERVERS: {
+		newObject: func() client.Object { return &v1.GameServer{} },
+		newList:   func() client.ObjectList { return &v1.GameServerList{} },
+		matchers: map[string]func(obj runtime.Object, values []string) bool{
+			"state": func(obj runtime.Object, values []string) bool {
+				gs := obj.(*v1.GameServer)
+				for _, value := range values {
+					if string(gs.Status.State) == value {
+						return true
+					}
+				}
+				return false
+			},
+		},
+		labels: map[string]string{
+			"fleet": v1.FleetNameLabel,
+		},
+	},
+	RESOURCE_FLEETS: {
+		newObject: func() client.Object { return &v1.Fleet{} },
+		newList:   func() client.ObjectList { return &v1.FleetList{} },
+	},
+}
+
+func New(config *Config, sources func() []Source) (*API, error) {
+	projector := config.Projector
+	if projector == nil {
+		var err error
+		if projector, err = projection.New(projection.DefaultConfig(), config.Scheme); err != nil {
+			return nil, err
+		}
+	}
+
+	api := &API{
+		logger:    log.NewLoggerWithField("source", "query"),
+		resources: map[string]*resource{},
+		projector: projector,
+		scheme:    config.Scheme,
+		sources:   sources,
+	}
+
+	for _, name := range config.Resources {
+		r, ok := resources[name]
+		if !ok {
+			return nil, fmt.Errorf("resource %s is not supported", name)
+		}
+		api.resources[name] = r
+	}
+
+	return api, nil
+}
+
+// ServeHTTP serves the requests with the PREFIX path
+func (a *API) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		a.fail(resp, http.StatusMethodNotAllowed, "only GET requests are supported")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, PREFIX), "/"), "/")
+	r, ok := a.resources[parts[0]]
+	if !ok {
+		a.fail(resp, http.StatusNotFound, fmt.Sprintf("resource %s is not served", parts[0]))
+		return
+	}
+
+	switch len(parts) {
+	case 1:
+		a.list(resp, req, r)
+	case 3:
+		a.get(resp, req, r, types.NamespacedName{Namespace: parts[1], Name: parts[2]})
+	default:
+		a.fail(resp, http.StatusNotFound, "resources are served on /api/v1/<resource> and /api/v1/<resource>/<namespace>/<name>")
+	}
+}
+
+func (a *API) list(resp http.ResponseWriter, req *http.Request, r *resource) {
+	params := req.URL.Query()
+
+	limit := DEFAULT_LIMIT
+	if value := params.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			a.fail(resp, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", value))
+			return
+		}
+	}
+	if limit > MAX_LIMIT {
+		limit = MAX_LIMIT
+	}
+
+	var after *cursor
+	if token := params.Get("continue"); token != "" {
+		var err error
+		if after, err = decodeCursor(token); err != nil {
+			a.fail(resp, http.StatusBadRequest, fmt.Sprintf("invalid continue token: %v", err))
+			return
+		}
+	}
+
+	opts, err := r.listOptions(params)
+	if err != nil {
+		a.fail(resp, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fields, err := a.fields(params)
+	if err != nil {
+		a.fail(resp, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sources, status, err := a.sourcesFor(params.Get("cluster"))
+	if err != nil {
+		a.fail(resp, status, err.Error())
+		return
+	}
+
+	// Objects are not copied out of the cache, only the ones on the page are copied by the projection
+	opts = append(opts, client.UnsafeDisableDeepCopy)
+
+	selected := &page{limit: limit + 1}
+	for _, source := range sources {
+		// Resources are ordered by cluster first, so the clusters before the cursor were listed by previous pages
+		if after != nil && source.Cluster < after.Cluster {
+			continue
+		}
+
+		list := r.newList()
+		if err := source.Reader.List(req.Context(), list, opts...); err != nil {
+			a.fail(resp, http.StatusBadRequest, fmt.Sprintf("error listing resources: %v", err))
+			return
+		}
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			a.fail(resp, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		for _, obj := range items {
+			if !r.matches(obj, params) {
+				continue
+			}
+
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				continue
+			}
+
+			c := cursor{Cluster: source.Cluster, Namespace: accessor.GetNamespace(), Name: accessor.GetName()}
+			if after != nil && !after.less(c) {
+				continue
+			}
+
+			selected.offer(entry{cursor: c, obj: obj})
+		}
+	}
+
+	entries := selected.sorted()
+	result := List{Items: []Item{}}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		result.Continue = entries[limit-1].cursor.encode()
+	}
+
+	for _, e := range entries {
+		item, err := a.item(e.cursor.Cluster, e.obj, fields)
+		if err != nil {
+			a.fail(resp, http.StatusInternalServerError, err.Error())
+			return
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	a.respond(resp, http.StatusOK, result)
+}
+
+func (a *API) get(resp http.ResponseWriter, req *http.Request, r *resource, key types.NamespacedName) {
+	params := req.URL.Query()
+
+	fields, err := a.fields(params)
+	if err != nil {
+		a.fail(resp, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sources, status, err := a.sourcesFor(params.Get("cluster"))
+	if err != nil {
+		a.fail(resp, status, err.Error())
+		return
+	}
+
+	// Resources with the same name may exist on several clusters
+	if len(sources) > 1 {
+		a.fail(resp, http.StatusBadRequest, "the cluster parameter is required when broadcasting from several clusters")
+		return
+	}
+
+	target := r.newObject()
+	if err := sources[0].Reader.Get(req.Context(), key, target); err != nil {
+		if apierrors.IsNotFound(err) {
+			a.fail(resp, http.StatusNotFound, fmt.Sprintf("%s not found", key))
+			return
+		}
+		a.fail(resp, http.StatusBadRequest, fmt.Sprintf("error getting resource: %v", err))
+		return
+	}
+
+	item, err := a.item(sources[0].Cluster, target, fields)
+	if err != nil {
+		a.fail(resp, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	a.respond(resp, http.StatusOK, item)
+}
+
+// sourcesFor returns the synced sources of the cluster. Every synced source is returned if cluster is empty.
+func (a *API) sourcesFor(cluster string) ([]Source, int, error) {
+	var sources []Source
+	for _, source := range a.sources() {
+		if cluster != "" && source.Cluster != cluster {
+			continue
+		}
+
+		if source.Reader == nil {
+			if cluster != "" {
+				return nil, http.StatusServiceUnavailable, fmt.Errorf("caches of cluster %s are not synced", cluster)
+			}
+			continue
+		}
+
+		sources = append(sources, source)
+	}
+
+	if len(sources) > 0 {
+		return sources, http.StatusOK, nil
+	}
+
+	if cluster != "" {
+		return nil, http.StatusNotFound, fmt.Errorf("cluster %s not found", cluster)
+	}
+
+	return nil, http.StatusServiceUnavailable, fmt.Errorf("caches are not synced")
+}
+
+// item applies the projection and the field selection to the resource
+func (a *API) item(cluster string, obj runtime.Object, fields *projection.Projector) (Item, error) {
+	projected, err := a.projector.Project(obj)
+	if err != nil {
+		return Item{}, err
+	}
+
+	if fields != nil {
+		if projected, err = fields.Project(projected); err != nil {
+			return Item{}, err
+		}
+	}
+
+	return Item{Cluster: cluster, Resource: projected}, nil
+}
+
+// fields returns the projector of the fields parameter. It is nil when the parameter is not set.
+func (a *API) fields(params map[string][]string) (*projection.Projector, error) {
+	value := first(params["fields"])
+	if value == "" {
+		return nil, nil
+	}
+
+	projector, err := projection.New(&projection.Config{Include: strings.Split(value, ",")}, a.scheme)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fields: %v", err)
+	}
+
+	return projector, nil
+}
+
+func (a *API) respond(resp http.ResponseWriter, status int, body interface{}) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(status)
+	if err := json.NewEncoder(resp).Encode(body); err != nil {
+		a.logger.WithError(err).Error("error encoding response")
+	}
+}
+
+func (a *API) fail(resp http.ResponseWriter, status int, message string) {
+	a.respond(resp, status, Error{Error: message})
+}
+
+// listOptions returns the options of the namespace, labelSelector and label based parameters, which are applied by the cache
+func (r *resource) listOptions(params map[string][]string) ([]client.ListOption, error) {
+	var opts []client.ListOption
+	if namespace := first(params["namespace"]); namespace != "" {
+		opts = append(opts, client.InNamespace(namespace))
+	}
+
+	selector, err := labels.Parse(first(params["labelSelector"]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid labelSelector: %v", err)
+	}
+
+	for param, label := range r.labels {
+		values := splitValues(params[param])
+		if len(values) == 0 {
+			continue
+		}
+
+		requirement, err := labels.NewRequirement(label, selection.In, values)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", param, err)
+		}
+		selector = selector.Add(*requirement)
+	}
+
+	for param := range params {
+		if _, ok := r.matchers[param]; ok {
+			continue
+		}
+		if _, ok := r.labels[param]; ok {
+			continue
+		}
+		switch param {
+		case "namespace", "labelSelector", "cluster", "limit", "continue", "fields":
+			continue
+		}
+		return nil, fmt.Errorf("unknown parameter %s", param)
+	}
+
+	return append(opts, client.MatchingLabelsSelector{Selector: selector}), nil
+}
+
+// matches returns true if the resource matches the parameters that can't be applied by the cache
+func (r *resource) matches(obj runtime.Object, params map[string][]string) bool {
+	for param, match := range r.matchers {
+		values := splitValues(params[param])
+		if len(values) > 0 && !match(obj, values) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// entry is a resource listed from the cache and its position
+type entry struct {
+	cursor cursor
+	obj    runtime.Object
+}
+
+// page keeps the first limit entries by position. Items are sorted so pages are stable while resources are added and
+// removed, and only the entries of the page are kept and sorted instead of every resource of the cache.
+// The entries are a max-heap, the last entry of the page is at the top so it is replaced by entries before it.
+type page struct {
+	limit   int
+	entries []entry
+}
+
+func (p *page) Len() int           { return len(p.entries) }
+func (p *page) Less(i, j int) bool { return p.entries[j].cursor.less(p.entries[i].cursor) }
+func (p *page) Swap(i, j int)      { p.entries[i], p.entries[j] = p.entries[j], p.entries[i] }
+func (p *page) Push(x interface{}) { p.entries = append(p.entries, x.(entry)) }
+func (p *page) Pop() interface{} {
+	last := p.entries[len(p.entries)-1]
+	p.entries = p.entries[:len(p.entries)-1]
+	return last
+}
+
+// offer adds the entry if the page is not full or it comes before the last entry of the page
+func (p *page) offer(e entry) {
+	if len(p.entries) < p.limit {
+		heap.Push(p, e)
+		return
+	}
+
+	if e.cursor.less(p.entries[0].cursor) {
+		p.entries[0] = e
+		heap.Fix(p, 0)
+	}
+}
+
+// sorted returns the entries of the page by position
+func (p *page) sorted() []entry {
+	sort.Slice(p.entries, func(i, j int) bool {
+		return p.entries[i].cursor.less(p.entries[j].cursor)
+	})
+
+	return p.entries
+}
+
+// cursor is the position of a resource on the sorted list of resources of every cluster
+type cursor struct {
+	Cluster   string `json:"c,omitempty"`
+	Namespace string `json:"ns,omitempty"`
+	Name      string `json:"n"`
+}
+
+func (c cursor) less(other cursor) bool {
+	if c.Cluster != other.Cluster {
+		return c.Cluster < other.Cluster
+	}
+	if c.Namespace != other.Namespace {
+		return c.Namespace < other.Namespace
+	}
+	return c.Name < other.Name
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cursor{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// splitValues returns the comma-separated values of a parameter
+func splitValues(params []string) []string {
+	var values []string
+	for _, param := range params {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
@@ -0,0 +1,266 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/manager"
+)
+
+func newGameServer(namespace, name, fleet string, state v1.GameServerState) *v1.GameServer {
+	return &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{v1.FleetNameLabel: fleet},
+		},
+		Status: v1.GameServerStatus{State: state},
+	}
+}
+
+// response is the decoded body of a response
+type response struct {
+	Items []struct {
+		Cluster  string                 `json:"cluster"`
+		Resource map[string]interface{} `json:"resource"`
+	} `json:"items"`
+	Continue string `json:"continue"`
+	Error    string `json:"error"`
+}
+
+func (r response) names() []string {
+	names := []string{}
+	for _, item := range r.Items {
+		metadata := item.Resource["metadata"].(map[string]interface{})
+		names = append(names, item.Cluster+"/"+metadata["namespace"].(string)+"/"+metadata["name"].(string))
+	}
+	return names
+}
+
+func newReader(objs ...client.Object) client.Reader {
+	return fake.NewClientBuilder().WithScheme(manager.Scheme).WithObjects(objs...).Build()
+}
+
+// failingReader fails every list, it is the cache of a cluster that must not be listed
+type failingReader struct {
+	client.Reader
+}
+
+func (failingReader) List(context.Context, client.ObjectList, ...client.ListOption) error {
+	return errors.New("cluster must not be listed")
+}
+
+func serve(t *testing.T, api *API, path string) (int, response) {
+	resp := httptest.NewRecorder()
+	api.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var body response
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+
+	return resp.Code, body
+}
+
+func Test_API_List(t *testing.T) {
+	sources := []Source{
+		{
+			Cluster: "europe-west1",
+			Reader: newReader(
+				newGameServer("default", "simple-udp-1", "simple-udp", v1.GameServerStateReady),
+				newGameServer("default", "simple-udp-2", "simple-udp", v1.GameServerStateAllocated),
+				newGameServer("ranked", "ranked-1", "ranked", v1.GameServerStateReady),
+			),
+		},
+		{
+			Cluster: "us-central1",
+			Reader: newReader(
+				newGameServer("default", "simple-udp-1", "simple-udp", v1.GameServerStateShutdown),
+			),
+		},
+		{
+			Cluster: "asia-east1",
+		},
+	}
+
+	api, err := New(&Config{Resources: []string{RESOURCE_GAMESERVERS}, Scheme: manager.Scheme}, func() []Source { return sources })
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc       string
+		path       string
+		wantStatus int
+		want       []string
+	}{
+		{
+			desc:       "it should list the resources of every synced cluster",
+			path:       "/api/v1/gameservers",
+			wantStatus: http.StatusOK,
+			want:       []string{"europe-west1/default/simple-udp-1", "europe-west1/default/simple-udp-2", "europe-west1/ranked/ranked-1", "us-central1/default/simple-udp-1"},
+		},
+		{
+			desc:       "it should filter by namespace",
+			path:       "/api/v1/gameservers?namespace=ranked",
+			wantStatus: http.StatusOK,
+			want:       []string{"europe-west1/ranked/ranked-1"},
+		},
+		{
+			desc:       "it should filter by state",
+			path:       "/api/v1/gameservers?state=Allocated,Shutdown",
+			wantStatus: http.StatusOK,
+			want:       []string{"europe-west1/default/simple-udp-2", "us-central1/default/simple-udp-1"},
+		},
+		{
+			desc:       "it should filter by fleet and labels",
+			path:       "/api/v1/gameservers?fleet=simple-udp&labelSelector=agones.dev/fleet!=ranked&cluster=europe-west1",
+			wantStatus: http.StatusOK,
+			want:       []string{"europe-west1/default/simple-udp-1", "europe-west1/default/simple-udp-2"},
+		},
+		{
+			desc:       "it should fail on clusters not synced",
+			path:       "/api/v1/gameservers?cluster=asia-east1",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			desc:       "it should fail on unknown clusters",
+			path:       "/api/v1/gameservers?cluster=unknown",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			desc:       "it should fail on unknown parameters",
+			path:       "/api/v1/gameservers?status=Ready",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			desc:       "it should fail on resources not served",
+			path:       "/api/v1/fleets",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			status, body := serve(t, api, tc.path)
+			require.Equal(t, tc.wantStatus, status, body.Error)
+			if tc.wantStatus == http.StatusOK {
+				require.Equal(t, tc.want, body.names())
+			}
+		})
+	}
+
+	t.Run("it should paginate the resources", func(t *testing.T) {
+		var pages [][]string
+		path := "/api/v1/gameservers?limit=3"
+		for {
+			status, body := serve(t, api, path)
+			require.Equal(t, http.StatusOK, status)
+			pages = append(pages, body.names())
+			if body.Continue == "" {
+				break
+			}
+			path = "/api/v1/gameservers?limit=3&continue=" + body.Continue
+		}
+
+		require.Equal(t, [][]string{
+			{"europe-west1/default/simple-udp-1", "europe-west1/default/simple-udp-2", "europe-west1/ranked/ranked-1"},
+			{"us-central1/default/simple-udp-1"},
+		}, pages)
+	})
+
+	t.Run("it should not list the clusters of previous pages", func(t *testing.T) {
+		api, err := New(&Config{Resources: []string{RESOURCE_GAMESERVERS}, Scheme: manager.Scheme}, func() []Source {
+			return []Source{
+				{Cluster: "europe-west1", Reader: failingReader{}},
+				sources[1],
+			}
+		})
+		require.NoError(t, err)
+
+		token := cursor{Cluster: "us-central1", Namespace: "default", Name: "a"}.encode()
+		status, body := serve(t, api, "/api/v1/gameservers?continue="+token)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []string{"us-central1/default/simple-udp-1"}, body.names())
+	})
+
+	t.Run("it should select fields", func(t *testing.T) {
+		status, body := serve(t, api, "/api/v1/gameservers?namespace=ranked&fields=metadata.name,metadata.namespace,status.state")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, map[string]interface{}{
+			"apiVersion": "agones.dev/v1",
+			"kind":       "GameServer",
+			"metadata":   map[string]interface{}{"name": "ranked-1", "namespace": "ranked"},
+			"status":     map[string]interface{}{"state": "Ready"},
+		}, body.Items[0].Resource)
+	})
+}
+
+func Test_API_Get(t *testing.T) {
+	reader := newReader(newGameServer("default", "simple-udp-1", "simple-udp", v1.GameServerStateReady))
+	sources := []Source{{Cluster: "europe-west1", Reader: reader}}
+
+	api, err := New(&Config{Resources: []string{RESOURCE_GAMESERVERS, RESOURCE_FLEETS}, Scheme: manager.Scheme}, func() []Source { return sources })
+	require.NoError(t, err)
+
+	t.Run("it should get a resource", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		api.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/gameservers/default/simple-udp-1", nil))
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		var item struct {
+			Cluster  string        `json:"cluster"`
+			Resource v1.GameServer `json:"resource"`
+		}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &item))
+		require.Equal(t, "europe-west1", item.Cluster)
+		require.Equal(t, v1.GameServerStateReady, item.Resource.Status.State)
+	})
+
+	t.Run("it should fail on resources not found", func(t *testing.T) {
+		status, _ := serve(t, api, "/api/v1/fleets/default/simple-udp")
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("it should require the cluster when broadcasting from several clusters", func(t *testing.T) {
+		sources = append(sources, Source{Cluster: "us-central1", Reader: newReader()})
+		status, _ := serve(t, api, "/api/v1/gameservers/default/simple-udp-1")
+		require.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = serve(t, api, "/api/v1/gameservers/default/simple-udp-1?cluster=europe-west1")
+		require.Equal(t, http.StatusOK, status)
+	})
+}
+
+func Test_page(t *testing.T) {
+	t.Run("it should keep the first entries sorted by position", func(t *testing.T) {
+		p := &page{limit: 3}
+		for _, i := range []int{7, 2, 9, 0, 5, 1, 8, 3, 6, 4} {
+			p.offer(entry{cursor: cursor{Cluster: "europe-west1", Namespace: "default", Name: fmt.Sprintf("gs-%d", i)}})
+		}
+
+		var names []string
+		for _, e := range p.sorted() {
+			names = append(names, e.cursor.Name)
+		}
+		require.Equal(t, []string{"gs-0", "gs-1", "gs-2"}, names)
+	})
+
+	t.Run("it should keep every entry when the page is not full", func(t *testing.T) {
+		p := &page{limit: 3}
+		p.offer(entry{cursor: cursor{Cluster: "us-central1", Name: "gs-0"}})
+		p.offer(entry{cursor: cursor{Cluster: "europe-west1", Name: "gs-1"}})
+
+		entries := p.sorted()
+		require.Len(t, entries, 2)
+		require.Equal(t, "europe-west1", entries[0].cursor.Cluster)
+		require.Equal(t, "us-central1", entries[1].cursor.Cluster)
+	})
+}
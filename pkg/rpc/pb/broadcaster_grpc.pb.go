@@ -0,0 +1,180 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: broadcaster/v1/broadcaster.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Broadcaster_Subscribe_FullMethodName = "/octops.broadcaster.v1.Broadcaster/Subscribe"
+	Broadcaster_List_FullMethodName      = "/octops.broadcaster.v1.Broadcaster/List"
+)
+
+// BroadcasterClient is the client API for Broadcaster service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BroadcasterClient interface {
+	// Subscribe streams the events matching the filter as they are published.
+	// Clients reconnecting with the stream and sequence of the last event received resume from the next event.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Broadcaster_SubscribeClient, error)
+	// List returns the resources on the cache matching the filter, and the position of the stream they were listed at
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+}
+
+type broadcasterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBroadcasterClient(cc grpc.ClientConnInterface) BroadcasterClient {
+	return &broadcasterClient{cc}
+}
+
+func (c *broadcasterClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Broadcaster_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Broadcaster_ServiceDesc.Streams[0], Broadcaster_Subscribe_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &broadcasterSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Broadcaster_SubscribeClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type broadcasterSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *broadcasterSubscribeClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *broadcasterClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, Broadcaster_List_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BroadcasterServer is the server API for Broadcaster service.
+// All implementations must embed UnimplementedBroadcasterServer
+// for forward compatibility
+type BroadcasterServer interface {
+	// Subscribe streams the events matching the filter as they are published.
+	// Clients reconnecting with the stream and sequence of the last event received resume from the next event.
+	Subscribe(*SubscribeRequest, Broadcaster_SubscribeServer) error
+	// List returns the resources on the cache matching the filter, and the position of the stream they were listed at
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	mustEmbedUnimplementedBroadcasterServer()
+}
+
+// UnimplementedBroadcasterServer must be embedded to have forward compatible implementations.
+type UnimplementedBroadcasterServer struct {
+}
+
+func (UnimplementedBroadcasterServer) Subscribe(*SubscribeRequest, Broadcaster_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedBroadcasterServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedBroadcasterServer) mustEmbedUnimplementedBroadcasterServer() {}
+
+// UnsafeBroadcasterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BroadcasterServer will
+// result in compilation errors.
+type UnsafeBroadcasterServer interface {
+	mustEmbedUnimplementedBroadcasterServer()
+}
+
+func RegisterBroadcasterServer(s grpc.ServiceRegistrar, srv BroadcasterServer) {
+	s.RegisterService(&Broadcaster_ServiceDesc, srv)
+}
+
+func _Broadcaster_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BroadcasterServer).Subscribe(m, &broadcasterSubscribeServer{stream})
+}
+
+type Broadcaster_SubscribeServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type broadcasterSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *broadcasterSubscribeServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Broadcaster_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BroadcasterServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Broadcaster_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BroadcasterServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Broadcaster_ServiceDesc is the grpc.ServiceDesc for Broadcaster service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Broadcaster_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "octops.broadcaster.v1.Broadcaster",
+	HandlerType: (*BroadcasterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "List",
+			Handler:    _Broadcaster_List_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Broadcaster_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "broadcaster/v1/broadcaster.proto",
+}
@@ -0,0 +1,615 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
+	"github.com/Octops/agones-event-broadcaster/pkg/rpc/pb"
+	"github.com/Octops/agones-event-broadcaster/pkg/runtime/log"
+)
+
+const (
+	// DEFAULT_BUFFER_SIZE is the default number of events waiting to be sent to a subscriber before it is dropped
+	DEFAULT_BUFFER_SIZE = 256
+	// DEFAULT_REPLAY_SIZE is the default number of events kept for subscribers resuming the stream
+	DEFAULT_REPLAY_SIZE = 1024
+)
+
+var _ brokers.Broker = (*RPCBroker)(nil)
+var _ pb.BroadcasterServer = (*RPCBroker)(nil)
+
+// Snapshot returns a snapshot event for every resource on the caches, used to answer List requests
+type Snapshot func(ctx context.Context) ([]events.Event, error)
+
+// Config of the gRPC API. Address is where the API is served, e.g. :8090.
+// CertFile and KeyFile are required to serve with TLS. Clients must present a certificate signed by ClientCAFile, if set.
+// Insecure serves the API without TLS when no certificate is set, e.g. behind a service mesh that encrypts the traffic.
+// BufferSize is the number of events waiting to be sent to a subscriber before it is dropped. Defaults to DEFAULT_BUFFER_SIZE.
+// ReplaySize is the number of events kept for subscribers resuming the stream. Defaults to DEFAULT_REPLAY_SIZE.
+type Config struct {
+	Address      string
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	Insecure     bool
+	BufferSize   int
+	ReplaySize   int
+}
+
+// RPCBroker is a broker that streams events to gRPC subscribers and lists the resources on the caches.
+// Every event published gets the next sequence of the stream. The stream is identified by a random id generated when
+// the server is created, so subscribers can tell when sequences restarted.
+type RPCBroker struct {
+	pb.UnimplementedBroadcasterServer
+
+	logger   *logrus.Entry
+	config   *Config
+	scheme   *runtime.Scheme
+	snapshot Snapshot
+	stream   string
+	mutex    sync.RWMutex
+	sequence uint64
+	replay   []*entry
+	clients  map[*subscriber]bool
+}
+
+// entry is an event of the stream and the fields used to filter it
+type entry struct {
+	event     *pb.Event
+	namespace string
+	labels    labels.Set
+}
+
+// subscriber is a connected client. dropped is closed when the client doesn't keep up with the stream.
+type subscriber struct {
+	filter  *filter
+	events  chan *pb.Event
+	dropped chan struct{}
+}
+
+// filter is the compiled version of a pb.Filter
+type filter struct {
+	kinds      []string
+	namespaces []string
+	eventTypes []string
+	clusters   []string
+	selector   labels.Selector
+}
+
+func New(config *Config, scheme *runtime.Scheme, snapshot Snapshot) *RPCBroker {
+	if config.BufferSize <= 0 {
+		config.BufferSize = DEFAULT_BUFFER_SIZE
+	}
+
+	if config.ReplaySize <= 0 {
+		config.ReplaySize = DEFAULT_REPLAY_SIZE
+	}
+
+	return &RPCBroker{
+		logger:   log.NewLoggerWithField("source", "rpc"),
+		config:   config,
+		scheme:   scheme,
+		snapshot: snapshot,
+		stream:   string(uuid.NewUUID()),
+		clients:  map[*subscriber]bool{},
+	}
+}
+
+// Start serves the gRPC API on the configured address until ctx is cancelled
+func (s *RPCBroker) Start(ctx context.Context) error {
+	opts, err := s.serverOptions()
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %v", s.config.Address, err)
+	}
+
+	srv := grpc.NewServer(opts...)
+	pb.RegisterBroadcasterServer(srv, s)
+
+	go func() {
+		<-ctx.Done()
+		// Subscriptions never end on their own, so waiting for them would block the shutdown
+		srv.Stop()
+	}()
+
+	s.logger.Infof("serving gRPC API on %s", listener.Addr())
+	return srv.Serve(listener)
+}
+
+// serverOptions returns the TLS credentials of the server. Client certificates are verified if ClientCAFile is set.
+// It fails without a certificate unless Insecure is set, so the API is never served in plaintext by mistake.
+func (s *RPCBroker) serverOptions() ([]grpc.ServerOption, error) {
+	if s.config.CertFile == "" && s.config.KeyFile == "" {
+		if s.config.ClientCAFile != "" {
+			return nil, fmt.Errorf("client certificates can't be verified without a server certificate and key")
+		}
+		if !s.config.Insecure {
+			return nil, fmt.Errorf("a server certificate and key are required to serve with TLS, the API can only be served without TLS if insecure is set")
+		}
+
+		s.logger.Warn("serving gRPC API without TLS")
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.config.CertFile, s.config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading server certificate: %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if s.config.ClientCAFile != "" {
+		ca, err := os.ReadFile(s.config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading client CA: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found on client CA %s", s.config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsConfig))}, nil
+}
+
+// BuildEnvelope converts the event to its protobuf message. The sequence is assigned when the message is sent.
+func (s *RPCBroker) BuildEnvelope(event events.Event) (*events.Envelope, error) {
+	e, err := s.newEntry(event)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := &events.Envelope{Message: e}
+	envelope.AddHeader(events.EVENT_ID_HEADER_KEY, e.event.GetMetadata().GetId())
+
+	return envelope, nil
+}
+
+// SendMessage assigns the next sequence to the event, keeps it for subscribers resuming the stream and sends it to the
+// subscribers which filters match it. Subscribers which buffer is full are dropped.
+func (s *RPCBroker) SendMessage(envelope *events.Envelope) error {
+	e, ok := envelope.Message.(*entry)
+	if !ok {
+		return fmt.Errorf("envelope was not built by the RPCBroker")
+	}
+
+	var slow []*subscriber
+	s.mutex.Lock()
+	s.sequence++
+	e.event.Stream = s.stream
+	e.event.Sequence = s.sequence
+
+	s.replay = append(s.replay, e)
+	if len(s.replay) > s.config.ReplaySize {
+		s.replay = s.replay[len(s.replay)-s.config.ReplaySize:]
+	}
+
+	for c := range s.clients {
+		if !c.filter.match(e) {
+			continue
+		}
+
+		select {
+		case c.events <- e.event:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, c := range slow {
+		s.drop(c)
+	}
+
+	return nil
+}
+
+// Subscribe streams the events matching the filter of the request. Subscribers resuming the stream first receive the
+// buffered events published after the sequence of the request. It fails with OutOfRange if those events are not
+// buffered anymore, or the stream of the request is not the current one, so the subscriber can List again.
+func (s *RPCBroker) Subscribe(req *pb.SubscribeRequest, srv pb.Broadcaster_SubscribeServer) error {
+	f, err := newFilter(req.GetFilter())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	c := &subscriber{
+		filter:  f,
+		events:  make(chan *pb.Event, s.config.BufferSize),
+		dropped: make(chan struct{}),
+	}
+
+	// The backlog is taken while the subscriber is added so no event is missed or sent twice
+	s.mutex.Lock()
+	backlog, err := s.backlog(req)
+	if err != nil {
+		s.mutex.Unlock()
+		return err
+	}
+	s.clients[c] = true
+	s.mutex.Unlock()
+	metrics.RPCSubscribers.Inc()
+	defer s.disconnect(c)
+
+	for _, e := range backlog {
+		if !f.match(e) {
+			continue
+		}
+		if err := srv.Send(e.event); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-srv.Context().Done():
+			return nil
+		case <-c.dropped:
+			return status.Error(codes.ResourceExhausted, "the subscriber didn't keep up with the stream")
+		case event := <-c.events:
+			if err := srv.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// List returns the resources on the caches matching the filter of the request. Event types are ignored.
+func (s *RPCBroker) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	f, err := newFilter(req.GetFilter())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	f.eventTypes = nil
+
+	// The position is taken before listing, so subscribing after it never misses a change
+	s.mutex.RLock()
+	resp := &pb.ListResponse{Stream: s.stream, Sequence: s.sequence}
+	s.mutex.RUnlock()
+
+	if s.snapshot == nil {
+		return resp, nil
+	}
+
+	snapshot, err := s.snapshot(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "error listing resources: %v", err)
+	}
+
+	for _, event := range snapshot {
+		e, err := s.newEntry(event)
+		if err != nil {
+			s.logger.WithError(err).Warn("error converting snapshot event")
+			continue
+		}
+
+		if e.event.Object == nil || !f.match(e) {
+			continue
+		}
+
+		resource := &pb.Resource{
+			Cluster: e.event.GetMetadata().GetClusterName(),
+			Kind:    e.event.Kind,
+			Object:  e.event.Object,
+		}
+		switch status := e.event.Status.(type) {
+		case *pb.Event_GameServerStatus:
+			resource.Status = &pb.Resource_GameServerStatus{GameServerStatus: status.GameServerStatus}
+		case *pb.Event_FleetStatus:
+			resource.Status = &pb.Resource_FleetStatus{FleetStatus: status.FleetStatus}
+		}
+
+		resp.Resources = append(resp.Resources, resource)
+	}
+
+	return resp, nil
+}
+
+// Subscribers returns the number of connected subscribers
+func (s *RPCBroker) Subscribers() int {
+	defer s.mutex.RUnlock()
+	s.mutex.RLock()
+
+	return len(s.clients)
+}
+
+// backlog returns the buffered events published after the sequence of the request. It must be called holding the lock.
+func (s *RPCBroker) backlog(req *pb.SubscribeRequest) ([]*entry, error) {
+	after := req.GetAfterSequence()
+	if after == 0 {
+		return nil, nil
+	}
+
+	if req.GetStream() != s.stream {
+		return nil, status.Errorf(codes.OutOfRange, "stream %q is not the current stream %q", req.GetStream(), s.stream)
+	}
+
+	if after > s.sequence {
+		return nil, status.Errorf(codes.OutOfRange, "sequence %d has not been published, the last sequence is %d", after, s.sequence)
+	}
+
+	oldest := s.sequence - uint64(len(s.replay)) + 1
+	if after+1 < oldest {
+		return nil, status.Errorf(codes.OutOfRange, "events after sequence %d are not buffered anymore, the oldest sequence is %d", after, oldest)
+	}
+
+	return append([]*entry{}, s.replay[after+1-oldest:]...), nil
+}
+
+// drop disconnects a subscriber that didn't keep up with the stream
+func (s *RPCBroker) drop(c *subscriber) {
+	if s.disconnect(c) {
+		close(c.dropped)
+		metrics.RPCSubscribersDropped.Inc()
+		s.logger.Warn("subscriber dropped for not keeping up with the stream")
+	}
+}
+
+// disconnect removes the subscriber. It returns false if the subscriber was already disconnected.
+func (s *RPCBroker) disconnect(c *subscriber) bool {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	if !s.clients[c] {
+		return false
+	}
+
+	delete(s.clients, c)
+	metrics.RPCSubscribers.Dec()
+
+	return true
+}
+
+// newEntry converts the event to its protobuf message. Resources are set as Object, and OldObject for updates.
+// The content of events without a resource as content, like the ones rendered from templates, is set as Body.
+func (s *RPCBroker) newEntry(event events.Event) (*entry, error) {
+	e := &entry{
+		event: &pb.Event{
+			Source: event.EventSource().String(),
+			Type:   event.EventType().String(),
+		},
+	}
+
+	m, ok := event.(events.Message)
+	if !ok {
+		return e, nil
+	}
+	e.event.Metadata = toMetadata(events.MetadataOf(m))
+
+	if obj, ok := events.ResourceObject(m); ok {
+		if gvk, err := apiutil.GVKForObject(obj, s.scheme); err == nil {
+			e.event.Kind = gvk.Kind
+		}
+		if accessor, err := meta.Accessor(obj); err == nil {
+			e.namespace = accessor.GetNamespace()
+			e.labels = accessor.GetLabels()
+		}
+
+		switch status := toStatus(obj).(type) {
+		case *pb.GameServerStatus:
+			e.event.Status = &pb.Event_GameServerStatus{GameServerStatus: status}
+		case *pb.FleetStatus:
+			e.event.Status = &pb.Event_FleetStatus{FleetStatus: status}
+		}
+	}
+
+	var err error
+	switch content := m.Content().(type) {
+	case *events.UpdatedMessage:
+		if e.event.Object, err = toStruct(content.New); err != nil {
+			return nil, err
+		}
+		if e.event.OldObject, err = toStruct(content.Old); err != nil {
+			return nil, err
+		}
+	case runtime.Object:
+		if e.event.Object, err = toStruct(content); err != nil {
+			return nil, err
+		}
+	default:
+		if e.event.Body, err = toValue(content); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+func toMetadata(metadata *events.Metadata) *pb.Metadata {
+	if metadata == nil {
+		return nil
+	}
+
+	m := &pb.Metadata{
+		Id:                metadata.ID,
+		ObservedAt:        timestamppb.New(metadata.ObservedAt),
+		ClusterName:       metadata.ClusterName,
+		Instance:          metadata.Instance,
+		ResourceUid:       metadata.ResourceUID,
+		ResourceVersion:   metadata.ResourceVersion,
+		ResourceSequence:  metadata.Sequence,
+		FinalStateUnknown: metadata.FinalStateUnknown,
+	}
+	if metadata.DeletionTimestamp != nil {
+		m.DeletionTimestamp = timestamppb.New(*metadata.DeletionTimestamp)
+	}
+
+	return m
+}
+
+// toStatus returns the typed status of GameServers and Fleets, typed or unstructured. It returns nil for other resources.
+func toStatus(obj runtime.Object) interface{} {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		gvk := u.GroupVersionKind()
+		if gvk.Group != v1.SchemeGroupVersion.Group {
+			return nil
+		}
+
+		switch gvk.Kind {
+		case "GameServer":
+			obj = &v1.GameServer{}
+		case "Fleet":
+			obj = &v1.Fleet{}
+		default:
+			return nil
+		}
+
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj); err != nil {
+			return nil
+		}
+	}
+
+	switch o := obj.(type) {
+	case *v1.GameServer:
+		status := &pb.GameServerStatus{
+			State:    string(o.Status.State),
+			Address:  o.Status.Address,
+			NodeName: o.Status.NodeName,
+		}
+		for _, address := range o.Status.Addresses {
+			status.Addresses = append(status.Addresses, &pb.NodeAddress{Type: string(address.Type), Address: address.Address})
+		}
+		for _, port := range o.Status.Ports {
+			status.Ports = append(status.Ports, &pb.GameServerStatusPort{Name: port.Name, Port: port.Port})
+		}
+		if o.Status.ReservedUntil != nil {
+			status.ReservedUntil = timestamppb.New(o.Status.ReservedUntil.Time)
+		}
+
+		return status
+	case *v1.Fleet:
+		return &pb.FleetStatus{
+			Replicas:          o.Status.Replicas,
+			ReadyReplicas:     o.Status.ReadyReplicas,
+			ReservedReplicas:  o.Status.ReservedReplicas,
+			AllocatedReplicas: o.Status.AllocatedReplicas,
+		}
+	}
+
+	return nil
+}
+
+// toStruct converts the JSON representation of the resource to a Struct
+func toStruct(obj runtime.Object) (*structpb.Struct, error) {
+	if obj == nil {
+		return nil, nil
+	}
+
+	value, err := toValue(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return value.GetStructValue(), nil
+}
+
+// toValue converts the JSON representation of the content to a Value
+func toValue(content interface{}) (*structpb.Value, error) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding content: %v", err)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("error decoding content: %v", err)
+	}
+
+	value, err := structpb.NewValue(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("error converting content: %v", err)
+	}
+
+	return value, nil
+}
+
+func newFilter(f *pb.Filter) (*filter, error) {
+	selector, err := labels.Parse(f.GetLabelSelector())
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector: %v", err)
+	}
+
+	return &filter{
+		kinds:      f.GetKinds(),
+		namespaces: f.GetNamespaces(),
+		eventTypes: f.GetEventTypes(),
+		clusters:   f.GetClusters(),
+		selector:   selector,
+	}, nil
+}
+
+// match returns true if the entry matches every field of the filter
+func (f *filter) match(e *entry) bool {
+	if len(f.kinds) > 0 && !containsFold(f.kinds, e.event.Kind) {
+		return false
+	}
+
+	if len(f.namespaces) > 0 && !contains(f.namespaces, e.namespace) {
+		return false
+	}
+
+	if len(f.eventTypes) > 0 && !contains(f.eventTypes, e.event.Type) {
+		return false
+	}
+
+	if len(f.clusters) > 0 && !contains(f.clusters, e.event.GetMetadata().GetClusterName()) {
+		return false
+	}
+
+	return f.selector.Empty() || f.selector.Matches(e.labels)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,377 @@
+package rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/rpc/pb"
+)
+
+// scheme knows the Agones types, the same way the scheme of the manager does
+var scheme = func() *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := v1.AddToScheme(s); err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+func newGameServer(namespace, name string, labels map[string]string) *v1.GameServer {
+	return &v1.GameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+	}
+}
+
+func newEvent(namespace, name string, labels map[string]string) events.Event {
+	return events.OnAdded(&events.AddedMessage{
+		Obj:  newGameServer(namespace, name, labels),
+		Meta: &events.Metadata{ID: name, ClusterName: "us-central1", ObservedAt: time.Now()},
+	})
+}
+
+func send(t *testing.T, s *RPCBroker, event events.Event) {
+	envelope, err := s.BuildEnvelope(event)
+	require.NoError(t, err)
+	require.NoError(t, s.SendMessage(envelope))
+}
+
+// newClient serves the broker on an in-memory listener and returns a client connected to it
+func newClient(t *testing.T, s *RPCBroker) pb.BroadcasterClient {
+	listener := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterBroadcasterServer(srv, s)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewBroadcasterClient(conn)
+}
+
+func subscribe(t *testing.T, client pb.BroadcasterClient, req *pb.SubscribeRequest) pb.Broadcaster_SubscribeClient {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	sub, err := client.Subscribe(ctx, req)
+	require.NoError(t, err)
+
+	return sub
+}
+
+// waitForSubscribers waits until the number of subscribers connected to the broker is n
+func waitForSubscribers(t *testing.T, s *RPCBroker, n int) {
+	require.Eventually(t, func() bool { return s.Subscribers() == n }, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_RPCBroker_Subscribe(t *testing.T) {
+	s := New(&Config{ReplaySize: 1}, scheme, nil)
+	client := newClient(t, s)
+
+	t.Run("it should stream the events matching the filter", func(t *testing.T) {
+		sub := subscribe(t, client, &pb.SubscribeRequest{
+			Filter: &pb.Filter{Kinds: []string{"gameserver"}, LabelSelector: "mode=ranked"},
+		})
+		waitForSubscribers(t, s, 1)
+
+		send(t, s, newEvent("default", "casual", map[string]string{"mode": "casual"}))
+		send(t, s, newEvent("default", "ranked", map[string]string{"mode": "ranked"}))
+
+		event, err := sub.Recv()
+		require.NoError(t, err)
+		require.Equal(t, s.stream, event.Stream)
+		require.Equal(t, uint64(2), event.Sequence)
+		require.Equal(t, "OnAdd", event.Source)
+		require.Equal(t, "gameserver.events.added", event.Type)
+		require.Equal(t, "GameServer", event.Kind)
+		require.Equal(t, "ranked", event.Metadata.Id)
+		require.Equal(t, "us-central1", event.Metadata.ClusterName)
+		require.Equal(t, "ranked", event.Object.AsMap()["metadata"].(map[string]interface{})["name"])
+	})
+
+	t.Run("it should resume the stream after the sequence of the request", func(t *testing.T) {
+		send(t, s, newEvent("default", "resumed", nil))
+
+		sub := subscribe(t, client, &pb.SubscribeRequest{Stream: s.stream, AfterSequence: 2})
+		event, err := sub.Recv()
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), event.Sequence)
+		require.Equal(t, "resumed", event.Metadata.Id)
+	})
+
+	testCases := []struct {
+		desc string
+		req  *pb.SubscribeRequest
+		code codes.Code
+	}{
+		{
+			desc: "it should fail if the events are not buffered anymore",
+			req:  &pb.SubscribeRequest{Stream: s.stream, AfterSequence: 1},
+			code: codes.OutOfRange,
+		},
+		{
+			desc: "it should fail if the stream is not the current one",
+			req:  &pb.SubscribeRequest{Stream: "previous", AfterSequence: 2},
+			code: codes.OutOfRange,
+		},
+		{
+			desc: "it should fail if the sequence has not been published",
+			req:  &pb.SubscribeRequest{Stream: s.stream, AfterSequence: 10},
+			code: codes.OutOfRange,
+		},
+		{
+			desc: "it should fail if the label selector is invalid",
+			req:  &pb.SubscribeRequest{Filter: &pb.Filter{LabelSelector: "mode in ranked"}},
+			code: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := subscribe(t, client, tc.req).Recv()
+			require.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
+
+func Test_RPCBroker_Drop(t *testing.T) {
+	s := New(&Config{BufferSize: 1}, scheme, nil)
+	client := newClient(t, s)
+
+	sub := subscribe(t, client, &pb.SubscribeRequest{})
+	waitForSubscribers(t, s, 1)
+
+	// The client doesn't read while events are sent, so the buffer fills up at some point
+	for i := 0; i < 100000 && s.Subscribers() > 0; i++ {
+		send(t, s, newEvent("default", "simple-udp", nil))
+	}
+	require.Equal(t, 0, s.Subscribers())
+
+	var err error
+	for err == nil {
+		_, err = sub.Recv()
+	}
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func Test_RPCBroker_List(t *testing.T) {
+	s := New(&Config{}, scheme, func(ctx context.Context) ([]events.Event, error) {
+		return []events.Event{
+			events.OnSnapshot(&events.AddedMessage{Obj: newGameServer("default", "simple-udp", nil), Meta: &events.Metadata{ClusterName: "us-central1"}}),
+			events.OnSnapshot(&events.AddedMessage{Obj: newGameServer("ranked", "filtered", nil), Meta: &events.Metadata{ClusterName: "us-central1"}}),
+		}, nil
+	})
+	client := newClient(t, s)
+	send(t, s, newEvent("default", "simple-udp", nil))
+
+	resp, err := client.List(context.Background(), &pb.ListRequest{
+		Filter: &pb.Filter{Namespaces: []string{"default"}, EventTypes: []string{"gameserver.events.added"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, s.stream, resp.Stream)
+	require.Equal(t, uint64(1), resp.Sequence)
+	require.Len(t, resp.Resources, 1)
+	require.Equal(t, "us-central1", resp.Resources[0].Cluster)
+	require.Equal(t, "GameServer", resp.Resources[0].Kind)
+	require.Equal(t, "simple-udp", resp.Resources[0].Object.AsMap()["metadata"].(map[string]interface{})["name"])
+	require.NotNil(t, resp.Resources[0].GetGameServerStatus(), "it should set the typed status of the resource")
+}
+
+func Test_RPCBroker_newEntry_Status(t *testing.T) {
+	reservedUntil := metav1.NewTime(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	gs := newGameServer("default", "simple-udp", nil)
+	gs.Status = v1.GameServerStatus{
+		State:         v1.GameServerStateReady,
+		Address:       "172.17.0.2",
+		Addresses:     []corev1.NodeAddress{{Type: corev1.NodeExternalIP, Address: "172.17.0.2"}},
+		Ports:         []v1.GameServerStatusPort{{Name: "default", Port: 7100}},
+		NodeName:      "node-1",
+		ReservedUntil: &reservedUntil,
+	}
+	wantGameServer := &pb.GameServerStatus{
+		State:         "Ready",
+		Address:       "172.17.0.2",
+		Addresses:     []*pb.NodeAddress{{Type: "ExternalIP", Address: "172.17.0.2"}},
+		Ports:         []*pb.GameServerStatusPort{{Name: "default", Port: 7100}},
+		NodeName:      "node-1",
+		ReservedUntil: timestamppb.New(reservedUntil.Time),
+	}
+
+	unstructuredGS, err := runtime.DefaultUnstructuredConverter.ToUnstructured(gs)
+	require.NoError(t, err)
+	u := &unstructured.Unstructured{Object: unstructuredGS}
+	u.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("GameServer"))
+
+	fleet := &v1.Fleet{
+		ObjectMeta: metav1.ObjectMeta{Name: "fleet", Namespace: "default"},
+		Status:     v1.FleetStatus{Replicas: 3, ReadyReplicas: 1, ReservedReplicas: 1, AllocatedReplicas: 1},
+	}
+
+	testCases := []struct {
+		desc           string
+		event          events.Event
+		wantGameServer *pb.GameServerStatus
+		wantFleet      *pb.FleetStatus
+	}{
+		{
+			desc:           "it should set the status of GameServers",
+			event:          events.GameServerAdded(&events.EventMessage{Body: gs}),
+			wantGameServer: wantGameServer,
+		},
+		{
+			desc:           "it should set the status of the new version of updated GameServers",
+			event:          events.GameServerUpdated(&events.EventMessage{Body: &events.UpdatedMessage{Old: newGameServer("default", "simple-udp", nil), New: gs}}),
+			wantGameServer: wantGameServer,
+		},
+		{
+			desc:           "it should set the status of unstructured GameServers",
+			event:          events.GameServerAdded(&events.EventMessage{Body: u}),
+			wantGameServer: wantGameServer,
+		},
+		{
+			desc:      "it should set the status of Fleets",
+			event:     events.FleetAdded(&events.EventMessage{Body: fleet}),
+			wantFleet: &pb.FleetStatus{Replicas: 3, ReadyReplicas: 1, ReservedReplicas: 1, AllocatedReplicas: 1},
+		},
+		{
+			desc:  "it should not set the status of other resources",
+			event: events.GameServerAdded(&events.EventMessage{Body: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			e, err := New(&Config{}, scheme, nil).newEntry(tc.event)
+			require.NoError(t, err)
+			require.True(t, proto.Equal(tc.wantGameServer, e.event.GetGameServerStatus()), "got %v", e.event.GetGameServerStatus())
+			require.True(t, proto.Equal(tc.wantFleet, e.event.GetFleetStatus()), "got %v", e.event.GetFleetStatus())
+		})
+	}
+}
+
+func Test_RPCBroker_serverOptions(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		config  *Config
+		wantErr bool
+	}{
+		{
+			desc:    "it should fail without a certificate by default",
+			config:  &Config{},
+			wantErr: true,
+		},
+		{
+			desc:   "it should serve without TLS when insecure",
+			config: &Config{Insecure: true},
+		},
+		{
+			desc:    "it should fail to verify clients without a server certificate",
+			config:  &Config{ClientCAFile: "ca.crt"},
+			wantErr: true,
+		},
+		{
+			desc:    "it should fail if the certificate can't be loaded",
+			config:  &Config{CertFile: "missing.crt", KeyFile: "missing.key"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts, err := New(tc.config, scheme, nil).serverOptions()
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Empty(t, opts)
+		})
+	}
+
+	t.Run("it should serve with TLS using the certificate", func(t *testing.T) {
+		certFile, keyFile := newCertificate(t)
+		s := New(&Config{CertFile: certFile, KeyFile: keyFile}, scheme, nil)
+		opts, err := s.serverOptions()
+		require.NoError(t, err)
+		require.Len(t, opts, 1)
+
+		listener := bufconn.Listen(1 << 20)
+		srv := grpc.NewServer(opts...)
+		pb.RegisterBroadcasterServer(srv, s)
+		go srv.Serve(listener)
+		t.Cleanup(srv.Stop)
+
+		creds, err := credentials.NewClientTLSFromFile(certFile, "localhost")
+		require.NoError(t, err)
+		conn, err := grpc.Dial("bufnet",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+			grpc.WithTransportCredentials(creds),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		_, err = pb.NewBroadcasterClient(conn).List(context.Background(), &pb.ListRequest{})
+		require.NoError(t, err)
+	})
+}
+
+// newCertificate writes a self-signed certificate for localhost and its key, and returns their files
+func newCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+
+	return certFile, keyFile
+}
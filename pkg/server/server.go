@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/runtime/log"
+)
+
+const (
+	// DEFAULT_CHECK_TIMEOUT is the default maximum amount of time a readiness check can take
+	DEFAULT_CHECK_TIMEOUT = 5 * time.Second
+	// SHUTDOWN_TIMEOUT is the maximum amount of time waited for requests in progress when the server stops
+	SHUTDOWN_TIMEOUT = 5 * time.Second
+)
+
+// Check reports an error when the broadcaster is not ready
+type Check func(ctx context.Context) error
+
+// Config of the server. Address is the TCP address the server listens on, e.g. ":8089".
+// CheckTimeout is the maximum amount of time a readiness check can take. Defaults to DEFAULT_CHECK_TIMEOUT.
+// Profiling serves the Go profiles on /debug/pprof. Profiles are not authenticated and expose the command line of the process.
+type Config struct {
+	Address      string
+	CheckTimeout time.Duration
+	Profiling    bool
+}
+
+// Server serves the health, readiness and debug endpoints of the broadcaster:
+// /healthz responds ok while the process is running.
+// /readyz responds ok when every readiness check passes. /readyz/<name> runs a single check.
+// /debug responds with the state returned by the debug function encoded as JSON. /debug/pprof serves the Go profiles when Profiling is set.
+type Server struct {
+	logger *logrus.Entry
+	config *Config
+	mutex  sync.RWMutex
+	checks map[string]Check
+	debug  func() interface{}
+	mux    *http.ServeMux
+}
+
+func New(config *Config, debug func() interface{}) *Server {
+	if config.CheckTimeout <= 0 {
+		config.CheckTimeout = DEFAULT_CHECK_TIMEOUT
+	}
+
+	s := &Server{
+		logger: log.NewLoggerWithField("source", "server"),
+		config: config,
+		checks: map[string]Check{},
+		debug:  debug,
+		mux:    http.NewServeMux(),
+	}
+
+	mux := s.mux
+	handle(mux, "/healthz", &healthz.Handler{})
+	handle(mux, "/readyz", http.HandlerFunc(s.serveReadyz))
+	mux.HandleFunc("/debug", s.serveDebug)
+	if config.Profiling {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	return s
+}
+
+// AddReadyCheck adds a check that must pass for the broadcaster to be ready. Checks with the same name are replaced.
+func (s *Server) AddReadyCheck(name string, check Check) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	s.checks[name] = check
+}
+
+// Handle serves the handler on pattern, in addition to the health, readiness and debug endpoints
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Handler returns the handler serving the endpoints of the server
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start serves the endpoints until ctx is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %v", s.config.Address, err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		// Requests are cancelled when the server stops, so streaming responses don't delay the shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			s.logger.WithError(err).Error("error stopping server")
+		}
+	}()
+
+	s.logger.Infof("serving on %s", listener.Addr())
+	if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// serveReadyz runs the readiness checks using the healthz handler of controller-runtime,
+// so the response format and the exclude and verbose parameters are the same as the ones of Kubernetes components
+func (s *Server) serveReadyz(resp http.ResponseWriter, req *http.Request) {
+	s.mutex.RLock()
+	checks := make(map[string]healthz.Checker, len(s.checks))
+	for name, check := range s.checks {
+		checks[name] = s.checker(name, check)
+	}
+	s.mutex.RUnlock()
+
+	(&healthz.Handler{Checks: checks}).ServeHTTP(resp, req)
+}
+
+// checker runs the check with a timeout. The reason of a failure is logged since it is withheld from the response.
+func (s *Server) checker(name string, check Check) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), s.config.CheckTimeout)
+		defer cancel()
+
+		if err := check(ctx); err != nil {
+			s.logger.WithError(err).WithField("check", name).Warn("readiness check failed")
+			return err
+		}
+
+		return nil
+	}
+}
+
+func (s *Server) serveDebug(resp http.ResponseWriter, req *http.Request) {
+	var state interface{}
+	if s.debug != nil {
+		state = s.debug()
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(resp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(state); err != nil {
+		s.logger.WithError(err).Error("error encoding debug state")
+	}
+}
+
+// handle serves the handler on path and its sub paths, e.g. /readyz/<name>
+func handle(mux *http.ServeMux, path string, handler http.Handler) {
+	mux.Handle(path, http.StripPrefix(path, handler))
+	mux.Handle(path+"/", http.StripPrefix(path, handler))
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Server(t *testing.T) {
+	ready := false
+	s := New(&Config{}, func() interface{} {
+		return map[string]string{"instance": "broadcaster-0"}
+	})
+	s.AddReadyCheck("cache-sync", func(ctx context.Context) error {
+		if !ready {
+			return errors.New("caches are not synced")
+		}
+		return nil
+	})
+	s.AddReadyCheck("broker-pubsub", func(ctx context.Context) error {
+		return nil
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		s.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		return resp
+	}
+
+	testCases := []struct {
+		desc       string
+		path       string
+		ready      bool
+		wantStatus int
+	}{
+		{
+			desc:       "it should be healthy before being ready",
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "it should not be ready while a check fails",
+			path:       "/readyz",
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			desc:       "it should run a single check",
+			path:       "/readyz/broker-pubsub",
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "it should exclude checks",
+			path:       "/readyz?exclude=cache-sync",
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "it should be ready once every check passes",
+			path:       "/readyz",
+			ready:      true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "it should fail on unknown checks",
+			path:       "/readyz/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ready = tc.ready
+			require.Equal(t, tc.wantStatus, get(tc.path).Code)
+		})
+	}
+
+	t.Run("it should serve the debug state as JSON", func(t *testing.T) {
+		resp := get("/debug")
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+
+		var state map[string]string
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &state))
+		require.Equal(t, "broadcaster-0", state["instance"])
+	})
+
+	t.Run("it should not serve the Go profiles unless profiling is enabled", func(t *testing.T) {
+		require.Equal(t, http.StatusNotFound, get("/debug/pprof/").Code)
+	})
+}
+
+func Test_Server_Profiling(t *testing.T) {
+	s := New(&Config{Profiling: true}, nil)
+
+	resp := httptest.NewRecorder()
+	s.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+}
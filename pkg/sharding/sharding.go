@@ -0,0 +1,383 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
+	"github.com/Octops/agones-event-broadcaster/pkg/runtime/log"
+)
+
+// Strategy decides the key used to assign a resource to a replica
+type Strategy string
+
+const (
+	// STRATEGY_NAME assigns resources by namespace and name, spreading resources evenly across replicas
+	STRATEGY_NAME Strategy = "name"
+	// STRATEGY_NAMESPACE assigns every resource of a namespace to the same replica
+	STRATEGY_NAMESPACE Strategy = "namespace"
+)
+
+const (
+	// GROUP_LABEL is set on the Lease of every member with the name of the group
+	GROUP_LABEL            = "octops.io/sharding-group"
+	DEFAULT_LEASE_DURATION = 15 * time.Second
+	DEFAULT_RENEW_PERIOD   = 5 * time.Second
+)
+
+// serviceAccountNamespace holds the namespace of pods running in the cluster
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// ErrNotReady is returned when the replica doesn't know its members or could not renew its Lease in time.
+// Ownership can't be decided without risking events being published by more than one replica.
+var ErrNotReady = fmt.Errorf("sharding members are not in sync")
+
+// Config of the sharding.
+// Replicas of the same Group share the resources. Each replica holds a Lease named <Group>-<Identity> on Namespace.
+// Namespace defaults to the namespace the broadcaster is running on. Strategy defaults to STRATEGY_NAME.
+// Members that don't renew their Lease for LeaseDuration are removed and their resources are assigned to the remaining members.
+// Leases that expired for longer than LeaseDuration are deleted, e.g. the Leases of replicas that were killed.
+// ClusterName labels the metrics of the sharder.
+// OnChange is called, if set, with the previous members every time the members of the group change after the first sync.
+type Config struct {
+	Group         string
+	Identity      string
+	Namespace     string
+	Strategy      Strategy
+	LeaseDuration time.Duration
+	RenewPeriod   time.Duration
+	ClusterName   string
+	OnChange      func(previous []string)
+}
+
+// Sharder assigns resources to the live members of a group using rendezvous hashing.
+// Only the resources of a replica that leaves the group are moved to other replicas.
+type Sharder struct {
+	logger    *logrus.Entry
+	config    Config
+	client    client.Client
+	reader    client.Reader
+	mutex     sync.RWMutex
+	members   []string
+	lastRenew time.Time
+	synced    chan struct{}
+	now       func() time.Time
+}
+
+// New returns a Sharder. Leases are written using c and read using reader, which should not be backed by a cache.
+func New(config *Config, c client.Client, reader client.Reader) (*Sharder, error) {
+	conf := *config
+	if conf.Group == "" || conf.Identity == "" {
+		return nil, fmt.Errorf("sharding group and identity are required")
+	}
+
+	if conf.Namespace == "" {
+		namespace, err := os.ReadFile(serviceAccountNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("sharding namespace is required when running out of the cluster: %v", err)
+		}
+		conf.Namespace = strings.TrimSpace(string(namespace))
+	}
+
+	switch conf.Strategy {
+	case "":
+		conf.Strategy = STRATEGY_NAME
+	case STRATEGY_NAME, STRATEGY_NAMESPACE:
+	default:
+		return nil, fmt.Errorf("invalid sharding strategy %q, valid strategies are %s and %s", conf.Strategy, STRATEGY_NAME, STRATEGY_NAMESPACE)
+	}
+
+	if conf.LeaseDuration <= 0 {
+		conf.LeaseDuration = DEFAULT_LEASE_DURATION
+	}
+
+	if conf.RenewPeriod <= 0 {
+		conf.RenewPeriod = DEFAULT_RENEW_PERIOD
+	}
+
+	if conf.RenewPeriod >= conf.LeaseDuration {
+		return nil, fmt.Errorf("sharding renew period %s must be lower than the lease duration %s", conf.RenewPeriod, conf.LeaseDuration)
+	}
+
+	return &Sharder{
+		logger: log.Logger().WithFields(logrus.Fields{
+			"source":   "sharder",
+			"group":    conf.Group,
+			"identity": conf.Identity,
+		}),
+		config: conf,
+		client: c,
+		reader: reader,
+		synced: make(chan struct{}),
+		now:    time.Now,
+	}, nil
+}
+
+// Start renews the Lease of the replica and syncs the members of the group until ctx is cancelled.
+// The Lease is deleted when the Sharder stops so other members take over its resources right away.
+func (s *Sharder) Start(ctx context.Context) error {
+	s.logger.Info("starting sharder")
+
+	ticker := time.NewTicker(s.config.RenewPeriod)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sync(ctx); err != nil {
+			s.logger.WithError(err).Error("error syncing sharding members")
+		}
+
+		select {
+		case <-ctx.Done():
+			s.release()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns false so every replica runs the Sharder
+func (s *Sharder) NeedLeaderElection() bool {
+	return false
+}
+
+// Sync renews the Lease of the replica and updates the members of the group
+func (s *Sharder) Sync(ctx context.Context) error {
+	// Other members compute the expiration of the Lease from the time the renewal started
+	renewed := s.now()
+	if err := s.renew(ctx, renewed); err != nil {
+		return fmt.Errorf("error renewing lease: %v", err)
+	}
+
+	members, err := s.list(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing leases: %v", err)
+	}
+
+	s.mutex.Lock()
+	previous := s.members
+	s.members = members
+	s.lastRenew = renewed
+	s.mutex.Unlock()
+
+	metrics.ShardMembers.WithLabelValues(s.config.ClusterName).Set(float64(len(members)))
+
+	select {
+	case <-s.synced:
+	default:
+		close(s.synced)
+		return nil
+	}
+
+	if strings.Join(members, ",") != strings.Join(previous, ",") {
+		s.logger.WithField("members", members).Info("sharding members changed, rebalancing resources")
+		if s.config.OnChange != nil {
+			s.config.OnChange(previous)
+		}
+	}
+
+	return nil
+}
+
+// Synced returns a channel closed once the members of the group are synced for the first time
+func (s *Sharder) Synced() <-chan struct{} {
+	return s.synced
+}
+
+// Members returns the identities of the live members of the group, including the replica itself
+func (s *Sharder) Members() []string {
+	defer s.mutex.RUnlock()
+	s.mutex.RLock()
+
+	return append([]string(nil), s.members...)
+}
+
+// Owns returns true if the resource is assigned to the replica.
+// It returns ErrNotReady if members have not been synced or the Lease of the replica may have expired.
+func (s *Sharder) Owns(obj runtime.Object) (bool, error) {
+	key, err := s.key(obj)
+	if err != nil {
+		return false, err
+	}
+
+	defer s.mutex.RUnlock()
+	s.mutex.RLock()
+
+	if len(s.members) == 0 || s.now().Sub(s.lastRenew) >= s.config.LeaseDuration {
+		return false, ErrNotReady
+	}
+
+	return Owner(s.members, key) == s.config.Identity, nil
+}
+
+// Gained returns true if the resource is assigned to the replica but was assigned to another member of previous
+func (s *Sharder) Gained(previous []string, obj runtime.Object) (bool, error) {
+	owned, err := s.Owns(obj)
+	if err != nil || !owned {
+		return false, err
+	}
+
+	key, err := s.key(obj)
+	if err != nil {
+		return false, err
+	}
+
+	return Owner(previous, key) != s.config.Identity, nil
+}
+
+// key returns the key used to assign the resource to a member
+func (s *Sharder) key(obj runtime.Object) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+
+	if s.config.Strategy == STRATEGY_NAMESPACE {
+		return accessor.GetNamespace(), nil
+	}
+
+	return types.NamespacedName{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}.String(), nil
+}
+
+// Owner returns the member with the highest score for the key. Every replica with the same members picks the same owner.
+func Owner(members []string, key string) string {
+	var owner string
+	var highest uint64
+	for _, member := range members {
+		score := score(member, key)
+		if owner == "" || score > highest {
+			owner, highest = member, score
+		}
+	}
+
+	return owner
+}
+
+// score hashes the member and key. The FNV hash is mixed since member names often differ on the last characters only.
+func score(member, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(member))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
+
+// renew creates or updates the Lease of the replica
+func (s *Sharder) renew(ctx context.Context, renewed time.Time) error {
+	now := metav1.NewMicroTime(renewed)
+	seconds := int32(s.config.LeaseDuration.Seconds())
+	identity := s.config.Identity
+
+	lease := &coordinationv1.Lease{}
+	err := s.reader.Get(ctx, client.ObjectKey{Namespace: s.config.Namespace, Name: s.leaseName()}, lease)
+	if apierrors.IsNotFound(err) {
+		return s.client.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.leaseName(),
+				Namespace: s.config.Namespace,
+				Labels:    map[string]string{GROUP_LABEL: s.config.Group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	lease.Spec.HolderIdentity = &identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+
+	return s.client.Update(ctx, lease)
+}
+
+// list returns the sorted identities of the members which Leases have not expired.
+// Leases that expired for longer than LeaseDuration are deleted.
+func (s *Sharder) list(ctx context.Context) ([]string, error) {
+	leases := &coordinationv1.LeaseList{}
+	if err := s.reader.List(ctx, leases, client.InNamespace(s.config.Namespace), client.MatchingLabels{GROUP_LABEL: s.config.Group}); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	members := []string{s.config.Identity}
+	for _, lease := range leases.Items {
+		spec := lease.Spec
+		if spec.HolderIdentity == nil || *spec.HolderIdentity == s.config.Identity || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+			continue
+		}
+
+		expires := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+		if now.Before(expires) {
+			members = append(members, *spec.HolderIdentity)
+			continue
+		}
+
+		if now.Sub(expires) >= s.config.LeaseDuration {
+			s.collect(ctx, lease)
+		}
+	}
+
+	sort.Strings(members)
+	return members, nil
+}
+
+// release deletes the Lease of the replica
+func (s *Sharder) release() {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	s.members = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.RenewPeriod)
+	defer cancel()
+
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: s.leaseName(), Namespace: s.config.Namespace},
+	}
+	if err := s.client.Delete(ctx, lease); err != nil && !apierrors.IsNotFound(err) {
+		s.logger.WithError(err).Warn("error releasing lease")
+	}
+}
+
+// collect deletes the expired Lease of another member. The Lease is not deleted if it was renewed since it was listed.
+func (s *Sharder) collect(ctx context.Context, lease coordinationv1.Lease) {
+	logger := s.logger.WithField("lease", lease.Name)
+	err := s.client.Delete(ctx, &lease, client.Preconditions{ResourceVersion: &lease.ResourceVersion})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		logger.WithError(err).Warn("error deleting expired lease")
+		return
+	}
+
+	logger.Info("expired lease deleted")
+}
+
+func (s *Sharder) leaseName() string {
+	return s.config.Group + "-" + s.config.Identity
+}
@@ -0,0 +1,256 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newSharder(t *testing.T, c client.Client, identity string, strategy Strategy) *Sharder {
+	sharder, err := New(&Config{
+		Group:     "broadcaster",
+		Identity:  identity,
+		Namespace: "default",
+		Strategy:  strategy,
+	}, c, c)
+	require.NoError(t, err)
+
+	return sharder
+}
+
+func newPod(namespace, name string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+func Test_Owner(t *testing.T) {
+	members := []string{"broadcaster-0", "broadcaster-1", "broadcaster-2"}
+
+	t.Run("it should spread keys across members", func(t *testing.T) {
+		owned := map[string]int{}
+		for i := 0; i < 3000; i++ {
+			owned[Owner(members, fmt.Sprintf("default/gameserver-%d", i))]++
+		}
+
+		for _, member := range members {
+			require.InDelta(t, 1000, owned[member], 150)
+		}
+	})
+
+	t.Run("it should only move the keys of the member that left", func(t *testing.T) {
+		remaining := []string{"broadcaster-0", "broadcaster-2"}
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("default/gameserver-%d", i)
+			if owner := Owner(members, key); owner != "broadcaster-1" {
+				require.Equal(t, owner, Owner(remaining, key))
+			}
+		}
+	})
+}
+
+func Test_Sharder_Owns(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it should fail before members are synced", func(t *testing.T) {
+		sharder := newSharder(t, fake.NewClientBuilder().Build(), "broadcaster-0", STRATEGY_NAME)
+
+		_, err := sharder.Owns(newPod("default", "gameserver"))
+		require.ErrorIs(t, err, ErrNotReady)
+	})
+
+	t.Run("it should assign each resource to exactly one replica", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		sharders := []*Sharder{
+			newSharder(t, c, "broadcaster-0", STRATEGY_NAME),
+			newSharder(t, c, "broadcaster-1", STRATEGY_NAME),
+			newSharder(t, c, "broadcaster-2", STRATEGY_NAME),
+		}
+		for _, s := range append(sharders, sharders...) {
+			require.NoError(t, s.Sync(ctx))
+		}
+
+		for _, s := range sharders {
+			require.Equal(t, []string{"broadcaster-0", "broadcaster-1", "broadcaster-2"}, s.Members())
+		}
+
+		for i := 0; i < 100; i++ {
+			owners := 0
+			for _, s := range sharders {
+				owned, err := s.Owns(newPod("default", fmt.Sprintf("gameserver-%d", i)))
+				require.NoError(t, err)
+				if owned {
+					owners++
+				}
+			}
+			require.Equal(t, 1, owners)
+		}
+	})
+
+	t.Run("it should assign resources of a namespace to the same replica", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		sharder := newSharder(t, c, "broadcaster-0", STRATEGY_NAMESPACE)
+		other := newSharder(t, c, "broadcaster-1", STRATEGY_NAMESPACE)
+		require.NoError(t, other.Sync(ctx))
+		require.NoError(t, sharder.Sync(ctx))
+
+		want, err := sharder.Owns(newPod("team-a", "gameserver-0"))
+		require.NoError(t, err)
+		for i := 1; i < 20; i++ {
+			owned, err := sharder.Owns(newPod("team-a", fmt.Sprintf("gameserver-%d", i)))
+			require.NoError(t, err)
+			require.Equal(t, want, owned)
+		}
+	})
+
+	t.Run("it should take over the resources of members which leases expired", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		sharder := newSharder(t, c, "broadcaster-0", STRATEGY_NAME)
+		other := newSharder(t, c, "broadcaster-1", STRATEGY_NAME)
+		other.now = func() time.Time {
+			return time.Now().Add(-time.Minute)
+		}
+		require.NoError(t, other.Sync(ctx))
+		require.NoError(t, sharder.Sync(ctx))
+
+		require.Equal(t, []string{"broadcaster-0"}, sharder.Members())
+		owned, err := sharder.Owns(newPod("default", "gameserver"))
+		require.NoError(t, err)
+		require.True(t, owned)
+	})
+
+	t.Run("it should fail when its own lease may have expired", func(t *testing.T) {
+		sharder := newSharder(t, fake.NewClientBuilder().Build(), "broadcaster-0", STRATEGY_NAME)
+		require.NoError(t, sharder.Sync(ctx))
+		sharder.now = func() time.Time {
+			return time.Now().Add(DEFAULT_LEASE_DURATION)
+		}
+
+		_, err := sharder.Owns(newPod("default", "gameserver"))
+		require.ErrorIs(t, err, ErrNotReady)
+	})
+
+	t.Run("it should delete its lease when released", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		sharder := newSharder(t, c, "broadcaster-0", STRATEGY_NAME)
+		require.NoError(t, sharder.Sync(ctx))
+		sharder.release()
+
+		leases := &coordinationv1.LeaseList{}
+		require.NoError(t, c.List(ctx, leases))
+		require.Empty(t, leases.Items)
+	})
+}
+
+func Test_New_InvalidConfig(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config *Config
+	}{
+		{
+			desc:   "it should fail without identity",
+			config: &Config{Group: "broadcaster", Namespace: "default"},
+		},
+		{
+			desc:   "it should fail on unknown strategies",
+			config: &Config{Group: "broadcaster", Identity: "broadcaster-0", Namespace: "default", Strategy: "random"},
+		},
+		{
+			desc:   "it should fail when leases are renewed after they expire",
+			config: &Config{Group: "broadcaster", Identity: "broadcaster-0", Namespace: "default", LeaseDuration: time.Second, RenewPeriod: time.Second},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := New(tc.config, nil, nil)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_Sharder_Sync(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it should be synced after the first sync", func(t *testing.T) {
+		sharder := newSharder(t, fake.NewClientBuilder().Build(), "broadcaster-0", STRATEGY_NAME)
+		select {
+		case <-sharder.Synced():
+			t.Fatal("sharder should not be synced")
+		default:
+		}
+
+		require.NoError(t, sharder.Sync(ctx))
+		select {
+		case <-sharder.Synced():
+		default:
+			t.Fatal("sharder should be synced")
+		}
+	})
+
+	t.Run("it should notify the previous members when members change", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		var changes [][]string
+		sharder, err := New(&Config{
+			Group:     "broadcaster",
+			Identity:  "broadcaster-0",
+			Namespace: "default",
+			OnChange: func(previous []string) {
+				changes = append(changes, previous)
+			},
+		}, c, c)
+		require.NoError(t, err)
+
+		require.NoError(t, sharder.Sync(ctx))
+		require.NoError(t, sharder.Sync(ctx))
+		require.Empty(t, changes, "it should not notify the first sync or syncs without changes")
+
+		require.NoError(t, newSharder(t, c, "broadcaster-1", STRATEGY_NAME).Sync(ctx))
+		require.NoError(t, sharder.Sync(ctx))
+		require.Equal(t, [][]string{{"broadcaster-0"}}, changes)
+	})
+
+	t.Run("it should delete leases expired for longer than the lease duration", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		sharder := newSharder(t, c, "broadcaster-0", STRATEGY_NAME)
+		expiring := newSharder(t, c, "broadcaster-1", STRATEGY_NAME)
+		expiring.now = func() time.Time {
+			return time.Now().Add(-DEFAULT_LEASE_DURATION - time.Second)
+		}
+		expired := newSharder(t, c, "broadcaster-2", STRATEGY_NAME)
+		expired.now = func() time.Time {
+			return time.Now().Add(-2*DEFAULT_LEASE_DURATION - time.Second)
+		}
+		require.NoError(t, expiring.Sync(ctx))
+		require.NoError(t, expired.Sync(ctx))
+		require.NoError(t, sharder.Sync(ctx))
+
+		leases := &coordinationv1.LeaseList{}
+		require.NoError(t, c.List(ctx, leases))
+		var names []string
+		for _, lease := range leases.Items {
+			names = append(names, lease.Name)
+		}
+		require.ElementsMatch(t, []string{"broadcaster-broadcaster-0", "broadcaster-broadcaster-1"}, names)
+	})
+}
+
+func Test_Sharder_Gained(t *testing.T) {
+	c := fake.NewClientBuilder().Build()
+	sharder := newSharder(t, c, "broadcaster-0", STRATEGY_NAME)
+	require.NoError(t, sharder.Sync(context.Background()))
+
+	previous := []string{"broadcaster-0", "broadcaster-1"}
+	for i := 0; i < 100; i++ {
+		pod := newPod("default", fmt.Sprintf("gameserver-%d", i))
+		gained, err := sharder.Gained(previous, pod)
+		require.NoError(t, err)
+		require.Equal(t, Owner(previous, "default/"+pod.Name) == "broadcaster-1", gained)
+	}
+}
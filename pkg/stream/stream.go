@@ -0,0 +1,517 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/brokers"
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+	"github.com/Octops/agones-event-broadcaster/pkg/metrics"
+	"github.com/Octops/agones-event-broadcaster/pkg/runtime/log"
+)
+
+const (
+	// DEFAULT_BUFFER_SIZE is the default number of envelopes waiting to be sent to a client before it is dropped
+	DEFAULT_BUFFER_SIZE = 256
+	// KEEPALIVE_PERIOD is the time between comments sent to SSE clients so idle connections are not closed by proxies
+	KEEPALIVE_PERIOD = 15 * time.Second
+
+	EVENT_TYPE_HEADER_KEY = "event_type"
+	KIND_HEADER_KEY       = "kind"
+	NAMESPACE_HEADER_KEY  = "namespace"
+	NAME_HEADER_KEY       = "name"
+)
+
+var _ brokers.Broker = (*StreamBroker)(nil)
+
+// Snapshot returns the events sent to clients that request a snapshot when they connect
+type Snapshot func(ctx context.Context) ([]events.Event, error)
+
+// Config of the stream. BufferSize is the number of envelopes waiting to be sent to a client before it is dropped.
+// Defaults to DEFAULT_BUFFER_SIZE.
+// AllowedOrigins are the origins, e.g. https://dashboard.example.com, of the browsers that can connect using WebSockets,
+// in addition to the origin of the server itself. "*" allows every origin. Clients that don't send an Origin are allowed.
+type Config struct {
+	BufferSize     int
+	AllowedOrigins []string
+}
+
+// StreamBroker is a broker that streams envelopes to the clients connected using Server-Sent Events or WebSockets.
+// Sending messages never blocks, clients that don't keep up with the stream are disconnected.
+type StreamBroker struct {
+	logger   *logrus.Entry
+	config   *Config
+	scheme   *runtime.Scheme
+	snapshot Snapshot
+	mutex    sync.RWMutex
+	clients  map[*subscriber]bool
+}
+
+// message is the content of the envelopes built by the StreamBroker. Labels are kept for filtering and not encoded.
+type message struct {
+	content interface{}
+	labels  labels.Set
+}
+
+func (m *message) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.content)
+}
+
+// frame is an encoded envelope sent to clients
+type frame struct {
+	id        string
+	eventType string
+	data      []byte
+}
+
+// subscriber is a connected client. dropped is closed when the client doesn't keep up with the stream.
+// While holding, e.g. while the snapshot is sent, frames are held without limit instead of being buffered.
+type subscriber struct {
+	filter  *Filter
+	frames  chan *frame
+	dropped chan struct{}
+	mutex   sync.Mutex
+	holding bool
+	held    []*frame
+}
+
+// Filter selects the envelopes sent to a client. Empty fields match every envelope.
+type Filter struct {
+	Kinds      []string
+	Namespaces []string
+	EventTypes []string
+	Selector   labels.Selector
+}
+
+func New(config *Config, scheme *runtime.Scheme, snapshot Snapshot) *StreamBroker {
+	if config.BufferSize <= 0 {
+		config.BufferSize = DEFAULT_BUFFER_SIZE
+	}
+
+	return &StreamBroker{
+		logger:   log.NewLoggerWithField("source", "stream"),
+		config:   config,
+		scheme:   scheme,
+		snapshot: snapshot,
+		clients:  map[*subscriber]bool{},
+	}
+}
+
+// BuildEnvelope builds the envelope of the event. Headers identify the resource so clients can filter envelopes.
+func (s *StreamBroker) BuildEnvelope(event events.Event) (*events.Envelope, error) {
+	envelope := &events.Envelope{}
+	envelope.AddHeader(EVENT_TYPE_HEADER_KEY, event.EventType().String())
+
+	msg := &message{}
+	if m, ok := event.(events.Message); ok {
+		envelope.AddMetadataHeaders(events.MetadataOf(m))
+		msg.content = m.Content()
+
+		if obj, ok := events.ResourceObject(m); ok {
+			if gvk, err := apiutil.GVKForObject(obj, s.scheme); err == nil {
+				envelope.AddHeader(KIND_HEADER_KEY, gvk.Kind)
+			}
+			if accessor, err := meta.Accessor(obj); err == nil {
+				if accessor.GetNamespace() != "" {
+					envelope.AddHeader(NAMESPACE_HEADER_KEY, accessor.GetNamespace())
+				}
+				envelope.AddHeader(NAME_HEADER_KEY, accessor.GetName())
+				msg.labels = accessor.GetLabels()
+			}
+		}
+	}
+	envelope.Message = msg
+
+	return envelope, nil
+}
+
+// SendMessage sends the envelope to the clients which filters match it. Clients which buffer is full are dropped.
+func (s *StreamBroker) SendMessage(envelope *events.Envelope) error {
+	f, err := encode(envelope)
+	if err != nil {
+		return err
+	}
+
+	var slow []*subscriber
+	s.mutex.RLock()
+	for c := range s.clients {
+		if !c.filter.Match(envelope) {
+			continue
+		}
+
+		if !c.offer(f) {
+			slow = append(slow, c)
+		}
+	}
+	s.mutex.RUnlock()
+
+	for _, c := range slow {
+		s.drop(c)
+	}
+
+	return nil
+}
+
+// Clients returns the number of connected clients
+func (s *StreamBroker) Clients() int {
+	defer s.mutex.RUnlock()
+	s.mutex.RLock()
+
+	return len(s.clients)
+}
+
+// SSEHandler streams envelopes using Server-Sent Events. The id of each event is the event ID and its type is the event type.
+func (s *StreamBroker) SSEHandler() http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		flusher, ok := resp.(http.Flusher)
+		if !ok {
+			http.Error(resp, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		c, snapshot, err := s.connect(req)
+		if err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer s.disconnect(c)
+
+		resp.Header().Set("Content-Type", "text/event-stream")
+		resp.Header().Set("Cache-Control", "no-cache")
+		resp.Header().Set("Connection", "keep-alive")
+		resp.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		send := func(f *frame) error {
+			if _, err := fmt.Fprintf(resp, "id: %s\nevent: %s\ndata: %s\n\n", f.id, f.eventType, f.data); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		}
+
+		keepalive := func() error {
+			if _, err := fmt.Fprint(resp, ": keepalive\n\n"); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		}
+
+		dropped := func() {
+			fmt.Fprint(resp, "event: dropped\ndata: the client didn't keep up with the stream\n\n")
+			flusher.Flush()
+		}
+
+		s.serve(req.Context(), c, snapshot, send, keepalive, dropped)
+	})
+}
+
+// WebSocketHandler streams envelopes using WebSockets. Each envelope is sent as a JSON text message.
+// Messages sent by clients are ignored. Browsers on origins that are not allowed are rejected.
+func (s *StreamBroker) WebSocketHandler() http.Handler {
+	return websocket.Server{
+		Handshake: s.checkOrigin,
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+
+			c, snapshot, err := s.connect(conn.Request())
+			if err != nil {
+				_ = websocket.JSON.Send(conn, map[string]string{"error": err.Error()})
+				return
+			}
+			defer s.disconnect(c)
+
+			// The connection is closed by the client when reading fails
+			ctx, cancel := context.WithCancel(conn.Request().Context())
+			defer cancel()
+			go func() {
+				defer cancel()
+				var ignored string
+				for websocket.Message.Receive(conn, &ignored) == nil {
+				}
+			}()
+
+			send := func(f *frame) error {
+				return websocket.Message.Send(conn, string(f.data))
+			}
+
+			s.serve(ctx, c, snapshot, send, func() error { return nil }, func() {})
+		},
+	}
+}
+
+// checkOrigin rejects the WebSocket handshake of browsers on origins that are not allowed. Requests without Origin,
+// which are not sent by browsers, and requests from the origin of the server are allowed.
+func (s *StreamBroker) checkOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	for _, allowed := range s.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return nil
+		}
+	}
+
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, req.Host) {
+		return nil
+	}
+
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+// connect subscribes a client using the filter of the request and returns the snapshot, if requested
+func (s *StreamBroker) connect(req *http.Request) (*subscriber, []*frame, error) {
+	filter, err := ParseFilter(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	withSnapshot := req.URL.Query().Get("snapshot") == "true" && s.snapshot != nil
+	c := &subscriber{
+		filter:  filter,
+		frames:  make(chan *frame, s.config.BufferSize),
+		dropped: make(chan struct{}),
+		holding: withSnapshot,
+	}
+
+	// The client is subscribed before the snapshot is taken, so envelopes sent while it is taken are held and sent
+	// after the snapshot. The snapshot is taken without the lock so sending envelopes doesn't wait for it.
+	s.mutex.Lock()
+	s.clients[c] = true
+	s.mutex.Unlock()
+	metrics.StreamClients.Inc()
+
+	var snapshot []events.Event
+	if withSnapshot {
+		snapshot, err = s.snapshot(req.Context())
+	}
+
+	if err != nil {
+		s.disconnect(c)
+		return nil, nil, fmt.Errorf("error taking snapshot: %v", err)
+	}
+
+	var frames []*frame
+	for _, event := range snapshot {
+		envelope, err := s.BuildEnvelope(event)
+		if err != nil || !filter.Match(envelope) {
+			continue
+		}
+
+		f, err := encode(envelope)
+		if err != nil {
+			s.logger.WithError(err).Warn("error encoding snapshot event")
+			continue
+		}
+		frames = append(frames, f)
+	}
+
+	return c, frames, nil
+}
+
+// serve sends the snapshot followed by the envelopes of the client until ctx is cancelled, sending fails or the client is dropped.
+// Envelopes sent while the snapshot is sent are held, so large snapshots don't fill the buffer of the client.
+func (s *StreamBroker) serve(ctx context.Context, c *subscriber, snapshot []*frame, send func(*frame) error, keepalive func() error, dropped func()) {
+	for _, f := range snapshot {
+		if err := send(f); err != nil {
+			return
+		}
+	}
+
+	for held := c.release(); len(held) > 0; held = c.release() {
+		for _, f := range held {
+			if err := send(f); err != nil {
+				return
+			}
+		}
+	}
+
+	ticker := time.NewTicker(KEEPALIVE_PERIOD)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.dropped:
+			dropped()
+			return
+		case <-ticker.C:
+			if err := keepalive(); err != nil {
+				return
+			}
+		case f := <-c.frames:
+			if err := send(f); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// offer buffers the frame, or holds it while the client is holding. It returns false if the buffer is full.
+func (c *subscriber) offer(f *frame) bool {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+
+	if c.holding {
+		c.held = append(c.held, f)
+		return true
+	}
+
+	select {
+	case c.frames <- f:
+		return true
+	default:
+		return false
+	}
+}
+
+// release returns the frames held since the last call. Once no frame is held, the client stops holding frames.
+func (c *subscriber) release() []*frame {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+
+	held := c.held
+	c.held = nil
+	if len(held) == 0 {
+		c.holding = false
+	}
+
+	return held
+}
+
+// drop disconnects a client that didn't keep up with the stream
+func (s *StreamBroker) drop(c *subscriber) {
+	if s.disconnect(c) {
+		close(c.dropped)
+		metrics.StreamClientsDropped.Inc()
+		s.logger.Warn("client dropped for not keeping up with the stream")
+	}
+}
+
+// disconnect unsubscribes the client. It returns false if the client was already disconnected.
+func (s *StreamBroker) disconnect(c *subscriber) bool {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	if !s.clients[c] {
+		return false
+	}
+
+	delete(s.clients, c)
+	metrics.StreamClients.Dec()
+
+	return true
+}
+
+// ParseFilter returns the filter of the kind, namespace, event_type and labelSelector parameters of the request.
+// Parameters are comma-separated lists. Kinds are case-insensitive, e.g. GameServer or gameserver.
+func ParseFilter(req *http.Request) (*Filter, error) {
+	params := req.URL.Query()
+
+	selector, err := labels.Parse(params.Get("labelSelector"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid labelSelector: %v", err)
+	}
+
+	return &Filter{
+		Kinds:      splitValues(params["kind"]),
+		Namespaces: splitValues(params["namespace"]),
+		EventTypes: splitValues(params["event_type"]),
+		Selector:   selector,
+	}, nil
+}
+
+// Match returns true if the envelope matches every field of the filter
+func (f *Filter) Match(envelope *events.Envelope) bool {
+	headers := map[string]string{}
+	if envelope.Header != nil {
+		headers = envelope.Header.Headers
+	}
+
+	if len(f.Kinds) > 0 && !containsFold(f.Kinds, headers[KIND_HEADER_KEY]) {
+		return false
+	}
+
+	if len(f.Namespaces) > 0 && !contains(f.Namespaces, headers[NAMESPACE_HEADER_KEY]) {
+		return false
+	}
+
+	if len(f.EventTypes) > 0 && !contains(f.EventTypes, headers[EVENT_TYPE_HEADER_KEY]) {
+		return false
+	}
+
+	if f.Selector != nil && !f.Selector.Empty() {
+		msg, ok := envelope.Message.(*message)
+		if !ok || !f.Selector.Matches(msg.labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func encode(envelope *events.Envelope) (*frame, error) {
+	data, err := envelope.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding envelope: %v", err)
+	}
+
+	f := &frame{data: data}
+	if envelope.Header != nil {
+		f.id = envelope.Header.Headers[events.EVENT_ID_HEADER_KEY]
+		f.eventType = envelope.Header.Headers[EVENT_TYPE_HEADER_KEY]
+	}
+
+	return f, nil
+}
+
+// splitValues returns the comma-separated values of a parameter
+func splitValues(params []string) []string {
+	var values []string
+	for _, param := range params {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,286 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+// scheme knows the Agones types, the same way the scheme of the manager does
+var scheme = func() *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := v1.AddToScheme(s); err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+func newEvent(namespace, name string, labels map[string]string) events.Event {
+	return events.OnAdded(&events.AddedMessage{
+		Obj: &v1.GameServer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		},
+		Meta: &events.Metadata{ID: name},
+	})
+}
+
+func send(t *testing.T, s *StreamBroker, event events.Event) {
+	envelope, err := s.BuildEnvelope(event)
+	require.NoError(t, err)
+	require.NoError(t, s.SendMessage(envelope))
+}
+
+// waitForClients waits until the number of clients connected to the broker is n
+func waitForClients(t *testing.T, s *StreamBroker, n int) {
+	require.Eventually(t, func() bool { return s.Clients() == n }, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_Filter_Match(t *testing.T) {
+	s := New(&Config{}, scheme, nil)
+	envelope, err := s.BuildEnvelope(newEvent("default", "simple-udp", map[string]string{"mode": "ranked"}))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc  string
+		query string
+		want  bool
+	}{
+		{
+			desc: "it should match every envelope without filters",
+			want: true,
+		},
+		{
+			desc:  "it should match kinds ignoring the case",
+			query: "kind=fleet,gameserver",
+			want:  true,
+		},
+		{
+			desc:  "it should not match other kinds",
+			query: "kind=Fleet",
+		},
+		{
+			desc:  "it should match namespaces and event types",
+			query: "namespace=default&event_type=gameserver.events.added",
+			want:  true,
+		},
+		{
+			desc:  "it should not match other event types",
+			query: "event_type=gameserver.events.deleted",
+		},
+		{
+			desc:  "it should match label selectors",
+			query: "labelSelector=mode in (ranked,casual)",
+			want:  true,
+		},
+		{
+			desc:  "it should not match other labels",
+			query: "labelSelector=mode!=ranked",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/events", nil)
+			req.URL.RawQuery = strings.ReplaceAll(tc.query, " ", "+")
+
+			filter, err := ParseFilter(req)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, filter.Match(envelope))
+		})
+	}
+}
+
+func Test_StreamBroker_SSE(t *testing.T) {
+	s := New(&Config{BufferSize: 2}, scheme, func(ctx context.Context) ([]events.Event, error) {
+		return []events.Event{newEvent("default", "existing", nil), newEvent("ranked", "filtered", nil)}, nil
+	})
+	srv := httptest.NewServer(s.SSEHandler())
+	defer srv.Close()
+
+	t.Run("it should stream the snapshot followed by the events matching the filter", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "?namespace=default&snapshot=true")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		waitForClients(t, s, 1)
+
+		send(t, s, newEvent("ranked", "filtered", nil))
+		send(t, s, newEvent("default", "created", nil))
+
+		reader := bufio.NewReader(resp.Body)
+		for _, want := range []string{"existing", "created"} {
+			require.Equal(t, "id: "+want+"\n", readLine(t, reader))
+			require.Equal(t, "event: gameserver.events.added\n", readLine(t, reader))
+
+			data := strings.TrimPrefix(readLine(t, reader), "data: ")
+			envelope := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal([]byte(data), &envelope))
+			require.Equal(t, want, envelope["message"].(map[string]interface{})["metadata"].(map[string]interface{})["name"])
+			require.Equal(t, "\n", readLine(t, reader))
+		}
+	})
+	waitForClients(t, s, 0)
+
+	t.Run("it should drop clients that don't keep up with the stream", func(t *testing.T) {
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		waitForClients(t, s, 1)
+
+		// The client doesn't read while events are sent, so the buffer fills up at some point
+		for i := 0; i < 100000 && s.Clients() > 0; i++ {
+			send(t, s, newEvent("default", "simple-udp", nil))
+		}
+		require.Equal(t, 0, s.Clients())
+	})
+}
+
+func Test_StreamBroker_WebSocket(t *testing.T) {
+	s := New(&Config{}, scheme, nil)
+	srv := httptest.NewServer(s.WebSocketHandler())
+	defer srv.Close()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?kind=GameServer", "", srv.URL)
+	require.NoError(t, err)
+	waitForClients(t, s, 1)
+
+	send(t, s, newEvent("default", "simple-udp", nil))
+
+	var envelope events.Envelope
+	require.NoError(t, websocket.JSON.Receive(conn, &envelope))
+	require.Equal(t, "simple-udp", envelope.Header.Headers[NAME_HEADER_KEY])
+	require.Equal(t, "GameServer", envelope.Header.Headers[KIND_HEADER_KEY])
+
+	require.NoError(t, conn.Close())
+	waitForClients(t, s, 0)
+}
+
+func Test_StreamBroker_WebSocket_Origin(t *testing.T) {
+	s := New(&Config{AllowedOrigins: []string{"https://dashboard.example.com"}}, scheme, nil)
+	srv := httptest.NewServer(s.WebSocketHandler())
+	defer srv.Close()
+
+	testCases := []struct {
+		desc    string
+		origin  string
+		wantErr bool
+	}{
+		{
+			desc:   "it should accept the origin of the server",
+			origin: srv.URL,
+		},
+		{
+			desc:   "it should accept allowed origins",
+			origin: "https://dashboard.example.com",
+		},
+		{
+			desc:    "it should reject other origins",
+			origin:  "https://attacker.example.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			conn, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", tc.origin)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NoError(t, conn.Close())
+		})
+	}
+}
+
+func Test_StreamBroker_connect_Snapshot(t *testing.T) {
+	taking, release := make(chan struct{}), make(chan struct{})
+	s := New(&Config{BufferSize: 1}, scheme, func(ctx context.Context) ([]events.Event, error) {
+		close(taking)
+		<-release
+		return []events.Event{newEvent("default", "existing", nil)}, nil
+	})
+
+	type connected struct {
+		c        *subscriber
+		snapshot []*frame
+		err      error
+	}
+	done := make(chan connected)
+	go func() {
+		c, snapshot, err := s.connect(httptest.NewRequest(http.MethodGet, "/events?snapshot=true", nil))
+		done <- connected{c, snapshot, err}
+	}()
+	<-taking
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for _, name := range []string{"live-1", "live-2"} {
+			send(t, s, newEvent("default", name, nil))
+		}
+	}()
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("it should send envelopes while the snapshot is taken")
+	}
+	close(release)
+
+	result := <-done
+	require.NoError(t, result.err)
+	require.Len(t, result.snapshot, 1)
+	require.Equal(t, "existing", result.snapshot[0].id)
+
+	held := result.c.release()
+	require.Len(t, held, 2, "it should hold the envelopes sent while the snapshot is taken beyond the buffer size")
+	require.Equal(t, "live-1", held[0].id)
+	require.Equal(t, "live-2", held[1].id)
+}
+
+func Test_StreamBroker_serve_Snapshot(t *testing.T) {
+	s := New(&Config{BufferSize: 1}, scheme, nil)
+	c := &subscriber{frames: make(chan *frame, 1), dropped: make(chan struct{}), holding: true}
+	for _, id := range []string{"live-1", "live-2", "live-3"} {
+		require.True(t, c.offer(&frame{id: id}), "it should hold envelopes beyond the buffer size while the snapshot is sent")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var sent []string
+	s.serve(ctx, c, []*frame{{id: "snapshot"}}, func(f *frame) error {
+		sent = append(sent, f.id)
+		switch f.id {
+		case "live-3":
+			require.True(t, c.offer(&frame{id: "live-4"}))
+		case "live-4":
+			cancel()
+		}
+		return nil
+	}, func() error { return nil }, func() {})
+
+	require.Equal(t, []string{"snapshot", "live-1", "live-2", "live-3", "live-4"}, sent, "it should send the held envelopes after the snapshot")
+	require.False(t, c.holding)
+}
+
+func readLine(t *testing.T, reader *bufio.Reader) string {
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	return line
+}
@@ -0,0 +1,175 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+// Exporter is where spans are sent to
+type Exporter string
+
+const (
+	// EXPORTER_OTLP sends spans to an OpenTelemetry collector using OTLP over gRPC
+	EXPORTER_OTLP Exporter = "otlp"
+	// EXPORTER_STDOUT writes spans to stdout. Used for local testing
+	EXPORTER_STDOUT Exporter = "stdout"
+	// TRACER_NAME is the name of the tracer of the spans of the broadcaster
+	TRACER_NAME = "github.com/Octops/agones-event-broadcaster"
+	// DEFAULT_SERVICE_NAME is the service name of the spans when Config.ServiceName is empty
+	DEFAULT_SERVICE_NAME = "agones-event-broadcaster"
+)
+
+// Config of the tracer provider.
+// Endpoint is the address of the OTLP collector, e.g. otel-collector:4317. Defaults to the OTEL_EXPORTER_OTLP_ENDPOINT
+// environment variable or localhost:4317. Insecure disables TLS when connecting to the collector.
+// SampleRatio is the fraction of traces started by the broadcaster that are sampled, between 0 and 1.
+// Traces continued from a parent follow the sampling decision of the parent.
+// ServiceVersion and Instance are added as resource attributes of every span.
+type Config struct {
+	Exporter       Exporter
+	Endpoint       string
+	Insecure       bool
+	SampleRatio    float64
+	ServiceName    string
+	ServiceVersion string
+	Instance       string
+}
+
+// Provider creates the spans of the broadcaster and sends them using the exporter
+type Provider struct {
+	*sdktrace.TracerProvider
+}
+
+// New creates the tracer provider and sets it as the global provider, together with the W3C trace context propagator
+// used to add the trace context to the messages published by the brokers
+func New(ctx context.Context, config *Config) (*Provider, error) {
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return nil, fmt.Errorf("invalid sample ratio %v, it must be between 0 and 1", config.SampleRatio)
+	}
+
+	if config.ServiceName == "" {
+		config.ServiceName = DEFAULT_SERVICE_NAME
+	}
+
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := []attribute.KeyValue{semconv.ServiceName(config.ServiceName)}
+	if config.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(config.ServiceVersion))
+	}
+	if config.Instance != "" {
+		attrs = append(attrs, semconv.ServiceInstanceID(config.Instance))
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, attrs...))
+	if err != nil {
+		return nil, fmt.Errorf("error creating tracing resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return &Provider{TracerProvider: provider}, nil
+}
+
+func newExporter(ctx context.Context, config *Config) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case EXPORTER_OTLP:
+		var opts []otlptracegrpc.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		exporter, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating otlp exporter: %v", err)
+		}
+
+		return exporter, nil
+	case EXPORTER_STDOUT:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("error creating stdout exporter: %v", err)
+		}
+
+		return exporter, nil
+	}
+
+	return nil, fmt.Errorf("invalid exporter %q, valid exporters are %s and %s", config.Exporter, EXPORTER_OTLP, EXPORTER_STDOUT)
+}
+
+// Tracer returns the tracer of the spans of the broadcaster. Spans are not recorded until a provider is created by New.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TRACER_NAME)
+}
+
+// Inject adds the trace context of the span of ctx to the headers of the envelope, e.g. the traceparent header,
+// so consumers of the message continue the trace. Nothing is added until a provider is created by New.
+func Inject(ctx context.Context, envelope *events.Envelope) {
+	otel.GetTextMapPropagator().Inject(ctx, envelopeCarrier{envelope: envelope})
+}
+
+// End records the error, if any, on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// envelopeCarrier reads and writes the trace context from the headers of an envelope
+type envelopeCarrier struct {
+	envelope *events.Envelope
+}
+
+func (c envelopeCarrier) Get(key string) string {
+	if c.envelope.Header == nil {
+		return ""
+	}
+
+	return c.envelope.Header.Headers[key]
+}
+
+func (c envelopeCarrier) Set(key, value string) {
+	c.envelope.AddHeader(key, value)
+}
+
+func (c envelopeCarrier) Keys() []string {
+	if c.envelope.Header == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(c.envelope.Header.Headers))
+	for key := range c.envelope.Header.Headers {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+func Test_New(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		config  *Config
+		wantErr bool
+	}{
+		{
+			desc:   "it should create the provider using the stdout exporter",
+			config: &Config{Exporter: EXPORTER_STDOUT, SampleRatio: 1},
+		},
+		{
+			desc:   "it should create the provider using the otlp exporter",
+			config: &Config{Exporter: EXPORTER_OTLP, Endpoint: "localhost:4317", Insecure: true, SampleRatio: 0.5},
+		},
+		{
+			desc:    "it should fail on unknown exporters",
+			config:  &Config{Exporter: "jaeger", SampleRatio: 1},
+			wantErr: true,
+		},
+		{
+			desc:    "it should fail on sample ratios greater than 1",
+			config:  &Config{Exporter: EXPORTER_STDOUT, SampleRatio: 2},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			defer resetGlobals()
+
+			provider, err := New(context.Background(), tc.config)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, DEFAULT_SERVICE_NAME, tc.config.ServiceName)
+			require.NoError(t, provider.Shutdown(context.Background()))
+		})
+	}
+}
+
+func Test_Inject(t *testing.T) {
+	t.Run("it should not add headers when tracing is disabled", func(t *testing.T) {
+		envelope := &events.Envelope{}
+		ctx, span := Tracer().Start(context.Background(), "send")
+		defer span.End()
+
+		Inject(ctx, envelope)
+		require.Empty(t, envelope.TraceHeaders())
+	})
+
+	t.Run("it should add the trace context of the span to the headers", func(t *testing.T) {
+		defer resetGlobals()
+
+		provider, err := New(context.Background(), &Config{Exporter: EXPORTER_STDOUT})
+		require.NoError(t, err)
+		defer provider.Shutdown(context.Background())
+
+		envelope := &events.Envelope{}
+		envelope.AddHeader(events.EVENT_ID_HEADER_KEY, "4b8b2c6e")
+
+		// Traces continued from a sampled parent are sampled even when the sample ratio is 0
+		parent := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+			SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+			TraceFlags: trace.FlagsSampled,
+		})
+		ctx, span := Tracer().Start(trace.ContextWithRemoteSpanContext(context.Background(), parent), "send")
+		defer span.End()
+
+		Inject(ctx, envelope)
+		require.Equal(t, map[string]string{
+			events.TRACEPARENT_HEADER_KEY: "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanContext().SpanID().String() + "-01",
+		}, envelope.TraceHeaders())
+		require.Equal(t, "4b8b2c6e", envelope.Header.Headers[events.EVENT_ID_HEADER_KEY])
+	})
+}
+
+func resetGlobals() {
+	otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+}
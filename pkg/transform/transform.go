@@ -0,0 +1,197 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+// Template renders the message body of events of a particular type.
+// The rendered output must be a valid JSON document.
+type Template struct {
+	EventType string `mapstructure:"eventType"`
+	Template  string `mapstructure:"template"`
+}
+
+// Data is the data structure available to templates.
+// Spec and Status hold the typed spec and status of the resource, or their unstructured representation for unstructured resources.
+// Old is only set for update events. Event holds the metadata of the event.
+type Data struct {
+	Type     string
+	Source   string
+	Metadata metav1.ObjectMeta
+	Spec     interface{}
+	Status   interface{}
+	Object   runtime.Object
+	Old      runtime.Object
+	Event    *events.Metadata
+}
+
+// Transformer renders custom message bodies for events which type has a template
+type Transformer struct {
+	templates map[events.EventType]*template.Template
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// New parses the templates and returns a Transformer. It returns an error if any template is invalid.
+func New(templates []Template) (*Transformer, error) {
+	t := &Transformer{
+		templates: map[events.EventType]*template.Template{},
+	}
+
+	for _, tpl := range templates {
+		if tpl.EventType == "" {
+			return nil, fmt.Errorf("template requires an event type")
+		}
+
+		eventType := events.EventType(tpl.EventType)
+		if _, ok := t.templates[eventType]; ok {
+			return nil, fmt.Errorf("duplicated template for event type %s", eventType)
+		}
+
+		parsed, err := template.New(tpl.EventType).Funcs(funcs).Option("missingkey=error").Parse(tpl.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for event type %s: %v", eventType, err)
+		}
+
+		t.templates[eventType] = parsed
+	}
+
+	return t, nil
+}
+
+// Transform returns an event which content is rendered from the template registered for the event type.
+// Events without a template are returned unchanged.
+func (t *Transformer) Transform(event events.Event) (events.Event, error) {
+	tpl, ok := t.templates[event.EventType()]
+	if !ok {
+		return event, nil
+	}
+
+	data, err := NewData(event)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error rendering template for event type %s: %v", event.EventType(), err)
+	}
+
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("template for event type %s did not render a valid json: %s", event.EventType(), buf.String())
+	}
+
+	return &RenderedEvent{
+		Event:   event,
+		message: event.(events.Message),
+		body:    json.RawMessage(buf.Bytes()),
+	}, nil
+}
+
+// NewData builds the data available to templates for a particular event
+func NewData(event events.Event) (*Data, error) {
+	message, ok := event.(events.Message)
+	if !ok {
+		return nil, fmt.Errorf("event %s does not carry a message", event.EventType())
+	}
+
+	obj, ok := events.ResourceObject(message)
+	if !ok {
+		return nil, fmt.Errorf("event %s does not carry a kubernetes resource", event.EventType())
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing resource metadata: %v", err)
+	}
+
+	data := &Data{
+		Type:   event.EventType().String(),
+		Source: event.EventSource().String(),
+		Metadata: metav1.ObjectMeta{
+			Name:              accessor.GetName(),
+			Namespace:         accessor.GetNamespace(),
+			UID:               accessor.GetUID(),
+			ResourceVersion:   accessor.GetResourceVersion(),
+			Generation:        accessor.GetGeneration(),
+			CreationTimestamp: accessor.GetCreationTimestamp(),
+			DeletionTimestamp: accessor.GetDeletionTimestamp(),
+			Labels:            accessor.GetLabels(),
+			Annotations:       accessor.GetAnnotations(),
+			OwnerReferences:   accessor.GetOwnerReferences(),
+			Finalizers:        accessor.GetFinalizers(),
+		},
+		Spec:   field(obj, "Spec"),
+		Status: field(obj, "Status"),
+		Object: obj,
+		Event:  events.MetadataOf(message),
+	}
+
+	if updated, ok := message.Content().(*events.UpdatedMessage); ok {
+		data.Old = updated.Old
+	}
+
+	return data, nil
+}
+
+// field returns the value of a top level field of the resource like Spec or Status
+func field(obj runtime.Object, name string) interface{} {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.Object[strings.ToLower(name)]
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	f := v.FieldByName(name)
+	if !f.IsValid() || !f.CanInterface() {
+		return nil
+	}
+
+	return f.Interface()
+}
+
+// RenderedEvent is an event which content has been rendered from a template.
+// It keeps the source and type of the original event.
+type RenderedEvent struct {
+	events.Event
+	message events.Message
+	body    json.RawMessage
+}
+
+// Content returns the rendered body
+func (r *RenderedEvent) Content() interface{} {
+	return r.body
+}
+
+// Metadata returns the metadata of the original event
+func (r *RenderedEvent) Metadata() *events.Metadata {
+	return events.MetadataOf(r.message)
+}
+
+// Object returns the resource used to render the event
+func (r *RenderedEvent) Object() runtime.Object {
+	obj, _ := events.ResourceObject(r.message)
+	return obj
+}
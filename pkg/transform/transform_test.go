@@ -0,0 +1,136 @@
+package transform
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	v1 "agones.dev/agones/pkg/apis/agones/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Octops/agones-event-broadcaster/pkg/events"
+)
+
+// loadGameServer reads the GameServer published on one of the sample messages from examples/messages
+func loadGameServer(t *testing.T, file string) *v1.GameServer {
+	data, err := os.ReadFile("../../examples/messages/" + file)
+	require.NoError(t, err)
+
+	sample := struct {
+		Message *v1.GameServer `json:"message"`
+	}{}
+	require.NoError(t, json.Unmarshal(data, &sample))
+
+	return sample.Message
+}
+
+func Test_Transformer_Transform(t *testing.T) {
+	templates := []Template{
+		{
+			EventType: events.GameServerEventAdded.String(),
+			Template:  `{"id": "{{.Metadata.Name}}", "state": "{{.Status.State}}"}`,
+		},
+		{
+			EventType: events.GameServerEventUpdated.String(),
+			Template:  `{"id": "{{.Metadata.Name}}", "addr": "{{.Status.Address}}", "port": {{(index .Status.Ports 0).Port}}, "from": {{json .Old.Status.State}}}`,
+		},
+		{
+			EventType: events.GameServerEventDeleted.String(),
+			Template:  `{"id": "{{.Metadata.Namespace}}/{{.Metadata.Name}}", "type": "{{.Type}}", "source": "{{.Source}}"}`,
+		},
+	}
+
+	added := loadGameServer(t, "add-gameserver.json")
+	updated := loadGameServer(t, "update-gameserver.json")
+	deleted := loadGameServer(t, "delete-gameserver.json")
+
+	testCases := []struct {
+		desc  string
+		event events.Event
+		want  string
+	}{
+		{
+			desc:  "it should render the body of a GameServerAdded event",
+			event: events.GameServerAdded(&events.AddedMessage{Obj: added}),
+			want:  `{"id": "simple-udp-agones", "state": "PortAllocation"}`,
+		},
+		{
+			desc:  "it should render the body of a GameServerUpdated event",
+			event: events.GameServerUpdated(&events.UpdatedMessage{Old: added, New: updated}),
+			want:  `{"id": "simple-udp-agones", "addr": "172.17.0.2", "port": 7412, "from": "PortAllocation"}`,
+		},
+		{
+			desc:  "it should render the body of a GameServerDeleted event",
+			event: events.GameServerDeleted(&events.DeletedMessage{Obj: deleted}),
+			want:  `{"id": "default/simple-udp-agones", "type": "gameserver.events.deleted", "source": "OnDelete"}`,
+		},
+	}
+
+	transformer, err := New(templates)
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := transformer.Transform(tc.event)
+			require.NoError(t, err)
+			require.Equal(t, tc.event.EventType(), got.EventType())
+			require.Equal(t, tc.event.EventSource(), got.EventSource())
+
+			content, ok := got.(events.Message).Content().(json.RawMessage)
+			require.True(t, ok)
+			require.JSONEq(t, tc.want, string(content))
+
+			gs, ok := events.ObjectAs[*v1.GameServer](got.(events.Message))
+			require.True(t, ok)
+			require.Equal(t, "simple-udp-agones", gs.Name)
+		})
+	}
+
+	t.Run("it should not change events without a template", func(t *testing.T) {
+		event := events.FleetAdded(&events.AddedMessage{Obj: &v1.Fleet{}})
+
+		got, err := transformer.Transform(event)
+		require.NoError(t, err)
+		require.Equal(t, event, got)
+	})
+
+	t.Run("it should fail when the template does not render a valid json", func(t *testing.T) {
+		transformer, err := New([]Template{
+			{EventType: events.GameServerEventAdded.String(), Template: `{"id": {{.Metadata.Name}}}`},
+		})
+		require.NoError(t, err)
+
+		_, err = transformer.Transform(events.GameServerAdded(&events.AddedMessage{Obj: added}))
+		require.Error(t, err)
+	})
+}
+
+func Test_New_InvalidTemplates(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		templates []Template
+	}{
+		{
+			desc:      "it should fail on templates that can't be parsed",
+			templates: []Template{{EventType: "gameserver.events.added", Template: `{"id": "{{.Metadata.Name}"}`}},
+		},
+		{
+			desc:      "it should fail on templates without event type",
+			templates: []Template{{Template: `{}`}},
+		},
+		{
+			desc: "it should fail on duplicated event types",
+			templates: []Template{
+				{EventType: "gameserver.events.added", Template: `{}`},
+				{EventType: "gameserver.events.added", Template: `{}`},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := New(tc.templates)
+			require.Error(t, err)
+		})
+	}
+}